@@ -2,18 +2,37 @@ package main
 
 import (
 	L "ethTx/cmd/util/logging"
+	P "ethTx/parser"
 	"ethTx/parser/parser_rest"
 	"flag"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 )
 
 var (
-	rpcURL        = flag.String("rpc.URL", "https://ethereum-rpc.publicnode.com", "Node rpc URL")
-	port          = flag.String("port", ":8080", "Port to run the service on")
-	parseInterval = flag.Duration("parse.interval", time.Second, "Interval on which to query for new block")
-	logLevel      = flag.String("log.level", "info", "Logging level: `info` OR `debug`")
+	rpcURL               = flag.String("rpc.URL", "https://ethereum-rpc.publicnode.com", "Node rpc URL")
+	port                 = flag.String("port", ":8080", "Port to run the service on")
+	parseInterval        = flag.Duration("parse.interval", time.Second, "Interval on which to query for new block")
+	logLevel             = flag.String("log.level", "info", "Logging level: `info` OR `debug`")
+	traceMode            = flag.String("trace.mode", "", "Internal transfer tracking: `debug` (debug_traceBlockByNumber), `parity` (trace_block) OR empty to disable")
+	subscribeDeployments = flag.Bool("subscribe.deployments", false, "Subscribe contracts deployed by subscribed addresses")
+	abiDir               = flag.String("abi.dir", "", "Directory with contract ABIs named `<contract address>.json`")
+	sigFiles             = flag.String("sigdb.files", "", "Comma separated list of files with additional function and event signatures")
+	verifyTransactions   = flag.Bool("verify.tx", false, "Verify sender and hash of matched transactions against their raw signed form")
+	mempoolMode          = flag.String("mempool.mode", "", "Pending transaction tracking: `block` (pending block), `txpool` (txpool_content) OR empty to disable")
+	mempoolInterval      = flag.Duration("mempool.interval", time.Second, "Interval on which to query the mempool")
+	dropTimeout          = flag.Duration("mempool.drop.timeout", P.DefaultDropTimeout, "Time after which pending transactions missing from the mempool are dropped")
+	confirmations        = flag.Int("confirmations", P.DefaultConfirmations, "Number of blocks after which included transactions are confirmed, 0 to disable")
+	stuckTimeout         = flag.Duration("stuck.timeout", P.DefaultStuckTimeout, "Time after which pending transactions of subscribed senders are reported as stuck")
+	reconcileInterval    = flag.Int("balance.reconcile", P.DefaultReconcileInterval, "Number of blocks between reconciliations of derived ETH and token balances with the node, 0 to disable")
+	tokenCache           = flag.String("token.cache", "", "File in which resolved token metadata is cached between restarts")
+	alertWebhook         = flag.String("alert.webhook", "", "URL to which raised alerts are posted as JSON")
+	addressBook          = flag.String("labels.file", "", "File in which the address book is stored between restarts")
+	denylistFiles        = flag.String("denylist.files", "", "Comma separated list of denylist files, e.g. OFAC SDN address exports")
+	denylistReload       = flag.Duration("denylist.reload", P.DefaultDenylistReload, "Interval on which denylist files are checked for changes, 0 to disable")
+	ensRefresh           = flag.Duration("ens.refresh", P.DefaultENSRefresh, "Time after which ENS names are resolved again, 0 to disable")
 )
 
 func main() {
@@ -25,7 +44,50 @@ func main() {
 			meant toonly work with block numbers that can be represented as int (64bit)`)
 
 	// Initializes the service with the provided RPC URL, port, and parse interval.
-	svc := parser_rest.Init(*port, *rpcURL, *parseInterval)
+	svc := parser_rest.Init(*port, *rpcURL, *parseInterval, func(bp *P.BlockParser) {
+		bp.WithTraceMode(*traceMode).
+			WithDeploymentSubscription(*subscribeDeployments).
+			WithVerification(*verifyTransactions).
+			WithMempool(*mempoolMode, *mempoolInterval).
+			WithDropTimeout(*dropTimeout).
+			WithConfirmations(*confirmations).
+			WithStuckTimeout(*stuckTimeout).
+			WithBalanceReconciliation(*reconcileInterval).
+			WithAlertWebhook(*alertWebhook).
+			WithENSRefresh(*ensRefresh).
+			WithDenylistReload(*denylistReload)
+		if *tokenCache != "" {
+			if err := bp.LoadTokenCache(*tokenCache); err != nil {
+				L.L.Error("Loading token cache failed:", err.Error())
+			}
+		}
+		if *addressBook != "" {
+			if err := bp.LoadAddressBook(*addressBook); err != nil {
+				L.L.Error("Loading address book failed:", err.Error())
+			}
+		}
+		for _, file := range strings.Split(*denylistFiles, ",") {
+			if file == "" {
+				continue
+			}
+			if err := bp.LoadDenylist(file); err != nil {
+				L.L.Error("Loading denylist failed:", err.Error())
+			}
+		}
+		if *abiDir != "" {
+			if err := bp.LoadABIs(*abiDir); err != nil {
+				L.L.Error("Loading ABIs failed:", err.Error())
+			}
+		}
+		for _, file := range strings.Split(*sigFiles, ",") {
+			if file == "" {
+				continue
+			}
+			if err := bp.LoadSignatures(file); err != nil {
+				L.L.Error("Loading signatures failed:", err.Error())
+			}
+		}
+	})
 	// Starts the service.
 	svc.Start()
 
@@ -0,0 +1,105 @@
+// Package keccak implements the legacy Keccak-256 hash used by Ethereum.
+//
+// It differs from SHA3-256 only in the padding byte (0x01 instead of 0x06).
+package keccak
+
+import (
+	"encoding/hex"
+	"math/bits"
+)
+
+const rate = 136 // (1600 - 2*256) / 8
+
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+var rotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// Sum256 returns Keccak-256 digest of data.
+func Sum256(data ...[]byte) [32]byte {
+	var msg []byte
+	for _, d := range data {
+		msg = append(msg, d...)
+	}
+
+	// pad10*1 with the Keccak domain byte
+	padded := make([]byte, len(msg)+rate-len(msg)%rate)
+	copy(padded, msg)
+	padded[len(msg)] ^= 0x01
+	padded[len(padded)-1] ^= 0x80
+
+	var state [25]uint64
+	for off := 0; off < len(padded); off += rate {
+		for i := 0; i < rate/8; i++ {
+			state[i] ^= le64(padded[off+8*i:])
+		}
+		permute(&state)
+	}
+
+	var out [32]byte
+	for i := 0; i < 4; i++ {
+		v := state[i]
+		for j := 0; j < 8; j++ {
+			out[8*i+j] = byte(v >> (8 * j))
+		}
+	}
+	return out
+}
+
+// Hex returns `0x` prefixed hex encoded Keccak-256 digest of data.
+func Hex(data []byte) string {
+	sum := Sum256(data)
+	return "0x" + hex.EncodeToString(sum[:])
+}
+
+func le64(b []byte) uint64 {
+	var v uint64
+	for i := 7; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// permute applies Keccak-f[1600] to the state.
+func permute(a *[25]uint64) {
+	var c [5]uint64
+	var b [25]uint64
+	for round := 0; round < 24; round++ {
+		// theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[y+x] ^= d
+			}
+		}
+		// rho and pi
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], rotations[x+5*y])
+			}
+		}
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[y+x] = b[y+x] ^ (^b[y+(x+1)%5] & b[y+(x+2)%5])
+			}
+		}
+		// iota
+		a[0] ^= roundConstants[round]
+	}
+}
@@ -0,0 +1,25 @@
+package keccak
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHex(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "0xc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{"Transfer(address,address,uint256)", "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+		{"transfer(address,uint256)", "0xa9059cbb2ab09eb219583f4a59a5d0623ade346d962bcd4e46b11da047c9049b"},
+		// longer than a single block
+		{strings.Repeat("a", 200), "0x96ea54061def936c4be90b518992fdc6f12f535068a256229aca54267b4d084d"},
+	}
+
+	for _, test := range tests {
+		if got := Hex([]byte(test.input)); got != test.want {
+			t.Errorf("Hex(%q) = %s; want %s", test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package abi parses Solidity contract ABIs and decodes calldata and event logs with them.
+package abi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"ethTx/cmd/util/keccak"
+	"fmt"
+	"strings"
+)
+
+// Argument is a single input or output of ABI entry as found in ABI JSON
+type Argument struct {
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	Indexed    bool       `json:"indexed,omitempty"`
+	Components []Argument `json:"components,omitempty"`
+}
+
+type entry struct {
+	Type      string     `json:"type"`
+	Name      string     `json:"name"`
+	Inputs    []Argument `json:"inputs"`
+	Anonymous bool       `json:"anonymous"`
+}
+
+// Method is a contract function identified by its 4 byte selector
+type Method struct {
+	Name      string
+	Signature string // canonical signature, e.g. transfer(address,uint256)
+	Selector  string // 0x prefixed first 4 bytes of keccak(Signature)
+	types     []Type
+	names     []string
+}
+
+// Event is a contract event identified by its topic
+type Event struct {
+	Name      string
+	Signature string // canonical signature, e.g. Transfer(address,address,uint256)
+	Topic     string // 0x prefixed keccak(Signature)
+	Anonymous bool
+	types     []Type
+	names     []string
+	indexed   []bool
+}
+
+// ABI holds the methods and events of a single contract
+type ABI struct {
+	Methods map[string]Method // by selector
+	Events  map[string]Event  // by topic, anonymous events are not included
+}
+
+// DecodedEvent is decoded event log
+type DecodedEvent struct {
+	Name      string  `json:"name"`
+	Signature string  `json:"signature"`
+	Params    []Value `json:"params"`
+	Guessed   bool    `json:"guessed,omitempty"` // decoded using signature table instead of contract ABI
+}
+
+// Call is decoded calldata
+type Call struct {
+	Method    string  `json:"method"`
+	Signature string  `json:"signature"`
+	Args      []Value `json:"args"`
+	Guessed   bool    `json:"guessed,omitempty"` // decoded using selector table instead of contract ABI
+}
+
+// Parse parses contract ABI JSON.
+func Parse(data []byte) (*ABI, error) {
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// artifacts produced by hardhat, truffle, etherscan... wrap the ABI
+		var artifact struct {
+			ABI []entry `json:"abi"`
+		}
+		if errArtifact := json.Unmarshal(data, &artifact); errArtifact != nil || artifact.ABI == nil {
+			return nil, fmt.Errorf("invalid ABI: %w", err)
+		}
+		entries = artifact.ABI
+	}
+
+	a := &ABI{Methods: make(map[string]Method), Events: make(map[string]Event)}
+	for _, e := range entries {
+		switch e.Type {
+		case "function", "":
+			m, err := newMethod(e.Name, e.Inputs)
+			if err != nil {
+				return nil, fmt.Errorf("method %s: %w", e.Name, err)
+			}
+			a.Methods[m.Selector] = m
+		case "event":
+			ev, err := NewEvent(e.Name, e.Inputs, e.Anonymous)
+			if err != nil {
+				return nil, fmt.Errorf("event %s: %w", e.Name, err)
+			}
+			if !ev.Anonymous {
+				a.Events[ev.Topic] = ev
+			}
+		}
+	}
+	return a, nil
+}
+
+// ParseSignature creates a method with unnamed arguments from its signature, e.g. transfer(address,uint256).
+func ParseSignature(signature string) (Method, error) {
+	name, args, err := splitSignature(signature)
+	if err != nil {
+		return Method{}, err
+	}
+	return newMethod(name, args)
+}
+
+// ParseEventSignature creates an event with unnamed parameters from its signature,
+// e.g. Transfer(address,address,uint256). The first `indexed` parameters are treated as indexed.
+func ParseEventSignature(signature string, indexed int) (Event, error) {
+	name, args, err := splitSignature(signature)
+	if err != nil {
+		return Event{}, err
+	}
+	if indexed > len(args) {
+		return Event{}, fmt.Errorf("%s has only %d parameters, %d indexed requested", signature, len(args), indexed)
+	}
+	for i := 0; i < indexed; i++ {
+		args[i].Indexed = true
+	}
+	return NewEvent(name, args, false)
+}
+
+func splitSignature(signature string) (string, []Argument, error) {
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return "", nil, fmt.Errorf("invalid signature %q", signature)
+	}
+	types, err := splitTypes(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return "", nil, err
+	}
+	args := make([]Argument, 0, len(types))
+	for _, t := range types {
+		args = append(args, Argument{Type: t})
+	}
+	return signature[:open], args, nil
+}
+
+func newMethod(name string, inputs []Argument) (Method, error) {
+	m := Method{Name: name}
+	strs := make([]string, 0, len(inputs))
+	for _, in := range inputs {
+		t, err := NewType(in.Type, in.Components)
+		if err != nil {
+			return Method{}, err
+		}
+		m.types = append(m.types, t)
+		m.names = append(m.names, in.Name)
+		strs = append(strs, t.str)
+	}
+	m.Signature = name + "(" + strings.Join(strs, ",") + ")"
+	m.Selector = keccak.Hex([]byte(m.Signature))[:10]
+	return m, nil
+}
+
+// NewEvent creates an event from its inputs.
+func NewEvent(name string, inputs []Argument, anonymous bool) (Event, error) {
+	ev := Event{Name: name, Anonymous: anonymous}
+	strs := make([]string, 0, len(inputs))
+	for _, in := range inputs {
+		t, err := NewType(in.Type, in.Components)
+		if err != nil {
+			return Event{}, err
+		}
+		ev.types = append(ev.types, t)
+		ev.names = append(ev.names, in.Name)
+		ev.indexed = append(ev.indexed, in.Indexed)
+		strs = append(strs, t.str)
+	}
+	ev.Signature = name + "(" + strings.Join(strs, ",") + ")"
+	ev.Topic = keccak.Hex([]byte(ev.Signature))
+	return ev, nil
+}
+
+// DecodeLog decodes event log emitted by the contract.
+func (a *ABI) DecodeLog(topics [][]byte, data []byte) (*DecodedEvent, error) {
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("log has no topics")
+	}
+	topic := "0x" + hex.EncodeToString(topics[0])
+	ev, ok := a.Events[topic]
+	if !ok {
+		return nil, fmt.Errorf("unknown event topic %s", topic)
+	}
+	return ev.Decode(topics, data)
+}
+
+// Decode decodes event log. Topics of non-anonymous events start with the event topic.
+//
+// Indexed parameters of dynamic types are returned as the keccak hash of their value.
+func (ev Event) Decode(topics [][]byte, data []byte) (*DecodedEvent, error) {
+	if !ev.Anonymous {
+		if len(topics) == 0 {
+			return nil, fmt.Errorf("log has no topics")
+		}
+		topics = topics[1:]
+	}
+
+	var dataTypes []Type
+	var dataNames []string
+	indexedCount := 0
+	for i, t := range ev.types {
+		if ev.indexed[i] {
+			indexedCount++
+			continue
+		}
+		dataTypes = append(dataTypes, t)
+		dataNames = append(dataNames, ev.names[i])
+	}
+	if indexedCount != len(topics) {
+		return nil, fmt.Errorf("%s: expected %d indexed topics, got %d", ev.Signature, indexedCount, len(topics))
+	}
+
+	dataValues, err := decodeArguments(dataTypes, dataNames, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ev.Signature, err)
+	}
+
+	params := make([]Value, 0, len(ev.types))
+	for i, t := range ev.types {
+		if ev.indexed[i] {
+			params = append(params, Value{Name: ev.names[i], Type: t.str, Value: DecodeTopic(t, topics[0]), Indexed: true})
+			topics = topics[1:]
+			continue
+		}
+		params = append(params, dataValues[0])
+		dataValues = dataValues[1:]
+	}
+	return &DecodedEvent{Name: ev.Name, Signature: ev.Signature, Params: params}, nil
+}
+
+// DecodeCall decodes calldata of a call to the contract.
+func (a *ABI) DecodeCall(input []byte) (*Call, error) {
+	if len(input) < 4 {
+		return nil, fmt.Errorf("calldata too short")
+	}
+	selector := "0x" + hex.EncodeToString(input[:4])
+	m, ok := a.Methods[selector]
+	if !ok {
+		return nil, fmt.Errorf("unknown selector %s", selector)
+	}
+	return m.Decode(input)
+}
+
+// Decode decodes calldata (selector included) of the method.
+func (m Method) Decode(input []byte) (*Call, error) {
+	if len(input) < 4 {
+		return nil, fmt.Errorf("calldata too short")
+	}
+	args, err := decodeArguments(m.types, m.names, input[4:])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Signature, err)
+	}
+	return &Call{Method: m.Name, Signature: m.Signature, Args: args}, nil
+}
@@ -0,0 +1,213 @@
+package abi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+const executeABI = `[
+    {"type": "constructor", "inputs": [{"name": "owner", "type": "address"}]},
+    {"type": "event", "name": "Executed", "inputs": [{"name": "to", "type": "address", "indexed": true}]},
+    {
+        "type": "function",
+        "name": "execute",
+        "inputs": [
+            {"name": "to", "type": "address"},
+            {"name": "value", "type": "uint256"},
+            {"name": "data", "type": "bytes"},
+            {"name": "items", "type": "tuple[]", "components": [
+                {"name": "account", "type": "address"},
+                {"name": "amount", "type": "uint256"}
+            ]},
+            {"name": "note", "type": "string"},
+            {"name": "delta", "type": "int8"}
+        ]
+    }
+]`
+
+// execute(0x3a10..., 1 ether, 0xdeadbeef, [(0x3a10..., 1), (0x98c3..., 2)], "hello", -5)
+const executeArgs = "0000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f0000000000000000000000000000000000000000000000000de0b6b3a764000000000000000000000000000000000000000000000000000000000000000000c0000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000001a0fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffb0000000000000000000000000000000000000000000000000000000000000004deadbeef0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000020000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f000000000000000000000000000000000000000000000000000000000000000100000000000000000000000098c3d3183c4b8a650614ad179a1a98be0a8d6b8e0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000568656c6c6f000000000000000000000000000000000000000000000000000000"
+
+func TestParse(t *testing.T) {
+	a, err := Parse([]byte(executeABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Methods) != 1 {
+		t.Fatalf("expected 1 method, got %d", len(a.Methods))
+	}
+	m, ok := a.Methods["0x751ccc90"]
+	if !ok {
+		t.Fatalf("execute selector not found in %v", a.Methods)
+	}
+	if m.Signature != "execute(address,uint256,bytes,(address,uint256)[],string,int8)" {
+		t.Errorf("unexpected signature %s", m.Signature)
+	}
+
+	// hardhat artifact
+	if _, err := Parse([]byte(`{"contractName": "X", "abi": ` + executeABI + `}`)); err != nil {
+		t.Error("artifact should be accepted:", err)
+	}
+	if _, err := Parse([]byte(`{"foo": 1}`)); err == nil {
+		t.Error("invalid ABI should be rejected")
+	}
+}
+
+func TestABI_DecodeCall(t *testing.T) {
+	a, err := Parse([]byte(executeABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, _ := hex.DecodeString("751ccc90" + executeArgs)
+	call, err := a.DecodeCall(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := json.Marshal(call)
+	want := `{"method":"execute","signature":"execute(address,uint256,bytes,(address,uint256)[],string,int8)","args":[` +
+		`{"name":"to","type":"address","value":"0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f"},` +
+		`{"name":"value","type":"uint256","value":"1000000000000000000"},` +
+		`{"name":"data","type":"bytes","value":"0xdeadbeef"},` +
+		`{"name":"items","type":"(address,uint256)[]","value":[{"account":"0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f","amount":"1"},{"account":"0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e","amount":"2"}]},` +
+		`{"name":"note","type":"string","value":"hello"},` +
+		`{"name":"delta","type":"int8","value":"-5"}]}`
+	if string(got) != want {
+		t.Errorf("DecodeCall() =\n%s\nwant\n%s", got, want)
+	}
+
+	// truncated calldata
+	if _, err := a.DecodeCall(input[:100]); err == nil {
+		t.Error("truncated calldata should fail")
+	}
+	// unknown selector
+	if _, err := a.DecodeCall([]byte{1, 2, 3, 4}); err == nil {
+		t.Error("unknown selector should fail")
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	m, err := ParseSignature("transfer(address,uint)")
+	if err != nil || m.Signature != "transfer(address,uint256)" || m.Selector != "0xa9059cbb" {
+		t.Fatalf("ParseSignature(transfer(address,uint)) = %v, %v", m, err)
+	}
+
+	input, _ := hex.DecodeString("a9059cbb" +
+		"0000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f" +
+		"00000000000000000000000000000000000000000000000000000000000003e8")
+	call, err := m.Decode(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Method != "transfer" || call.Args[1].Value != "1000" {
+		t.Errorf("unexpected call %+v", call)
+	}
+}
+
+func TestNewType(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   bool
+	}{
+		{input: "uint", want: "uint256"},
+		{input: "int", want: "int256"},
+		{input: "bytes32", want: "bytes32"},
+		{input: "address[2][]", want: "address[2][]"},
+		{input: "(uint256,(address,bytes)[])[3]", want: "(uint256,(address,bytes)[])[3]"},
+		{input: "uint7", err: true},
+		{input: "bytes33", err: true},
+		{input: "foo", err: true},
+		{input: "(uint256", err: true},
+	}
+
+	for _, test := range tests {
+		got, err := NewType(test.input, nil)
+		if (err != nil) != test.err || (err == nil && got.String() != test.want) {
+			t.Errorf("NewType(%q) = %v, %v; want %v (error %v)", test.input, got, err, test.want, test.err)
+		}
+	}
+}
+
+const noteABI = `[
+    {"type": "event", "name": "Noted", "inputs": [
+        {"name": "author", "type": "address", "indexed": true},
+        {"name": "id", "type": "uint256", "indexed": false},
+        {"name": "tag", "type": "string", "indexed": true},
+        {"name": "text", "type": "string", "indexed": false}
+    ]},
+    {"type": "event", "name": "Anon", "anonymous": true, "inputs": []}
+]`
+
+func TestABI_DecodeLog(t *testing.T) {
+	a, err := Parse([]byte(noteABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Events) != 1 {
+		t.Fatalf("expected 1 non-anonymous event, got %d", len(a.Events))
+	}
+
+	var ev Event
+	for _, e := range a.Events {
+		ev = e
+	}
+	if ev.Signature != "Noted(address,uint256,string,string)" {
+		t.Fatalf("unexpected signature %s", ev.Signature)
+	}
+
+	topic0, _ := hex.DecodeString(ev.Topic[2:])
+	author, _ := hex.DecodeString("0000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f")
+	tag, _ := hex.DecodeString("1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8")
+	data, _ := hex.DecodeString(
+		"0000000000000000000000000000000000000000000000000000000000000007" +
+			"0000000000000000000000000000000000000000000000000000000000000040" +
+			"0000000000000000000000000000000000000000000000000000000000000002" +
+			"6869000000000000000000000000000000000000000000000000000000000000")
+
+	event, err := a.DecodeLog([][]byte{topic0, author, tag}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := json.Marshal(event)
+	want := `{"name":"Noted","signature":"Noted(address,uint256,string,string)","params":[` +
+		`{"name":"author","type":"address","value":"0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f","indexed":true},` +
+		`{"name":"id","type":"uint256","value":"7"},` +
+		`{"name":"tag","type":"string","value":"0x1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8","indexed":true},` +
+		`{"name":"text","type":"string","value":"hi"}]}`
+	if string(got) != want {
+		t.Errorf("DecodeLog() =\n%s\nwant\n%s", got, want)
+	}
+
+	// topic count does not match indexed parameters
+	if _, err := a.DecodeLog([][]byte{topic0, author}, data); err == nil {
+		t.Error("missing topic should fail")
+	}
+}
+
+func TestParseEventSignature(t *testing.T) {
+	ev, err := ParseEventSignature("Transfer(address,address,uint256)", 2)
+	if err != nil || ev.Topic != "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef" {
+		t.Fatalf("ParseEventSignature() = %v, %v", ev, err)
+	}
+
+	topic0, _ := hex.DecodeString(ev.Topic[2:])
+	from, _ := hex.DecodeString("00000000000000000000000098c3d3183c4b8a650614ad179a1a98be0a8d6b8e")
+	to, _ := hex.DecodeString("0000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f")
+	data, _ := hex.DecodeString("00000000000000000000000000000000000000000000000000000000000003e8")
+
+	event, err := ev.Decode([][]byte{topic0, from, to}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !event.Params[0].Indexed || event.Params[1].Value != "0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f" || event.Params[2].Value != "1000" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	if _, err := ParseEventSignature("Transfer(address)", 2); err == nil {
+		t.Error("more indexed parameters than parameters should fail")
+	}
+}
@@ -0,0 +1,178 @@
+package abi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"unicode/utf8"
+)
+
+// maxLength caps lengths read from untrusted data
+const maxLength = 1 << 20
+
+// Value is a decoded argument
+type Value struct {
+	Name    string      `json:"name,omitempty"`
+	Type    string      `json:"type"`
+	Value   interface{} `json:"value"`
+	Indexed bool        `json:"indexed,omitempty"` // event parameter stored in topics
+}
+
+// decodeArguments decodes ABI encoded tuple of the given types.
+func decodeArguments(types []Type, names []string, data []byte) ([]Value, error) {
+	values, err := decodeTuple(types, data, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Value, len(values))
+	for i, v := range values {
+		out[i] = Value{Name: names[i], Type: types[i].str, Value: v}
+	}
+	return out, nil
+}
+
+// decodeTuple decodes the members of a tuple encoded at data[base:].
+func decodeTuple(types []Type, data []byte, base int) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(types))
+	pos := base
+	for _, t := range types {
+		at := pos
+		if t.isDynamic() {
+			offset, err := readLength(data, pos)
+			if err != nil {
+				return nil, err
+			}
+			at = base + offset
+		}
+		v, err := decodeValue(t, data, at)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		pos += t.headSize()
+	}
+	return values, nil
+}
+
+// decodeValue decodes a single value of type t located at data[at:].
+func decodeValue(t Type, data []byte, at int) (interface{}, error) {
+	switch t.kind {
+	case kindUint, kindInt, kindAddress, kindBool, kindFixedBytes:
+		word, err := readWord(data, at)
+		if err != nil {
+			return nil, err
+		}
+		return decodeWord(t, word), nil
+
+	case kindBytes, kindString:
+		length, err := readLength(data, at)
+		if err != nil {
+			return nil, err
+		}
+		if at+32+length > len(data) {
+			return nil, fmt.Errorf("%s exceeds data length", t.str)
+		}
+		b := data[at+32 : at+32+length]
+		if t.kind == kindString && utf8.Valid(b) {
+			return string(b), nil
+		}
+		return "0x" + hex.EncodeToString(b), nil
+
+	case kindSlice:
+		length, err := readLength(data, at)
+		if err != nil {
+			return nil, err
+		}
+		if at+32+length*t.elem.headSize() > len(data) {
+			return nil, fmt.Errorf("%s exceeds data length", t.str)
+		}
+		return decodeTuple(repeat(*t.elem, length), data, at+32)
+
+	case kindArray:
+		return decodeTuple(repeat(*t.elem, t.size), data, at)
+
+	case kindTuple:
+		values, err := decodeTuple(t.components, data, at)
+		if err != nil {
+			return nil, err
+		}
+		return namedTuple(t.names, values), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t.str)
+}
+
+// decodeWord decodes a static value occupying a single 32 byte word.
+func decodeWord(t Type, word []byte) interface{} {
+	switch t.kind {
+	case kindUint:
+		return new(big.Int).SetBytes(word).String()
+	case kindInt:
+		n := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return n.String()
+	case kindAddress:
+		return "0x" + hex.EncodeToString(word[12:])
+	case kindBool:
+		return word[31] != 0
+	case kindFixedBytes:
+		return "0x" + hex.EncodeToString(word[:t.size])
+	}
+	return nil
+}
+
+// DecodeTopic decodes an indexed event parameter.
+//
+// Dynamic types are stored as keccak hash of their value, which is returned as is.
+func DecodeTopic(t Type, topic []byte) interface{} {
+	if len(topic) != 32 {
+		return nil
+	}
+	switch t.kind {
+	case kindUint, kindInt, kindAddress, kindBool, kindFixedBytes:
+		return decodeWord(t, topic)
+	}
+	return "0x" + hex.EncodeToString(topic)
+}
+
+func namedTuple(names []string, values []interface{}) interface{} {
+	for _, n := range names {
+		if n == "" {
+			return values
+		}
+	}
+	m := make(map[string]interface{}, len(values))
+	for i, v := range values {
+		m[names[i]] = v
+	}
+	return m
+}
+
+func repeat(t Type, n int) []Type {
+	types := make([]Type, n)
+	for i := range types {
+		types[i] = t
+	}
+	return types
+}
+
+func readWord(data []byte, at int) ([]byte, error) {
+	if at < 0 || at+32 > len(data) {
+		return nil, fmt.Errorf("unexpected end of data at %d", at)
+	}
+	return data[at : at+32], nil
+}
+
+// readLength reads a word used as offset or length.
+func readLength(data []byte, at int) (int, error) {
+	word, err := readWord(data, at)
+	if err != nil {
+		return 0, err
+	}
+	n := new(big.Int).SetBytes(word)
+	if !n.IsInt64() || n.Int64() > maxLength {
+		return 0, fmt.Errorf("length %s at %d is too large", n.String(), at)
+	}
+	return int(n.Int64()), nil
+}
@@ -0,0 +1,188 @@
+package abi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// kind of an ABI type
+const (
+	kindUint = iota
+	kindInt
+	kindAddress
+	kindBool
+	kindFixedBytes
+	kindBytes
+	kindString
+	kindSlice // T[]
+	kindArray // T[k]
+	kindTuple
+)
+
+// Type is a parsed Solidity ABI type
+type Type struct {
+	kind       int
+	size       int    // bits of (u)int, length of bytesN and T[k]
+	elem       *Type  // element of T[] and T[k]
+	components []Type // tuple members
+	names      []string
+	str        string // canonical type, e.g. (address,uint256)[]
+}
+
+// String returns canonical representation of the type as used in signatures.
+func (t Type) String() string {
+	return t.str
+}
+
+// NewType parses type string. Tuple members are taken from components.
+func NewType(typ string, components []Argument) (Type, error) {
+	// arrays, innermost dimension is the last one
+	if strings.HasSuffix(typ, "]") {
+		open := strings.LastIndex(typ, "[")
+		if open < 0 {
+			return Type{}, fmt.Errorf("invalid type %q", typ)
+		}
+		elem, err := NewType(typ[:open], components)
+		if err != nil {
+			return Type{}, err
+		}
+		dim := typ[open+1 : len(typ)-1]
+		if dim == "" {
+			return Type{kind: kindSlice, elem: &elem, str: elem.str + "[]"}, nil
+		}
+		n, err := strconv.Atoi(dim)
+		if err != nil || n <= 0 {
+			return Type{}, fmt.Errorf("invalid array length in %q", typ)
+		}
+		return Type{kind: kindArray, size: n, elem: &elem, str: elem.str + "[" + dim + "]"}, nil
+	}
+
+	switch {
+	case typ == "tuple":
+		t := Type{kind: kindTuple}
+		strs := make([]string, 0, len(components))
+		for _, c := range components {
+			ct, err := NewType(c.Type, c.Components)
+			if err != nil {
+				return Type{}, err
+			}
+			t.components = append(t.components, ct)
+			t.names = append(t.names, c.Name)
+			strs = append(strs, ct.str)
+		}
+		t.str = "(" + strings.Join(strs, ",") + ")"
+		return t, nil
+
+	case strings.HasPrefix(typ, "(") && strings.HasSuffix(typ, ")"):
+		// tuple written inline, as in human readable signatures
+		members, err := splitTypes(typ[1 : len(typ)-1])
+		if err != nil {
+			return Type{}, err
+		}
+		args := make([]Argument, 0, len(members))
+		for _, m := range members {
+			args = append(args, Argument{Type: m})
+		}
+		return NewType("tuple", args)
+
+	case typ == "address":
+		return Type{kind: kindAddress, size: 160, str: typ}, nil
+	case typ == "bool":
+		return Type{kind: kindBool, str: typ}, nil
+	case typ == "string":
+		return Type{kind: kindString, str: typ}, nil
+	case typ == "bytes":
+		return Type{kind: kindBytes, str: typ}, nil
+	case typ == "function":
+		// address + selector
+		return Type{kind: kindFixedBytes, size: 24, str: typ}, nil
+
+	case strings.HasPrefix(typ, "bytes"):
+		n, err := strconv.Atoi(typ[len("bytes"):])
+		if err != nil || n < 1 || n > 32 {
+			return Type{}, fmt.Errorf("invalid type %q", typ)
+		}
+		return Type{kind: kindFixedBytes, size: n, str: typ}, nil
+
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		kind, prefix := kindInt, "int"
+		if strings.HasPrefix(typ, "uint") {
+			kind, prefix = kindUint, "uint"
+		}
+		bits := 256
+		if len(typ) > len(prefix) {
+			n, err := strconv.Atoi(typ[len(prefix):])
+			if err != nil || n < 8 || n > 256 || n%8 != 0 {
+				return Type{}, fmt.Errorf("invalid type %q", typ)
+			}
+			bits = n
+		}
+		return Type{kind: kind, size: bits, str: prefix + strconv.Itoa(bits)}, nil
+	}
+	return Type{}, fmt.Errorf("unsupported type %q", typ)
+}
+
+// isDynamic reports whether the type is encoded in the tail section.
+func (t Type) isDynamic() bool {
+	switch t.kind {
+	case kindBytes, kindString, kindSlice:
+		return true
+	case kindArray:
+		return t.elem.isDynamic()
+	case kindTuple:
+		for _, c := range t.components {
+			if c.isDynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize returns number of bytes the type occupies in the head section.
+func (t Type) headSize() int {
+	if t.isDynamic() {
+		return 32
+	}
+	switch t.kind {
+	case kindArray:
+		return t.size * t.elem.headSize()
+	case kindTuple:
+		size := 0
+		for _, c := range t.components {
+			size += c.headSize()
+		}
+		return size
+	}
+	return 32
+}
+
+// splitTypes splits comma separated type list respecting nested parentheses.
+func splitTypes(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var types []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in %q", s)
+			}
+		case ',':
+			if depth == 0 {
+				types = append(types, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in %q", s)
+	}
+	return append(types, strings.TrimSpace(s[start:])), nil
+}
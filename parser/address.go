@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"encoding/hex"
+	"ethTx/cmd/util/keccak"
+	"strings"
+)
+
+// zeroAddress is used by contracts and events for "no address"
+const zeroAddress = "0x0000000000000000000000000000000000000000"
+
+// normalizeAddress returns the lowercase form of the address, which is used as the key by storage.
+func normalizeAddress(address string) string {
+	return strings.ToLower(address)
+}
+
+// ChecksumAddress returns EIP-55 mixed case checksum encoding of the address.
+//
+// Strings that are not 20 byte hex addresses are returned unchanged.
+func ChecksumAddress(address string) string {
+	if len(address) != 42 || (address[:2] != "0x" && address[:2] != "0X") {
+		return address
+	}
+	lower := strings.ToLower(address[2:])
+	if _, err := hex.DecodeString(lower); err != nil {
+		return address
+	}
+
+	hash := keccak.Sum256([]byte(lower))
+	out := []byte(lower)
+	for i, c := range out {
+		if c < 'a' {
+			continue
+		}
+		// nibble i of the hash decides the case of character i
+		nibble := hash[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+		if nibble&0xf >= 8 {
+			out[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out)
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Label is an address book entry describing a known address, e.g. an exchange or own treasury
+type Label struct {
+	Address string   `json:"address"`
+	Label   string   `json:"label"`
+	Tags    []string `json:"tags,omitempty"` // e.g. `exchange`, `treasury`, `bridge`
+}
+
+// LoadAddressBook loads labels stored in the file and keeps the file updated with changes of the address book.
+//
+// A missing file is not an error, it is created with the first change.
+func (bp *BlockParser) LoadAddressBook(path string) error {
+	bp.addressBook = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var labels []Label
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for i, l := range labels {
+		if labels[i], err = normalizeLabel(l); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	for _, l := range labels {
+		bp.store.StoreLabel(l)
+	}
+	L.L.Info("Loaded", fmt.Sprintf("%d", len(labels)), "labels from", path)
+	return nil
+}
+
+// saveAddressBook writes all labels to the address book file. Caller must hold bp.mu.
+func (bp *BlockParser) saveAddressBook() {
+	if bp.addressBook == "" {
+		return
+	}
+	data, err := json.MarshalIndent(bp.store.Labels(), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(bp.addressBook, data)
+	}
+	if err != nil {
+		L.L.Error("Saving address book failed:", err.Error())
+	}
+}
+
+// normalizeLabel validates the entry, lowercases its address and drops empty and duplicate tags.
+func normalizeLabel(l Label) (Label, error) {
+	if !validAddress(l.Address) {
+		return Label{}, fmt.Errorf("address %s is not valid hex number", l.Address)
+	}
+	l.Address = normalizeAddress(l.Address)
+	l.Label = strings.TrimSpace(l.Label)
+	if l.Label == "" {
+		return Label{}, fmt.Errorf("label of %s is empty", l.Address)
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range l.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	l.Tags = tags
+	return l, nil
+}
+
+// SetLabel adds the address book entry or replaces the existing entry of its address.
+func (bp *BlockParser) SetLabel(l Label) (Label, error) {
+	l, err := normalizeLabel(l)
+	if err != nil {
+		return Label{}, err
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.store.StoreLabel(l)
+	bp.saveAddressBook()
+	L.L.Info("Address", l.Address, "labeled as", l.Label)
+	return l, nil
+}
+
+// GetLabel returns the address book entry of the address.
+func (bp *BlockParser) GetLabel(address string) (Label, bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.store.Label(normalizeAddress(address))
+}
+
+// GetLabels returns all address book entries ordered by address.
+func (bp *BlockParser) GetLabels() []Label {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.store.Labels()
+}
+
+// RemoveLabel removes the address book entry of the address. It returns false when there was none.
+func (bp *BlockParser) RemoveLabel(address string) bool {
+	address = normalizeAddress(address)
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if _, ok := bp.store.Label(address); !ok {
+		return false
+	}
+	bp.store.RemoveLabel(address)
+	bp.saveAddressBook()
+	L.L.Info("Label of", address, "removed")
+	return true
+}
+
+// ImportLabels adds or replaces address book entries in bulk. Nothing is imported when any entry is invalid.
+func (bp *BlockParser) ImportLabels(labels []Label) (int, error) {
+	for i, l := range labels {
+		var err error
+		if labels[i], err = normalizeLabel(l); err != nil {
+			return 0, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	for _, l := range labels {
+		bp.store.StoreLabel(l)
+	}
+	bp.saveAddressBook()
+	L.L.Info("Imported", fmt.Sprintf("%d", len(labels)), "labels")
+	return len(labels), nil
+}
+
+// ParseLabelsCSV reads address book entries exported from a spreadsheet. Columns are address, label and
+// optional tags separated by `;`. A header row starting with `address` is skipped.
+func ParseLabelsCSV(r io.Reader) ([]Label, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []Label
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected address and label", i+1)
+		}
+		l := Label{Address: strings.TrimSpace(record[0]), Label: record[1]}
+		if len(record) > 2 {
+			l.Tags = strings.Split(record[2], ";")
+		}
+		labels = append(labels, l)
+	}
+	return labels, nil
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const treasury = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+func TestParseLabelsCSV(t *testing.T) {
+	csv := "address,label,tags\n" +
+		treasury + ",Treasury,own;multisig\n" +
+		callWallet + ", Binance 14 ,exchange\n" +
+		lifecycleRecipient + ",Bridge\n"
+	labels, err := ParseLabelsCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal("Failed parsing labels", err.Error())
+	}
+	want := []Label{
+		{Address: treasury, Label: "Treasury", Tags: []string{"own", "multisig"}},
+		{Address: callWallet, Label: "Binance 14 ", Tags: []string{"exchange"}},
+		{Address: lifecycleRecipient, Label: "Bridge"},
+	}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("ParseLabelsCSV() = %+v, want %+v", labels, want)
+	}
+
+	if _, err := ParseLabelsCSV(strings.NewReader(treasury + "\n")); err == nil {
+		t.Error("row without label accepted")
+	}
+}
+
+func TestBlockParser_addressBook(t *testing.T) {
+	logging.Init("debug")
+
+	path := filepath.Join(t.TempDir(), "labels.json")
+	bp := &BlockParser{store: NewTransactionStorage()}
+	if err := bp.LoadAddressBook(path); err != nil {
+		t.Fatal("missing address book file should not fail", err.Error())
+	}
+
+	l, err := bp.SetLabel(Label{Address: treasury, Label: " Treasury ", Tags: []string{"own", "", "own"}})
+	if err != nil || l.Address != strings.ToLower(treasury) || l.Label != "Treasury" || !reflect.DeepEqual(l.Tags, []string{"own"}) {
+		t.Fatalf("SetLabel() = %+v, %v", l, err)
+	}
+	if _, err := bp.SetLabel(Label{Address: callWallet}); err == nil {
+		t.Error("empty label accepted")
+	}
+
+	// import is rejected as a whole
+	if _, err := bp.ImportLabels([]Label{{Address: callWallet, Label: "Binance 14"}, {Address: "0x12", Label: "Bridge"}}); err == nil {
+		t.Error("invalid address imported")
+	}
+	if _, ok := bp.GetLabel(callWallet); ok {
+		t.Error("valid entry of rejected import was stored")
+	}
+	if n, err := bp.ImportLabels([]Label{{Address: callWallet, Label: "Binance 14"}, {Address: lifecycleRecipient, Label: "Bridge"}}); n != 2 || err != nil {
+		t.Fatalf("ImportLabels() = %d, %v", n, err)
+	}
+
+	if !bp.RemoveLabel(lifecycleRecipient) || bp.RemoveLabel(lifecycleRecipient) {
+		t.Error("unexpected result of removing label")
+	}
+
+	// changes are kept in the file
+	loaded := &BlockParser{store: NewTransactionStorage()}
+	if err := loaded.LoadAddressBook(path); err != nil {
+		t.Fatal("Failed loading address book", err.Error())
+	}
+	if got := loaded.GetLabels(); !reflect.DeepEqual(got, bp.GetLabels()) || len(got) != 2 {
+		t.Errorf("loaded labels %+v differ from %+v", got, bp.GetLabels())
+	}
+
+	bp.Subscribe(callWallet)
+	bp.store.StoreTransactions(callWallet, Transaction{Hash: "0xaa", From: strings.ToLower(treasury), To: callWallet})
+	if txs := bp.GetTransactions(callWallet); txs[0].FromLabel != "Treasury" || txs[0].ToLabel != "Binance 14" {
+		t.Errorf("unexpected labels of transaction %+v", txs[0])
+	}
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"time"
+)
+
+// Alert types
+const (
+	AlertNonceGap         = "nonce-gap"         // a missing nonce blocks later transactions of the sender
+	AlertStuckTransaction = "stuck-transaction" // transaction has been pending for too long
+)
+
+// Alert priorities
+const (
+	PriorityNormal = "normal"
+	PriorityHigh   = "high" // requires immediate attention, e.g. interaction with a sanctioned address
+)
+
+// Alert is a notable condition detected for a subscribed address
+type Alert struct {
+	ID          int       `json:"id"`
+	Type        string    `json:"type"`
+	Priority    string    `json:"priority"`         // PriorityNormal or PriorityHigh
+	RuleID      int       `json:"ruleId,omitempty"` // Alert rule that raised AlertRule alerts
+	Address     string    `json:"address"`
+	TxHash      string    `json:"txHash,omitempty"`
+	BlockNumber int       `json:"blockNumber,omitempty"`
+	Message     string    `json:"message"`
+	Time        time.Time `json:"time"`
+
+	key string // the same condition is raised only once
+}
+
+// raiseAlert stores the alert unless an alert with the same key was already raised. Caller must hold bp.mu.
+func (bp *BlockParser) raiseAlert(a Alert, key string) {
+	if bp.store.IsAlertRaised(key) {
+		return
+	}
+	a.ID = len(bp.store.Alerts()) + 1
+	if a.Priority == "" {
+		a.Priority = PriorityNormal
+	}
+	a.Time = time.Now().UTC()
+	a.key = key
+	L.L.Warn("Alert", a.Type, "for", a.Address+":", a.Message)
+	bp.store.StoreAlert(a)
+	if bp.alertWebhook != "" {
+		go bp.notify(a)
+	}
+}
+
+// GetAlerts returns alerts raised for the address, or all alerts when address is empty.
+func (bp *BlockParser) GetAlerts(address string) []Alert {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	address = normalizeAddress(address)
+	alerts := []Alert{}
+	for _, a := range bp.store.Alerts() {
+		if address == "" || a.Address == address {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
@@ -0,0 +1,152 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+)
+
+// Event signatures (topic0) of the supported approval events.
+const (
+	// Approval(address,address,uint256) - shared by ERC-20 and ERC-721, ERC-721 has the token id indexed
+	approvalTopic = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+	// ApprovalForAll(address,address,bool) - ERC-721 and ERC-1155
+	approvalForAllTopic = "0x17307eab39ab6107e8899845ad3d59bd9653f200f220920489ca2b5937696c31"
+)
+
+// Alert types raised for approvals granted by subscribed owners
+const (
+	AlertNewApproval       = "new-approval"       // spender was granted an allowance it did not have before
+	AlertUnlimitedApproval = "unlimited-approval" // spender may move all tokens of the owner
+)
+
+// unlimitedAllowance is the smallest allowance treated as unlimited. Besides max uint256 wallets and tokens
+// (e.g. UNI, COMP) use max uint96 as infinite approval.
+var unlimitedAllowance = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 96), big.NewInt(1))
+
+// Allowance is an approval currently granted by a subscribed owner to a spender
+type Allowance struct {
+	Standard    string `json:"standard"`         // ERC-20 for Approval, ERC-721 for ApprovalForAll (also used by ERC-1155)
+	Contract    string `json:"contract"`         // Token contract address
+	Spender     string `json:"spender"`          // Approved spender or operator
+	Amount      string `json:"amount,omitempty"` // Allowance in token base units as decimal string, empty for ApprovalForAll
+	Unlimited   bool   `json:"unlimited"`        // Spender may move all tokens of the owner
+	TxHash      string `json:"txHash,omitempty"` // Transaction that granted the approval
+	BlockNumber int    `json:"blockNumber"`
+
+	Token     *TokenMetadata `json:"token,omitempty"`     // Metadata of ERC-20 token when resolved
+	Formatted string         `json:"formatted,omitempty"` // Allowance in token units, e.g. `1.5 USDC`, or `unlimited`
+}
+
+// approval is a decoded Approval or ApprovalForAll event
+type approval struct {
+	owner     string
+	allowance Allowance
+	logIndex  int
+}
+
+// GetAllowances returns approvals currently granted by the address.
+//
+// Allowances are derived from approval events observed while the address was subscribed. ERC-20 allowances
+// spent by transferFrom without an Approval event are reported with the approved amount.
+func (bp *BlockParser) GetAllowances(address string) []Allowance {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	allowances := []Allowance{}
+	for _, a := range bp.store.Allowances(normalizeAddress(address)) {
+		if amount, ok := new(big.Int).SetString(a.Amount, 10); ok {
+			a.Token, a.Formatted = bp.formatTokenAmount(a.Contract, amount)
+		}
+		if a.Unlimited {
+			a.Formatted = "unlimited"
+		}
+		allowances = append(allowances, a)
+	}
+	return allowances
+}
+
+// approvalFromLog decodes ERC-20 Approval and ApprovalForAll logs. ERC-721 approvals of single tokens
+// are cleared with the next transfer of the token and yield false.
+func approvalFromLog(log map[string]interface{}) (approval, bool) {
+	if removed, _ := log["removed"].(bool); removed {
+		return approval{}, false
+	}
+	rawTopics, _ := log["topics"].([]interface{})
+	topics := make([]string, 0, len(rawTopics))
+	for _, t := range rawTopics {
+		s, _ := t.(string)
+		topics = append(topics, s)
+	}
+	if len(topics) != 3 {
+		return approval{}, false
+	}
+	data, _ := log["data"].(string)
+	words := dataWords(data)
+	if len(words) != 1 {
+		return approval{}, false
+	}
+
+	contract, _ := log["address"].(string)
+	txHash, _ := log["transactionHash"].(string)
+	logIndex, _ := log["logIndex"].(string)
+	blockNumber, _ := log["blockNumber"].(string)
+	a := approval{
+		owner:    topicToAddress(topics[1]),
+		logIndex: hexToInt(logIndex),
+		allowance: Allowance{
+			Contract:    normalizeAddress(contract),
+			Spender:     topicToAddress(topics[2]),
+			TxHash:      txHash,
+			BlockNumber: hexToInt(blockNumber),
+		},
+	}
+	value := hexToBig(words[0])
+	switch topics[0] {
+	case approvalTopic:
+		a.allowance.Standard = StandardERC20
+		a.allowance.Amount = value.String()
+		a.allowance.Unlimited = value.Cmp(unlimitedAllowance) >= 0
+	case approvalForAllTopic:
+		a.allowance.Standard = StandardERC721
+		a.allowance.Unlimited = value.Sign() != 0
+	default:
+		return approval{}, false
+	}
+	return a, true
+}
+
+// applyApproval updates allowance table of the owner and raises alerts for new and unlimited approvals.
+// Caller must hold bp.mu.
+func (bp *BlockParser) applyApproval(a approval) {
+	al := a.allowance
+	_, existed := bp.store.Allowance(a.owner, al.Contract, al.Spender)
+	if al.Amount == "0" || (al.Standard == StandardERC721 && !al.Unlimited) {
+		if existed {
+			L.L.Info("Approval of", al.Spender, "for", al.Contract, "revoked by", a.owner)
+			bp.store.RemoveAllowance(a.owner, al.Contract, al.Spender)
+		}
+		return
+	}
+	L.L.Info("New approval of", al.Spender, "for", al.Contract, "by", a.owner)
+	bp.store.StoreAllowance(a.owner, al)
+
+	key := fmt.Sprintf("%s/%s/%d", a.owner, al.TxHash, a.logIndex)
+	switch {
+	case al.Unlimited:
+		bp.raiseAlert(Alert{
+			Type:        AlertUnlimitedApproval,
+			Address:     a.owner,
+			TxHash:      al.TxHash,
+			BlockNumber: al.BlockNumber,
+			Message:     fmt.Sprintf("%s was approved to move all %s tokens of %s", al.Spender, al.Contract, a.owner),
+		}, AlertUnlimitedApproval+"/"+key)
+	case !existed:
+		bp.raiseAlert(Alert{
+			Type:        AlertNewApproval,
+			Address:     a.owner,
+			TxHash:      al.TxHash,
+			BlockNumber: al.BlockNumber,
+			Message:     fmt.Sprintf("%s was approved to move %s units of %s tokens of %s", al.Spender, al.Amount, al.Contract, a.owner),
+		}, AlertNewApproval+"/"+key)
+	}
+}
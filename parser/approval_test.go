@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"encoding/json"
+	"ethTx/cmd/util/logging"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const approvalSpender = "0x000000000022d473030f116ddee9f6b43ac78ba3"
+
+func approvalLog(topic0, owner, spender, value string, logIndex int) map[string]interface{} {
+	return map[string]interface{}{
+		"address":         callToken,
+		"topics":          []interface{}{topic0, "0x000000000000000000000000" + owner[2:], "0x000000000000000000000000" + spender[2:]},
+		"data":            "0x" + value,
+		"transactionHash": "0xaa",
+		"logIndex":        fmt.Sprintf("0x%x", logIndex),
+		"blockNumber":     "0xa",
+	}
+}
+
+const (
+	word0   = "0000000000000000000000000000000000000000000000000000000000000000"
+	word1   = "0000000000000000000000000000000000000000000000000000000000000001"
+	word100 = "0000000000000000000000000000000000000000000000000000000000000064"
+	wordMax = "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+)
+
+func TestApprovalFromLog(t *testing.T) {
+	a, ok := approvalFromLog(approvalLog(approvalTopic, callWallet, approvalSpender, wordMax, 1))
+	if !ok || a.owner != callWallet || a.allowance.Spender != approvalSpender || a.allowance.Standard != StandardERC20 || !a.allowance.Unlimited {
+		t.Errorf("unexpected unlimited approval %+v", a)
+	}
+	a, ok = approvalFromLog(approvalLog(approvalTopic, callWallet, approvalSpender, word100, 1))
+	if !ok || a.allowance.Amount != "100" || a.allowance.Unlimited {
+		t.Errorf("unexpected limited approval %+v", a)
+	}
+	a, ok = approvalFromLog(approvalLog(approvalForAllTopic, callWallet, approvalSpender, word1, 1))
+	if !ok || a.allowance.Standard != StandardERC721 || !a.allowance.Unlimited || a.allowance.Amount != "" {
+		t.Errorf("unexpected approval for all %+v", a)
+	}
+
+	// ERC-721 approval of a single token
+	single := approvalLog(approvalTopic, callWallet, approvalSpender, "", 1)
+	single["topics"] = append(single["topics"].([]interface{}), "0x"+word1)
+	if _, ok := approvalFromLog(single); ok {
+		t.Error("single token approval should be ignored")
+	}
+}
+
+func TestBlockParser_applyApproval(t *testing.T) {
+	logging.Init("debug")
+
+	bp := &BlockParser{store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+
+	apply := func(topic0, value string, logIndex int) {
+		a, _ := approvalFromLog(approvalLog(topic0, callWallet, approvalSpender, value, logIndex))
+		bp.mu.Lock()
+		bp.applyApproval(a)
+		bp.mu.Unlock()
+	}
+	alertTypes := func() []string {
+		var types []string
+		for _, a := range bp.GetAlerts(callWallet) {
+			types = append(types, a.Type)
+		}
+		return types
+	}
+
+	apply(approvalTopic, word100, 1)
+	if got := bp.GetAllowances(callWallet); len(got) != 1 || got[0].Amount != "100" {
+		t.Fatalf("unexpected allowances %+v", got)
+	}
+	// changing existing allowance is not new
+	apply(approvalTopic, word100, 2)
+	apply(approvalTopic, wordMax, 3)
+	if got := bp.GetAllowances(callWallet); len(got) != 1 || !got[0].Unlimited {
+		t.Errorf("unexpected allowances %+v", got)
+	}
+	apply(approvalTopic, word0, 4)
+	if got := bp.GetAllowances(callWallet); len(got) != 0 {
+		t.Errorf("revoked approval still listed %+v", got)
+	}
+
+	apply(approvalForAllTopic, word1, 5)
+	if got := bp.GetAllowances(callWallet); len(got) != 1 || got[0].Standard != StandardERC721 {
+		t.Errorf("unexpected allowances %+v", got)
+	}
+	apply(approvalForAllTopic, word0, 6)
+	if got := bp.GetAllowances(callWallet); len(got) != 0 {
+		t.Errorf("revoked approval still listed %+v", got)
+	}
+
+	want := []string{AlertNewApproval, AlertUnlimitedApproval, AlertUnlimitedApproval}
+	if got := alertTypes(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("alerts = %v; want %v", got, want)
+	}
+}
+
+func TestBlockParser_GetAllowances(t *testing.T) {
+	logging.Init("debug")
+
+	bp := &BlockParser{store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+	for _, log := range []map[string]interface{}{
+		approvalLog(approvalTopic, callWallet, approvalSpender, wordMax, 1),
+		approvalLog(approvalForAllTopic, callWallet, lifecycleRecipient, word1, 2),
+	} {
+		a, _ := approvalFromLog(log)
+		bp.mu.Lock()
+		bp.applyApproval(a)
+		bp.mu.Unlock()
+	}
+
+	got := bp.GetAllowances(callWallet)
+	if len(got) != 2 {
+		t.Fatalf("unexpected allowances %+v", got)
+	}
+	for _, a := range got {
+		if !a.Unlimited || a.Formatted != "unlimited" {
+			t.Errorf("unlimited approval formatted as %q: %+v", a.Formatted, a)
+		}
+	}
+}
+
+func TestBlockParser_notify(t *testing.T) {
+	logging.Init("debug")
+
+	received := make(chan Alert, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		json.NewDecoder(r.Body).Decode(&a)
+		received <- a
+	}))
+	t.Cleanup(webhook.Close)
+
+	bp := (&BlockParser{store: NewTransactionStorage()}).WithAlertWebhook(webhook.URL)
+	bp.mu.Lock()
+	bp.raiseAlert(Alert{Type: AlertUnlimitedApproval, Address: callWallet, Message: "approved"}, "key")
+	bp.mu.Unlock()
+
+	select {
+	case a := <-received:
+		if a.ID != 1 || a.Type != AlertUnlimitedApproval || a.Address != ChecksumAddress(callWallet) {
+			t.Errorf("unexpected notification %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert was not posted to the webhook")
+	}
+}
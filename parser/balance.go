@@ -0,0 +1,254 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// AlertBalanceMismatch is raised when the derived balance differs from the one reported by the node
+const AlertBalanceMismatch = "balance-mismatch"
+
+// DefaultReconcileInterval is the number of blocks between reconciliations of derived balances
+const DefaultReconcileInterval = 100
+
+// Balance is the ETH balance of a subscribed address derived from ingested activity
+type Balance struct {
+	Address     string          `json:"address"`
+	Balance     string          `json:"balance"`              // Balance in Wei as decimal string
+	BlockNumber int             `json:"blockNumber"`          // Last block applied to the balance
+	Reconciled  *Reconciliation `json:"reconciled,omitempty"` // Last check against eth_getBalance
+}
+
+// Reconciliation is the result of comparing derived balance with eth_getBalance at the same block
+type Reconciliation struct {
+	BlockNumber int       `json:"blockNumber"`
+	Expected    string    `json:"expected"`             // Derived balance in Wei
+	Actual      string    `json:"actual"`               // Balance reported by the node in Wei
+	Difference  string    `json:"difference,omitempty"` // Actual minus expected, when they differ
+	Time        time.Time `json:"time"`
+}
+
+// WithBalanceReconciliation sets the number of blocks between reconciliations of derived balances with eth_getBalance.
+//
+// Zero disables periodic reconciliation, balances are still initialised from the node.
+func (bp *BlockParser) WithBalanceReconciliation(blocks int) *BlockParser {
+	bp.reconcileInterval = blocks
+	return bp
+}
+
+// GetBalance returns derived ETH balance of the address.
+func (bp *BlockParser) GetBalance(address string) (Balance, bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.store.Balance(normalizeAddress(address))
+}
+
+// getBalance fetches balance of the address at the block using eth_getBalance.
+func (bp *BlockParser) getBalance(address string, blockNumber int) (*big.Int, error) {
+	result, err := bp.call("eth_getBalance", address, fmt.Sprintf("0x%x", blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := result.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid eth_getBalance result: %v", result)
+	}
+	return hexToBig(balance), nil
+}
+
+// reconcileDue reports whether derived balances should be reconciled with the node at the block.
+func (bp *BlockParser) reconcileDue(blockNumber int) bool {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	due := bp.reconcileBalances || (bp.reconcileInterval > 0 && blockNumber%bp.reconcileInterval == 0)
+	bp.reconcileBalances = false
+	return due
+}
+
+// updateBalances applies activity of blocks processed since the last update to balances of observed addresses.
+// Balances are initialised from the node the first time and reconciled with it when reconcileAll is set.
+func (bp *BlockParser) updateBalances(blockNumber int, reconcileAll bool) {
+	type update struct {
+		balance   Balance
+		tracked   bool
+		delta     *big.Int
+		reconcile bool
+	}
+
+	bp.mu.Lock()
+	var updates []update
+	for _, address := range bp.store.ObservedAddresses() {
+		balance, tracked := bp.store.Balance(address)
+		if !tracked {
+			balance = Balance{Address: address}
+		}
+		updates = append(updates, update{
+			balance:   balance,
+			tracked:   tracked,
+			delta:     bp.balanceDelta(address, balance.BlockNumber, blockNumber),
+			reconcile: reconcileAll || !tracked,
+		})
+	}
+	bp.mu.Unlock()
+
+	for _, u := range updates {
+		b := u.balance
+		expected := new(big.Int)
+		if u.tracked {
+			current, _ := new(big.Int).SetString(b.Balance, 10)
+			if current != nil {
+				expected.Add(current, u.delta)
+			}
+		}
+		b.Balance = expected.String()
+		b.BlockNumber = blockNumber
+
+		if u.reconcile {
+			actual, err := bp.getBalance(b.Address, blockNumber)
+			if err != nil {
+				L.L.Error("Failed fetching balance of", b.Address, "Error:", err.Error())
+				if !u.tracked {
+					// try again with the next block
+					continue
+				}
+			} else if u.tracked {
+				b.Reconciled = &Reconciliation{
+					BlockNumber: blockNumber,
+					Expected:    expected.String(),
+					Actual:      actual.String(),
+					Time:        time.Now().UTC(),
+				}
+				if diff := new(big.Int).Sub(actual, expected); diff.Sign() != 0 {
+					b.Reconciled.Difference = diff.String()
+				}
+				b.Balance = actual.String()
+			} else {
+				b.Balance = actual.String()
+			}
+		}
+
+		bp.mu.Lock()
+		if b.Reconciled != nil && b.Reconciled.BlockNumber == blockNumber && b.Reconciled.Difference != "" {
+			bp.raiseAlert(Alert{
+				Type:        AlertBalanceMismatch,
+				Address:     b.Address,
+				BlockNumber: blockNumber,
+				Message:     fmt.Sprintf("derived balance %s Wei differs from node balance %s Wei by %s Wei", b.Reconciled.Expected, b.Reconciled.Actual, b.Reconciled.Difference),
+			}, AlertBalanceMismatch+"/"+b.Address+"/"+strconv.Itoa(blockNumber))
+		}
+		bp.store.StoreBalance(b)
+		bp.mu.Unlock()
+	}
+}
+
+// noteBalanceDelta remembers the balance changes of observed addresses caused by the stored entry.
+// Caller must hold bp.mu.
+func (bp *BlockParser) noteBalanceDelta(tx Transaction) {
+	seen := make(map[string]bool)
+	for _, address := range []string{tx.From, tx.To, tx.ContractAddress} {
+		// the same address is both sender and recipient of self transfers
+		if address == "" || seen[address] || !bp.store.IsObserved(address) {
+			continue
+		}
+		seen[address] = true
+		bp.addBalanceDelta(tx.BlockNumber, address, transactionDelta(address, tx))
+	}
+}
+
+// addBalanceDelta adds balance change of the address caused by the block. Changes are applied by updateBalances
+// and kept for reorgDepth blocks to be reverted when the block gets orphaned. Caller must hold bp.mu.
+func (bp *BlockParser) addBalanceDelta(blockNumber int, address string, delta *big.Int) {
+	if delta.Sign() == 0 {
+		return
+	}
+	if bp.balanceDeltas == nil {
+		bp.balanceDeltas = make(map[int]map[string]*big.Int)
+	}
+	if bp.balanceDeltas[blockNumber] == nil {
+		bp.balanceDeltas[blockNumber] = make(map[string]*big.Int)
+	}
+	if bp.balanceDeltas[blockNumber][address] == nil {
+		bp.balanceDeltas[blockNumber][address] = new(big.Int)
+	}
+	bp.balanceDeltas[blockNumber][address].Add(bp.balanceDeltas[blockNumber][address], delta)
+}
+
+// balanceDelta sums balance changes of the address caused by blocks after `from` up to and including `to`.
+// Caller must hold bp.mu.
+func (bp *BlockParser) balanceDelta(address string, from, to int) *big.Int {
+	delta := new(big.Int)
+	for n, deltas := range bp.balanceDeltas {
+		if n > from && n <= to && deltas[address] != nil {
+			delta.Add(delta, deltas[address])
+		}
+	}
+	return delta
+}
+
+// revertBalanceDeltas reverts balance changes of the orphaned block already applied to balances and moves them
+// back before the block, so changes of the block replacing it are applied with the next update.
+// Caller must hold bp.mu.
+func (bp *BlockParser) revertBalanceDeltas(blockNumber int) {
+	for _, address := range bp.store.ObservedAddresses() {
+		b, tracked := bp.store.Balance(address)
+		if !tracked || b.BlockNumber < blockNumber {
+			continue
+		}
+		balance, _ := new(big.Int).SetString(b.Balance, 10)
+		if balance == nil {
+			balance = new(big.Int)
+		}
+		if delta := bp.balanceDeltas[blockNumber][address]; delta != nil {
+			balance.Sub(balance, delta)
+			L.L.Info("Balance of", address, "reverted to", balance.String())
+		}
+		b.Balance = balance.String()
+		b.BlockNumber = blockNumber - 1
+		bp.store.StoreBalance(b)
+	}
+	delete(bp.balanceDeltas, blockNumber)
+}
+
+// transactionDelta returns the balance change of the address caused by the stored entry.
+func transactionDelta(address string, tx Transaction) *big.Int {
+	delta := new(big.Int)
+	value := hexToBig(tx.Value)
+	failed := tx.Status == "0x0"
+
+	switch tx.Kind {
+	case KindTransaction:
+		if tx.From == address {
+			delta.Sub(delta, transactionFee(tx))
+			if !failed {
+				delta.Sub(delta, value)
+			}
+		}
+		if (tx.To == address || tx.ContractAddress == address) && !failed {
+			delta.Add(delta, value)
+		}
+	case KindInternal:
+		if tx.From == address {
+			delta.Sub(delta, value)
+		}
+		if tx.To == address {
+			delta.Add(delta, value)
+		}
+	case KindWithdrawal:
+		if tx.To == address {
+			delta.Add(delta, value)
+		}
+	}
+	return delta
+}
+
+// transactionFee returns execution and blob fees paid by the sender of tx.
+func transactionFee(tx Transaction) *big.Int {
+	fee := new(big.Int).Mul(hexToBig(tx.GasUsed), hexToBig(tx.EffectiveGasPrice))
+	if tx.BlobGasUsed != "" {
+		fee.Add(fee, new(big.Int).Mul(hexToBig(tx.BlobGasUsed), hexToBig(tx.BlobGasPrice)))
+	}
+	return fee
+}
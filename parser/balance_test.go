@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"encoding/json"
+	"ethTx/cmd/util/logging"
+	"math/big"
+	"testing"
+)
+
+func TestTransactionDelta(t *testing.T) {
+	const me, other = "0x1", "0x2"
+	tests := []struct {
+		name string
+		tx   Transaction
+		want string
+	}{
+		{"outgoing", Transaction{Kind: KindTransaction, From: me, To: other, Value: "0x64", GasUsed: "0x2", EffectiveGasPrice: "0x3", Status: "0x1"}, "-106"},
+		{"outgoing failed", Transaction{Kind: KindTransaction, From: me, To: other, Value: "0x64", GasUsed: "0x2", EffectiveGasPrice: "0x3", Status: "0x0"}, "-6"},
+		{"outgoing blob", Transaction{Kind: KindTransaction, From: me, To: other, Value: "0x0", GasUsed: "0x2", EffectiveGasPrice: "0x3", BlobGasUsed: "0x4", BlobGasPrice: "0x5", Status: "0x1"}, "-26"},
+		{"incoming", Transaction{Kind: KindTransaction, From: other, To: me, Value: "0x64", GasUsed: "0x2", EffectiveGasPrice: "0x3", Status: "0x1"}, "100"},
+		{"incoming failed", Transaction{Kind: KindTransaction, From: other, To: me, Value: "0x64", Status: "0x0"}, "0"},
+		{"self", Transaction{Kind: KindTransaction, From: me, To: me, Value: "0x64", GasUsed: "0x2", EffectiveGasPrice: "0x3", Status: "0x1"}, "-6"},
+		{"deployed contract", Transaction{Kind: KindTransaction, From: other, ContractAddress: me, Value: "0x64", Status: "0x1"}, "100"},
+		{"internal outgoing", Transaction{Kind: KindInternal, From: me, To: other, Value: "0x64"}, "-100"},
+		{"internal incoming", Transaction{Kind: KindInternal, From: other, To: me, Value: "0x64"}, "100"},
+		{"withdrawal", Transaction{Kind: KindWithdrawal, To: me, Value: "0x64"}, "100"},
+	}
+
+	for _, tt := range tests {
+		if got := transactionDelta(me, tt.tx).String(); got != tt.want {
+			t.Errorf("%s: transactionDelta = %s; want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBlockParser_updateBalances(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{"eth_getBalance": `"0x64"`})
+	bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithBalanceReconciliation(12)
+	bp.Subscribe(callWallet)
+
+	// initialised from the node
+	bp.updateBalances(10, bp.reconcileDue(10))
+	if b, ok := bp.GetBalance(callWallet); !ok || b.Balance != "100" || b.BlockNumber != 10 || b.Reconciled != nil {
+		t.Fatalf("unexpected initial balance %+v", b)
+	}
+
+	// self transfer is stored twice, it must be applied once
+	self := Transaction{Hash: "0xaa", Kind: KindTransaction, From: callWallet, To: callWallet, Value: "0x5", GasUsed: "0x2", EffectiveGasPrice: "0x3", Status: "0x1", BlockNumber: 11}
+	out := Transaction{Hash: "0xbb", Kind: KindTransaction, From: callWallet, To: callToken, Value: "0x5", GasUsed: "0x2", EffectiveGasPrice: "0x3", Status: "0x1", BlockNumber: 11}
+	withdrawal := Transaction{Kind: KindWithdrawal, To: callWallet, Value: "0x1", BlockNumber: 11, Withdrawal: &Withdrawal{Index: 1}}
+	bp.mu.Lock()
+	for _, tx := range []Transaction{self, self, out, withdrawal} {
+		bp.store.StoreTransactions(callWallet, tx)
+	}
+	for _, tx := range []Transaction{self, out, withdrawal} {
+		bp.noteBalanceDelta(tx)
+	}
+	bp.store.StoreFeeRecipientBlock(callWallet, FeeRecipientBlock{BlockNumber: 11, PriorityFees: "10"})
+	bp.addBalanceDelta(11, callWallet, big.NewInt(10))
+	bp.mu.Unlock()
+
+	// 100 - 6 - 11 + 1 + 10
+	bp.updateBalances(11, bp.reconcileDue(11))
+	if b, _ := bp.GetBalance(callWallet); b.Balance != "94" || b.BlockNumber != 11 {
+		t.Fatalf("unexpected derived balance %+v", b)
+	}
+
+	// node disagrees with the derived balance
+	bp.updateBalances(12, bp.reconcileDue(12))
+	b, _ := bp.GetBalance(callWallet)
+	if b.Balance != "100" || b.Reconciled == nil || b.Reconciled.Expected != "94" || b.Reconciled.Actual != "100" || b.Reconciled.Difference != "6" {
+		t.Errorf("unexpected reconciled balance %+v %+v", b, b.Reconciled)
+	}
+	alerts := bp.GetAlerts(callWallet)
+	if len(alerts) != 1 || alerts[0].Type != AlertBalanceMismatch || alerts[0].BlockNumber != 12 {
+		t.Errorf("expected balance mismatch alert, got %+v", alerts)
+	}
+
+	// matching balance is reconciled without alert
+	bp.updateBalances(24, bp.reconcileDue(24))
+	if b, _ := bp.GetBalance(callWallet); b.Reconciled == nil || b.Reconciled.BlockNumber != 24 || b.Reconciled.Difference != "" {
+		t.Errorf("unexpected reconciliation %+v", b.Reconciled)
+	}
+	if alerts := bp.GetAlerts(callWallet); len(alerts) != 1 {
+		t.Errorf("matching balance should not raise alerts, got %+v", alerts)
+	}
+}
+
+func TestBlockParser_updateBalances_Reorg(t *testing.T) {
+	logging.Init("debug")
+
+	// the block replacing the orphaned one has no withdrawal
+	replacement, _ := json.Marshal(map[string]interface{}{"number": "0xb", "hash": "0xnew", "transactions": []interface{}{}})
+	rpc := mockRPC(t, map[string]string{
+		"eth_getBalance":       `"0x64"`,
+		"eth_getBlockByNumber": string(replacement),
+		"eth_getLogs":          "[]",
+	})
+	bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithBalanceReconciliation(0)
+	bp.Subscribe(callWallet)
+	bp.updateBalances(10, bp.reconcileDue(10))
+
+	block := map[string]interface{}{
+		"number":       "0xb",
+		"hash":         "0xold",
+		"transactions": []interface{}{},
+		"withdrawals":  []interface{}{map[string]interface{}{"index": "0x1", "address": callWallet, "amount": "0x1"}},
+	}
+	bp.processBlock(block)
+	bp.rememberBlock(11, "0xold")
+	bp.updateBalances(11, bp.reconcileDue(11))
+	if b, _ := bp.GetBalance(callWallet); b.Balance != "1000000100" {
+		t.Fatalf("withdrawal was not applied: %+v", b)
+	}
+
+	bp.handleReorg(11)
+	bp.updateBalances(12, bp.reconcileDue(12))
+	b, _ := bp.GetBalance(callWallet)
+	if b.Balance != "100" || b.BlockNumber != 12 || b.Reconciled == nil || b.Reconciled.Expected != "100" || b.Reconciled.Difference != "" {
+		t.Errorf("unexpected balance after reorg %+v %+v", b, b.Reconciled)
+	}
+	if alerts := bp.GetAlerts(callWallet); len(alerts) != 0 {
+		t.Errorf("reorg raised alerts %+v", alerts)
+	}
+}
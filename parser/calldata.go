@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"encoding/hex"
+	L "ethTx/cmd/util/logging"
+	"ethTx/parser/abi"
+	"ethTx/parser/sigdb"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RegisterABI parses contract ABI JSON and uses it to decode calldata of transactions to the contract.
+//
+// Previously registered ABI of the contract is replaced.
+func (bp *BlockParser) RegisterABI(address string, data []byte) error {
+	if !validAddress(address) {
+		return fmt.Errorf("address %s is not valid hex number", address)
+	}
+
+	a, err := abi.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.store.StoreABI(normalizeAddress(address), a)
+	L.L.Info("Registered ABI with", fmt.Sprintf("%d", len(a.Methods)), "methods for", address)
+	return nil
+}
+
+// GetABIAddresses returns addresses of contracts with registered ABI.
+func (bp *BlockParser) GetABIAddresses() []string {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.store.ABIAddresses()
+}
+
+// LoadSignatures imports additional function and event signatures from a file.
+//
+// See sigdb.Registry.Import for the file format.
+func (bp *BlockParser) LoadSignatures(path string) error {
+	if bp.sigs == nil {
+		bp.sigs = sigdb.New()
+	}
+	count, err := bp.sigs.ImportFile(path)
+	if err != nil {
+		return err
+	}
+	L.L.Info("Imported", fmt.Sprintf("%d", count), "signatures from", path)
+	return nil
+}
+
+// LoadABIs registers every `<contract address>.json` ABI file found in dir.
+func (bp *BlockParser) LoadABIs(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		address := strings.TrimSuffix(filepath.Base(file), ".json")
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := bp.RegisterABI(address, data); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// decodeCall decodes calldata of tx using ABI registered for the recipient,
+// falling back to the signature registry. Caller must hold bp.mu.
+func (bp *BlockParser) decodeCall(tx *Transaction) {
+	if tx.To == "" || len(tx.Input) < 10 {
+		return
+	}
+	input, err := hex.DecodeString(strings.TrimPrefix(tx.Input, "0x"))
+	if err != nil {
+		L.L.Warn("Invalid calldata of", tx.Hash)
+		return
+	}
+
+	if contractABI := bp.store.ABI(tx.To); contractABI != nil {
+		call, err := contractABI.DecodeCall(input)
+		if err == nil {
+			tx.Call = call
+			return
+		}
+		L.L.Debug("Decoding calldata of", tx.Hash, "with registered ABI failed:", err.Error())
+	}
+
+	if bp.sigs == nil {
+		return
+	}
+	signatures := bp.sigs.Functions(tx.Input[:10])
+	for _, sig := range signatures {
+		m, err := abi.ParseSignature(sig)
+		if err != nil {
+			continue
+		}
+		call, err := m.Decode(input)
+		if err != nil {
+			L.L.Debug("Decoding calldata of", tx.Hash, "as", sig, "failed:", err.Error())
+			continue
+		}
+		call.Guessed = true
+		tx.Call = call
+		return
+	}
+	// label the call even when arguments do not match any known signature
+	if len(signatures) > 0 {
+		name, _, _ := strings.Cut(signatures[0], "(")
+		tx.Call = &abi.Call{Method: name, Signature: signatures[0], Guessed: true}
+	}
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"ethTx/parser/sigdb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	callWallet = "0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e"
+	callToken  = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+)
+
+const tokenABI = `[{"type": "function", "name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}]}]`
+
+// transfer(0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f, 1000)
+const transferInput = "0xa9059cbb" +
+	"0000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f" +
+	"00000000000000000000000000000000000000000000000000000000000003e8"
+
+func callBlock() map[string]interface{} {
+	return map[string]interface{}{
+		"transactions": []interface{}{
+			map[string]interface{}{
+				"hash":        "0xaa",
+				"from":        callWallet,
+				"to":          callToken,
+				"value":       "0x0",
+				"input":       transferInput,
+				"blockNumber": "0x14386af",
+			},
+		},
+	}
+}
+
+func TestBlockParser_decodeCall(t *testing.T) {
+	logging.Init("debug")
+
+	t.Run("registered ABI", func(t *testing.T) {
+		bp := &BlockParser{store: NewTransactionStorage()}
+		bp.Subscribe(callWallet)
+		if err := bp.RegisterABI(callToken, []byte(tokenABI)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := bp.processBlockTransactions(callBlock()); err != nil {
+			t.Fatal(err)
+		}
+		tx := bp.GetTransactions(callWallet)[0]
+		if tx.Call == nil || tx.Call.Guessed || tx.Call.Method != "transfer" || tx.Call.Args[0].Name != "to" || tx.Call.Args[1].Value != "1000" {
+			t.Errorf("unexpected call %+v", tx.Call)
+		}
+		if tx.Input != transferInput {
+			t.Errorf("input = %s; want %s", tx.Input, transferInput)
+		}
+	})
+
+	t.Run("known selector", func(t *testing.T) {
+		bp := &BlockParser{store: NewTransactionStorage(), sigs: sigdb.New()}
+		bp.Subscribe(callWallet)
+
+		if err := bp.processBlockTransactions(callBlock()); err != nil {
+			t.Fatal(err)
+		}
+		tx := bp.GetTransactions(callWallet)[0]
+		if tx.Call == nil || !tx.Call.Guessed || tx.Call.Signature != "transfer(address,uint256)" || tx.Call.Args[0].Name != "" {
+			t.Errorf("unexpected call %+v", tx.Call)
+		}
+	})
+}
+
+func TestBlockParser_decodeCall_UnknownArguments(t *testing.T) {
+	logging.Init("debug")
+
+	bp := &BlockParser{store: NewTransactionStorage(), sigs: sigdb.New()}
+	tx := Transaction{Hash: "0xaa", To: callToken, Input: "0xa9059cbb00"}
+	bp.decodeCall(&tx)
+	if tx.Call == nil || tx.Call.Method != "transfer" || tx.Call.Args != nil || !tx.Call.Guessed {
+		t.Errorf("call with malformed arguments should be labeled only, got %+v", tx.Call)
+	}
+
+	tx = Transaction{Hash: "0xaa", To: callToken, Input: "0x12345678"}
+	bp.decodeCall(&tx)
+	if tx.Call != nil {
+		t.Errorf("unknown selector should not be labeled, got %+v", tx.Call)
+	}
+}
+
+func TestBlockParser_LoadABIs(t *testing.T) {
+	logging.Init("debug")
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, callToken+".json"), []byte(tokenABI), 0o644)
+	os.WriteFile(filepath.Join(dir, "README.txt"), []byte("not an ABI"), 0o644)
+
+	bp := &BlockParser{store: NewTransactionStorage()}
+	if err := bp.LoadABIs(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := bp.GetABIAddresses(); len(got) != 1 || got[0] != callToken {
+		t.Errorf("ABI addresses = %v; want [%s]", got, callToken)
+	}
+
+	os.WriteFile(filepath.Join(dir, "0x1234.json"), []byte("{"), 0o644)
+	if err := bp.LoadABIs(dir); err == nil {
+		t.Error("invalid ABI file should fail")
+	}
+}
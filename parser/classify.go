@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"errors"
+	L "ethTx/cmd/util/logging"
+	"ethTx/parser/rawtx"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Address types
+const (
+	AddressTypeEOA       = "eoa"       // externally owned account without code
+	AddressTypeContract  = "contract"  // account with contract code
+	AddressTypeDelegated = "delegated" // externally owned account delegating to contract code under EIP-7702
+)
+
+// delegationPrefix prefixes the code of EIP-7702 delegated accounts, followed by the delegate address
+const delegationPrefix = "0xef0100"
+
+// supportsInterface(bytes4) of ERC-165 and the interface ids queried with it
+const (
+	supportsInterfaceSelector = "0x01ffc9a7"
+	interfaceERC165           = "01ffc9a7"
+	interfaceInvalid          = "ffffffff" // must not be supported by ERC-165 contracts
+	interfaceERC721           = "80ac58cd"
+	interfaceERC1155          = "d9b67a26"
+)
+
+// erc20Functions are PUSH4 instructions of ERC-20 selectors (transfer, balanceOf, totalSupply) found
+// in the function dispatcher of token contracts
+var erc20Functions = []string{"63a9059cbb", "6370a08231", "6318160ddd"}
+
+// AddressInfo classifies an address as externally owned account or contract
+type AddressInfo struct {
+	Address   string   `json:"address"`
+	Type      string   `json:"type"`                // AddressTypeEOA, AddressTypeContract or AddressTypeDelegated
+	Delegate  string   `json:"delegate,omitempty"`  // Contract whose code a delegated account runs
+	Standards []string `json:"standards,omitempty"` // Token standards implemented by the contract, e.g. ERC-20
+}
+
+// GetAddressInfo returns the cached classification of the address.
+func (bp *BlockParser) GetAddressInfo(address string) (AddressInfo, bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.store.AddressInfo(normalizeAddress(address))
+}
+
+// ClassifyAddress returns classification of the address, fetching its code when it is not cached yet.
+func (bp *BlockParser) ClassifyAddress(address string) (AddressInfo, bool) {
+	address = normalizeAddress(address)
+	if !validAddress(address) {
+		return AddressInfo{}, false
+	}
+	if info, ok := bp.GetAddressInfo(address); ok {
+		return info, true
+	}
+
+	info, err := bp.fetchAddressInfo(address)
+	if err != nil {
+		L.L.Error("Failed classifying address", address, "Error:", err.Error())
+		return AddressInfo{}, false
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.store.StoreAddressInfo(info)
+	L.L.Debug("Address", address, "classified as", info.Type)
+	return info, true
+}
+
+// fetchAddressInfo classifies the address by its code at the latest block.
+func (bp *BlockParser) fetchAddressInfo(address string) (AddressInfo, error) {
+	result, err := bp.call("eth_getCode", address, "latest")
+	if err != nil {
+		return AddressInfo{}, err
+	}
+	code, ok := result.(string)
+	if !ok {
+		return AddressInfo{}, fmt.Errorf("invalid eth_getCode result: %v", result)
+	}
+	code = strings.ToLower(code)
+
+	info := AddressInfo{Address: address}
+	switch {
+	case code == "" || code == "0x":
+		info.Type = AddressTypeEOA
+	case strings.HasPrefix(code, delegationPrefix) && len(code) == len(delegationPrefix)+40:
+		info.Type = AddressTypeDelegated
+		info.Delegate = "0x" + code[len(delegationPrefix):]
+	default:
+		info.Type = AddressTypeContract
+		if info.Standards, err = bp.detectStandards(address, code); err != nil {
+			return AddressInfo{}, err
+		}
+	}
+	return info, nil
+}
+
+// detectStandards detects token standards of the contract. ERC-721 and ERC-1155 are required to implement
+// ERC-165, ERC-20 predates it and is recognized by its functions in the code or by resolved decimals.
+func (bp *BlockParser) detectStandards(contract, code string) ([]string, error) {
+	var standards []string
+	erc165, err := bp.supportsInterface(contract, interfaceERC165)
+	if err != nil {
+		return nil, err
+	}
+	if erc165 {
+		invalid, err := bp.supportsInterface(contract, interfaceInvalid)
+		if err != nil {
+			return nil, err
+		}
+		erc165 = !invalid
+	}
+	if erc165 {
+		for _, iface := range []struct{ id, standard string }{
+			{interfaceERC721, StandardERC721},
+			{interfaceERC1155, StandardERC1155},
+		} {
+			supported, err := bp.supportsInterface(contract, iface.id)
+			if err != nil {
+				return nil, err
+			}
+			if supported {
+				standards = append(standards, iface.standard)
+			}
+		}
+	}
+	if len(standards) > 0 {
+		return standards, nil
+	}
+
+	erc20 := true
+	for _, f := range erc20Functions {
+		erc20 = erc20 && strings.Contains(code, f)
+	}
+	if !erc20 {
+		// e.g. tokens behind a proxy
+		bp.mu.Lock()
+		m, ok := bp.store.TokenMetadata(contract)
+		bp.mu.Unlock()
+		erc20 = ok && m.Decimals != nil
+	}
+	if erc20 {
+		standards = append(standards, StandardERC20)
+	}
+	return standards, nil
+}
+
+// supportsInterface calls ERC-165 supportsInterface of the contract. Reverted calls mean the interface
+// is not supported.
+func (bp *BlockParser) supportsInterface(contract, interfaceID string) (bool, error) {
+	data, err := bp.callContract(contract, supportsInterfaceSelector+interfaceID+strings.Repeat("0", 56))
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return len(data) == 32 && new(big.Int).SetBytes(data).Cmp(big.NewInt(1)) == 0, nil
+}
+
+// withAddressInfo attaches cached classifications, primary names and address book labels of the sender
+// and recipient to the transaction. Caller must hold bp.mu.
+func (bp *BlockParser) withAddressInfo(tx Transaction) Transaction {
+	if info, ok := bp.store.AddressInfo(tx.From); ok {
+		tx.FromInfo = &info
+	}
+	if info, ok := bp.store.AddressInfo(tx.To); ok {
+		tx.ToInfo = &info
+	}
+	if r, ok := bp.store.ReverseName(tx.From); ok {
+		tx.FromName = r.Name
+	}
+	if r, ok := bp.store.ReverseName(tx.To); ok {
+		tx.ToName = r.Name
+	}
+	if l, ok := bp.store.Label(tx.From); ok {
+		tx.FromLabel = l.Label
+	}
+	if l, ok := bp.store.Label(tx.To); ok {
+		tx.ToLabel = l.Label
+	}
+	return tx
+}
+
+// codeChanges returns accounts whose code was changed by EIP-7702 authorizations of the transaction.
+func codeChanges(txMap map[string]interface{}) []string {
+	authorizations, _ := txMap["authorizationList"].([]interface{})
+	var authorities []string
+	for _, a := range authorizations {
+		auth, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		chainID, _ := auth["chainId"].(string)
+		address, _ := auth["address"].(string)
+		nonce, _ := auth["nonce"].(string)
+		yParity, _ := auth["yParity"].(string)
+		r, _ := auth["r"].(string)
+		s, _ := auth["s"].(string)
+		authority, err := rawtx.Authority(hexToBig(chainID), address, hexToBig(nonce).Uint64(), hexToBig(yParity).Uint64(), hexToBig(r), hexToBig(s))
+		if err != nil {
+			L.L.Debug("Skipping invalid authorization:", err.Error())
+			continue
+		}
+		authorities = append(authorities, authority)
+	}
+	return authorities
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	classifyNFT      = "0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"
+	classifyContract = "0x00000000219ab540356cbb839cbe05303d7705fa"
+	classifyDelegate = "0x63c0c19a282a1b52b07dd5a65b58948a07dae32b"
+)
+
+func supportsInterfaceCall(contract, interfaceID string) string {
+	return contract + "/" + supportsInterfaceSelector + interfaceID + strings.Repeat("0", 56)
+}
+
+func TestBlockParser_ClassifyAddress(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockTokenRPC(t, map[string]string{
+		"code/" + callWallet:         "0x",
+		"code/" + lifecycleRecipient: "0xef0100" + classifyDelegate[2:],
+		"code/" + callToken:          "0x6080604052" + strings.Join(erc20Functions, "5780"),
+		"code/" + classifyNFT:        "0x6080604052",
+		"code/" + classifyContract:   "0x6080604052",
+
+		supportsInterfaceCall(classifyNFT, interfaceERC165):  "0x" + word1,
+		supportsInterfaceCall(classifyNFT, interfaceERC721):  "0x" + word1,
+		supportsInterfaceCall(classifyNFT, interfaceInvalid): "0x" + word0,
+	})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+
+	tests := []struct {
+		address string
+		want    AddressInfo
+	}{
+		{callWallet, AddressInfo{Address: callWallet, Type: AddressTypeEOA}},
+		{lifecycleRecipient, AddressInfo{Address: lifecycleRecipient, Type: AddressTypeDelegated, Delegate: classifyDelegate}},
+		{callToken, AddressInfo{Address: callToken, Type: AddressTypeContract, Standards: []string{StandardERC20}}},
+		{classifyNFT, AddressInfo{Address: classifyNFT, Type: AddressTypeContract, Standards: []string{StandardERC721}}},
+		{classifyContract, AddressInfo{Address: classifyContract, Type: AddressTypeContract}},
+	}
+	for _, tt := range tests {
+		got, ok := bp.ClassifyAddress(tt.address)
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ClassifyAddress(%s) = %+v, %v; want %+v", tt.address, got, ok, tt.want)
+		}
+	}
+
+	// unknown address is not cached when eth_getCode fails
+	if _, ok := bp.ClassifyAddress(approvalSpender); ok {
+		t.Error("classified address without code")
+	}
+	if _, ok := bp.GetAddressInfo(approvalSpender); ok {
+		t.Error("failed classification was cached")
+	}
+}
+
+func TestBlockParser_ClassifyAddress_tokenMetadata(t *testing.T) {
+	logging.Init("debug")
+
+	// proxy code does not contain token functions
+	rpc := mockTokenRPC(t, map[string]string{"code/" + callToken: "0x363d3d373d3d3d363d73"})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+	decimals := 6
+	bp.store.StoreTokenMetadata(callToken, TokenMetadata{Symbol: "USDC", Decimals: &decimals})
+
+	if got, ok := bp.ClassifyAddress(callToken); !ok || !reflect.DeepEqual(got.Standards, []string{StandardERC20}) {
+		t.Errorf("unexpected classification of token proxy %+v", got)
+	}
+}
+
+func TestBlockParser_GetTransactions_addressInfo(t *testing.T) {
+	bp := &BlockParser{store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+	bp.store.StoreTransactions(callWallet, Transaction{Hash: "0xaa", From: callWallet, To: callToken})
+	bp.store.StoreAddressInfo(AddressInfo{Address: callToken, Type: AddressTypeContract, Standards: []string{StandardERC20}})
+
+	got := bp.GetTransactions(callWallet)
+	if len(got) != 1 || got[0].FromInfo != nil || got[0].ToInfo == nil || got[0].ToInfo.Type != AddressTypeContract {
+		t.Fatalf("unexpected classification of transaction %+v", got)
+	}
+	// stored transactions are left untouched
+	if stored := bp.store.Transactions(callWallet); stored[0].ToInfo != nil {
+		t.Error("classification was stored with the transaction")
+	}
+}
@@ -0,0 +1,24 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+)
+
+// WithDeploymentSubscription makes the parser subscribe contracts deployed by observed addresses.
+func (bp *BlockParser) WithDeploymentSubscription(enabled bool) *BlockParser {
+	bp.subscribeDeployments = enabled
+	return bp
+}
+
+// subscribeDeployment subscribes the contract deployed by tx. Caller must hold bp.mu.
+func (bp *BlockParser) subscribeDeployment(tx Transaction) {
+	if bp.store.IsObserved(tx.ContractAddress) {
+		bp.store.RemoveNameAddress(tx.ContractAddress)
+		return
+	}
+	if err := bp.store.StoreAddress(tx.ContractAddress); err != nil {
+		L.L.Warn("Subscribing deployed contract failed:", err.Error())
+		return
+	}
+	L.L.Info("Contract", tx.ContractAddress, "deployed by", tx.From, "is now subscribed")
+}
@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"sync"
+	"testing"
+)
+
+const (
+	deployer = "0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e"
+	deployed = "0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f"
+)
+
+func creationBlock() map[string]interface{} {
+	return map[string]interface{}{
+		"number": "0x14386af",
+		"transactions": []interface{}{
+			map[string]interface{}{
+				"hash":        "0xaa",
+				"from":        deployer,
+				"to":          nil,
+				"value":       "0x0",
+				"blockNumber": "0x14386af",
+			},
+		},
+	}
+}
+
+func TestBlockParser_processBlockTransactions_ContractCreation(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{
+		"eth_getTransactionReceipt": `{"transactionHash": "0xaa", "contractAddress": "0x3A10DC1A145DA500D5FBA38B9EC49C8FF11A981F", "status": "0x1"}`,
+	})
+
+	tests := []struct {
+		name             string
+		subscribe        bool
+		wantDeployedTxs  int
+		wantDeployedSubs bool
+	}{
+		{name: "record deployed contract", subscribe: false, wantDeployedTxs: 0},
+		{name: "subscribe deployed contract", subscribe: true, wantDeployedTxs: 1, wantDeployedSubs: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bp := (&BlockParser{
+				mu:     sync.Mutex{},
+				rpcURL: rpc.URL,
+				store:  NewTransactionStorage(),
+			}).WithDeploymentSubscription(tt.subscribe)
+			bp.Subscribe(deployer)
+
+			if err := bp.processBlockTransactions(creationBlock()); err != nil {
+				t.Fatal("Failed processing block transactions", err.Error())
+			}
+
+			txs := bp.GetTransactions(deployer)
+			if len(txs) != 1 || !txs[0].ContractCreation || txs[0].ContractAddress != deployed || txs[0].To != "" {
+				t.Fatalf("deployer transactions = %+v", txs)
+			}
+			if got := len(bp.GetTransactions(deployed)); got != tt.wantDeployedTxs {
+				t.Errorf("deployed contract has %d transactions; want %d", got, tt.wantDeployedTxs)
+			}
+			if got := bp.store.IsObserved(deployed); got != tt.wantDeployedSubs {
+				t.Errorf("deployed contract observed = %v; want %v", got, tt.wantDeployedSubs)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// AlertDenylistHit is raised when matched activity involves a denylisted address
+const AlertDenylistHit = "denylist-hit"
+
+// DefaultDenylistReload is the interval on which denylist files are checked for changes
+const DefaultDenylistReload = time.Minute
+
+// Roles of denylisted counterparties
+const (
+	RoleFrom      = "from"       // sender of the transaction or internal transfer
+	RoleTo        = "to"         // recipient of the transaction or internal transfer
+	RoleTokenFrom = "token-from" // sender of a token transferred by the transaction
+	RoleTokenTo   = "token-to"   // recipient of a token transferred by the transaction
+)
+
+// denylistAddress matches addresses in any file format, e.g. one address per line, CSV or JSON exports
+var denylistAddress = regexp.MustCompile(`0[xX][0-9a-fA-F]{40}`)
+
+// DenylistHit is a denylisted counterparty of a transaction
+type DenylistHit struct {
+	Address string `json:"address"`
+	Role    string `json:"role"` // RoleFrom, RoleTo, RoleTokenFrom or RoleTokenTo
+	List    string `json:"list"` // Name of the denylist file
+}
+
+// denylist is a loaded denylist file
+type denylist struct {
+	modTime   time.Time
+	addresses map[string]bool
+}
+
+// WithDenylistReload sets the interval on which denylist files are checked for changes, 0 disables reloading.
+func (bp *BlockParser) WithDenylistReload(interval time.Duration) *BlockParser {
+	bp.denylistReload = interval
+	return bp
+}
+
+// LoadDenylist loads addresses from the denylist file. Every address found in the file is denylisted,
+// so that plain lists as well as CSV or JSON exports of e.g. OFAC SDN addresses can be used.
+func (bp *BlockParser) LoadDenylist(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	addresses := make(map[string]bool)
+	for _, a := range denylistAddress.FindAllString(string(data), -1) {
+		addresses[normalizeAddress(a)] = true
+	}
+	if len(addresses) == 0 {
+		return fmt.Errorf("%s contains no addresses", path)
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if bp.denylists == nil {
+		bp.denylists = make(map[string]denylist)
+	}
+	bp.denylists[path] = denylist{modTime: info.ModTime(), addresses: addresses}
+	L.L.Info("Loaded", fmt.Sprintf("%d", len(addresses)), "denylisted addresses from", path)
+	return nil
+}
+
+// WatchDenylists reloads denylist files when they change until block synchronisation is stopped.
+// A file that cannot be reloaded keeps its previous content.
+func (bp *BlockParser) WatchDenylists() {
+	if bp.denylistReload <= 0 {
+		return
+	}
+	stop := bp.stopped()
+	ticker := time.NewTicker(bp.denylistReload)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		bp.reloadDenylists()
+	}
+}
+
+// reloadDenylists reloads denylist files modified since they were loaded.
+func (bp *BlockParser) reloadDenylists() {
+	bp.mu.Lock()
+	loaded := make(map[string]time.Time, len(bp.denylists))
+	for path, d := range bp.denylists {
+		loaded[path] = d.modTime
+	}
+	bp.mu.Unlock()
+
+	for path, modTime := range loaded {
+		info, err := os.Stat(path)
+		if err != nil {
+			L.L.Error("Checking denylist", path, "failed:", err.Error())
+			continue
+		}
+		if info.ModTime().Equal(modTime) {
+			continue
+		}
+		if err := bp.LoadDenylist(path); err != nil {
+			L.L.Error("Reloading denylist failed:", err.Error())
+		}
+	}
+}
+
+// denylisted returns the name of the denylist containing the address. Caller must hold bp.mu.
+func (bp *BlockParser) denylisted(address string) (string, bool) {
+	if address == "" {
+		return "", false
+	}
+	paths := make([]string, 0, len(bp.denylists))
+	for path := range bp.denylists {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if bp.denylists[path].addresses[address] {
+			return filepath.Base(path), true
+		}
+	}
+	return "", false
+}
+
+// screenTransaction checks the sender, recipient and token transfer counterparties of the matched transaction
+// against the denylists, marks hits on the transaction and raises alerts for its observed parties.
+// Caller must hold bp.mu.
+func (bp *BlockParser) screenTransaction(tx *Transaction) {
+	if len(bp.denylists) == 0 {
+		return
+	}
+	type party struct{ address, role string }
+	parties := []party{{tx.From, RoleFrom}, {tx.To, RoleTo}}
+	for _, log := range tx.Logs {
+		if from, to, ok := transferParties(log.Topics); ok {
+			parties = append(parties, party{from, RoleTokenFrom}, party{to, RoleTokenTo})
+		}
+	}
+
+	// internal transfers are reported under their top-level transaction
+	txHash := tx.Hash
+	if txHash == "" {
+		txHash = tx.ParentHash
+	}
+
+	tx.DenylistHits = nil
+	seen := make(map[string]bool)
+	for _, p := range parties {
+		list, hit := bp.denylisted(p.address)
+		if !hit || seen[p.address+"/"+p.role] {
+			continue
+		}
+		seen[p.address+"/"+p.role] = true
+		tx.DenylistHits = append(tx.DenylistHits, DenylistHit{Address: p.address, Role: p.role, List: list})
+		for _, observed := range []string{tx.From, tx.To} {
+			if bp.store.IsObserved(observed) {
+				bp.raiseDenylistAlert(observed, p.address, list, txHash, tx.BlockNumber)
+			}
+		}
+	}
+}
+
+// screenTransfer checks the counterparty of a token transfer involving observed addresses against the denylists.
+// It covers transfers made by transactions that are not matched themselves. Caller must hold bp.mu.
+func (bp *BlockParser) screenTransfer(from, to, txHash string, blockNumber int) {
+	for _, side := range [][2]string{{from, to}, {to, from}} {
+		observed, counterparty := side[0], side[1]
+		if !bp.store.IsObserved(observed) {
+			continue
+		}
+		if list, hit := bp.denylisted(counterparty); hit {
+			bp.raiseDenylistAlert(observed, counterparty, list, txHash, blockNumber)
+		}
+	}
+}
+
+// raiseDenylistAlert raises high priority alert for the observed address. Caller must hold bp.mu.
+func (bp *BlockParser) raiseDenylistAlert(observed, listed, list, txHash string, blockNumber int) {
+	bp.raiseAlert(Alert{
+		Type:        AlertDenylistHit,
+		Priority:    PriorityHigh,
+		Address:     observed,
+		TxHash:      txHash,
+		BlockNumber: blockNumber,
+		Message:     fmt.Sprintf("%s interacted with %s listed in %s", observed, listed, list),
+	}, fmt.Sprintf("%s/%s/%s/%s", AlertDenylistHit, observed, listed, txHash))
+}
+
+// transferParties returns sender and recipient of ERC-20, ERC-721 and ERC-1155 transfer events.
+func transferParties(topics []string) (string, string, bool) {
+	switch {
+	case len(topics) >= 3 && topics[0] == transferTopic:
+		return topicToAddress(topics[1]), topicToAddress(topics[2]), true
+	case len(topics) == 4 && (topics[0] == transferSingleTopic || topics[0] == transferBatchTopic):
+		return topicToAddress(topics[2]), topicToAddress(topics[3]), true
+	}
+	return "", "", false
+}
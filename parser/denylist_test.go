@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Tornado Cash router as exported in OFAC SDN lists
+const sanctioned = "0xd90e2f925da726b50c4ed8d0fb90ad053324f31b"
+
+func writeDenylist(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockParser_LoadDenylist(t *testing.T) {
+	logging.Init("debug")
+
+	path := filepath.Join(t.TempDir(), "sdn.csv")
+	modTime := time.Now().Add(-time.Hour)
+	writeDenylist(t, path, "address,program\n0xD90e2f925DA726b50C4Ed8D0Fb90Ad053324F31b,CYBER2\n", modTime)
+
+	bp := &BlockParser{store: NewTransactionStorage()}
+	if err := bp.LoadDenylist(path); err != nil {
+		t.Fatal("Failed loading denylist", err.Error())
+	}
+	if list, ok := bp.denylisted(sanctioned); !ok || list != "sdn.csv" {
+		t.Errorf("denylisted() = %s, %v; want sdn.csv", list, ok)
+	}
+
+	// unchanged file is not reloaded, changed one replaces the list
+	bp.reloadDenylists()
+	writeDenylist(t, path, callWallet+"\n", modTime.Add(time.Minute))
+	bp.reloadDenylists()
+	if _, ok := bp.denylisted(sanctioned); ok {
+		t.Error("address removed from the file is still denylisted")
+	}
+	if _, ok := bp.denylisted(callWallet); !ok {
+		t.Error("address added to the file is not denylisted")
+	}
+
+	// file without addresses keeps the previous list
+	writeDenylist(t, path, "", modTime.Add(2*time.Minute))
+	bp.reloadDenylists()
+	if _, ok := bp.denylisted(callWallet); !ok {
+		t.Error("emptied file cleared the denylist")
+	}
+}
+
+func TestBlockParser_screenTransaction(t *testing.T) {
+	logging.Init("debug")
+
+	path := filepath.Join(t.TempDir(), "sdn.txt")
+	writeDenylist(t, path, sanctioned+"\n", time.Now())
+	bp := &BlockParser{store: NewTransactionStorage()}
+	if err := bp.LoadDenylist(path); err != nil {
+		t.Fatal("Failed loading denylist", err.Error())
+	}
+	bp.Subscribe(callWallet)
+
+	tx := Transaction{
+		Hash:        "0xaa",
+		From:        callWallet,
+		To:          callToken,
+		BlockNumber: 10,
+		Logs: []Log{{
+			Address: callToken,
+			Topics:  []string{transferTopic, "0x000000000000000000000000" + callWallet[2:], "0x000000000000000000000000" + sanctioned[2:]},
+		}},
+	}
+	bp.mu.Lock()
+	bp.screenTransaction(&tx)
+	bp.screenTransaction(&tx)
+	bp.mu.Unlock()
+	if len(tx.DenylistHits) != 1 || tx.DenylistHits[0] != (DenylistHit{Address: sanctioned, Role: RoleTokenTo, List: "sdn.txt"}) {
+		t.Errorf("unexpected denylist hits %+v", tx.DenylistHits)
+	}
+	alerts := bp.GetAlerts(callWallet)
+	if len(alerts) != 1 || alerts[0].Type != AlertDenylistHit || alerts[0].Priority != PriorityHigh || alerts[0].TxHash != "0xaa" {
+		t.Fatalf("unexpected alerts %+v", alerts)
+	}
+
+	// token received in a transaction the subscribed address is not part of
+	bp.mu.Lock()
+	bp.screenTransfer(sanctioned, callWallet, "0xbb", 11)
+	bp.screenTransfer(lifecycleRecipient, callWallet, "0xcc", 11)
+	bp.mu.Unlock()
+	if alerts := bp.GetAlerts(callWallet); len(alerts) != 2 || alerts[1].TxHash != "0xbb" {
+		t.Errorf("unexpected alerts %+v", alerts)
+	}
+
+	// internal transfers are reported under their top-level transaction, each one separately
+	bp.mu.Lock()
+	for _, parent := range []string{"0xdd", "0xee"} {
+		internal := Transaction{From: sanctioned, To: callWallet, Kind: KindInternal, ParentHash: parent, TraceAddress: "0", BlockNumber: 12}
+		bp.screenTransaction(&internal)
+	}
+	bp.mu.Unlock()
+	if alerts := bp.GetAlerts(callWallet); len(alerts) != 4 || alerts[2].TxHash != "0xdd" || alerts[3].TxHash != "0xee" {
+		t.Errorf("unexpected alerts of internal transfers %+v", alerts)
+	}
+}
+
+func TestBlockParser_WatchDenylists_Stop(t *testing.T) {
+	logging.Init("debug")
+
+	path := filepath.Join(t.TempDir(), "sdn.txt")
+	writeDenylist(t, path, sanctioned+"\n", time.Now().Add(-time.Hour))
+	bp := (&BlockParser{store: NewTransactionStorage()}).WithDenylistReload(time.Millisecond)
+	if err := bp.LoadDenylist(path); err != nil {
+		t.Fatal("Failed loading denylist", err.Error())
+	}
+	done := make(chan struct{})
+	go func() {
+		bp.WatchDenylists()
+		close(done)
+	}()
+	writeDenylist(t, path, callWallet+"\n", time.Now())
+	time.Sleep(20 * time.Millisecond)
+	bp.StopSynchronisingBlocks()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher kept running after synchronisation was stopped")
+	}
+	if bp.running {
+		t.Error("watcher restarted block synchronisation")
+	}
+	if _, ok := bp.denylisted(callWallet); !ok {
+		t.Error("changed denylist was not reloaded")
+	}
+}
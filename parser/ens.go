@@ -0,0 +1,279 @@
+package parser
+
+import (
+	"encoding/hex"
+	"errors"
+	"ethTx/cmd/util/keccak"
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ensRegistry is the ENS registry deployed on mainnet
+const ensRegistry = "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e"
+
+// Selectors of the ENS registry and resolver functions
+const (
+	ensResolverSelector = "0x0178b8bf" // resolver(bytes32) of the registry
+	ensAddrSelector     = "0x3b3b57de" // addr(bytes32) of the resolver
+	ensNameSelector     = "0x691f3431" // name(bytes32) of the reverse resolver
+)
+
+// errNoAddress is returned for names that do not resolve to an address
+var errNoAddress = errors.New("name does not resolve to an address")
+
+// DefaultENSRefresh is the time after which resolved ENS names are resolved again
+const DefaultENSRefresh = 24 * time.Hour
+
+// ensCheckInterval is the interval on which names are checked for being older than the refresh period
+const ensCheckInterval = time.Minute
+
+// maxReverseNames limits the number of cached primary names of counterparties
+const maxReverseNames = 10000
+
+// ENSRecord is an ENS name resolved to an address or reverse resolved from it
+type ENSRecord struct {
+	Name     string    `json:"name"` // Empty when the address has no primary name
+	Address  string    `json:"address"`
+	Resolved time.Time `json:"resolved"` // Time of the last resolution
+}
+
+// WithENSRefresh sets the time after which names of subscribed addresses and counterparties are resolved again,
+// 0 disables re-resolution.
+func (bp *BlockParser) WithENSRefresh(refresh time.Duration) *BlockParser {
+	bp.ensRefresh = refresh
+	return bp
+}
+
+// normalizeENSName lowercases the name. Full ENSIP-15 normalization is not performed, names with empty
+// labels or whitespace are rejected.
+func normalizeENSName(name string) (string, bool) {
+	name = strings.ToLower(name)
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || strings.ContainsAny(label, " \t\r\n") {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// namehash computes the ENS node of the name.
+func namehash(name string) [32]byte {
+	var node [32]byte
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		label := keccak.Sum256([]byte(labels[i]))
+		node = keccak.Sum256(node[:], label[:])
+	}
+	return node
+}
+
+// SubscribeName resolves the ENS name and subscribes the address it points to. An address that is already
+// subscribed, directly or by another name, stays subscribed and the name is recorded for it. The name is resolved
+// again after the refresh period and the subscription moves to the new address when the name changed owner.
+func (bp *BlockParser) SubscribeName(name string) (string, bool) {
+	name, ok := normalizeENSName(name)
+	if !ok {
+		L.L.Warn("Name", name, "is not valid ENS name")
+		return "", false
+	}
+	address, err := bp.resolveName(name)
+	if err != nil {
+		L.L.Warn("Resolving", name, "failed:", err.Error())
+		return "", false
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.observeNameAddress(address)
+	bp.store.StoreSubscribedName(ENSRecord{Name: name, Address: address, Resolved: time.Now().UTC()})
+	return address, true
+}
+
+// observeNameAddress subscribes the address a name resolved to unless it is observed already.
+// Caller must hold bp.mu.
+func (bp *BlockParser) observeNameAddress(address string) {
+	if bp.store.IsObserved(address) {
+		return
+	}
+	bp.store.StoreAddress(address)
+	bp.store.StoreNameAddress(address)
+	L.L.Info("Address", address, "is now subscribed")
+}
+
+// LookupName returns the primary ENS name of the address, reverse resolving it when it is not cached yet.
+// Addresses without primary name are cached as well.
+func (bp *BlockParser) LookupName(address string) (string, bool) {
+	address = normalizeAddress(address)
+	if !validAddress(address) {
+		return "", false
+	}
+	bp.mu.Lock()
+	r, known := bp.store.ReverseName(address)
+	bp.mu.Unlock()
+	if known {
+		return r.Name, r.Name != ""
+	}
+
+	name, err := bp.reverseName(address)
+	if err != nil {
+		L.L.Error("Reverse resolving", address, "failed:", err.Error())
+		return "", false
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.store.StoreReverseName(ENSRecord{Name: name, Address: address, Resolved: time.Now().UTC()})
+	return name, name != ""
+}
+
+// WatchNames resolves subscribed names again and expires cached primary names once they are older than the
+// refresh period, until block synchronisation is stopped.
+func (bp *BlockParser) WatchNames() {
+	if bp.ensRefresh <= 0 {
+		return
+	}
+	stop := bp.stopped()
+	ticker := time.NewTicker(min(ensCheckInterval, bp.ensRefresh))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		bp.refreshNames(time.Now())
+	}
+}
+
+// refreshNames resolves subscribed names resolved longer than the refresh period ago again and moves their
+// subscription when they changed owner. Cached primary names of that age are dropped, they are reverse resolved
+// again when the address is seen next, and only the most recent maxReverseNames are kept.
+func (bp *BlockParser) refreshNames(now time.Time) {
+	if bp.ensRefresh == 0 {
+		return
+	}
+	bp.mu.Lock()
+	var subscribed []ENSRecord
+	for _, r := range bp.store.SubscribedNames() {
+		if now.Sub(r.Resolved) >= bp.ensRefresh {
+			subscribed = append(subscribed, r)
+		}
+	}
+	reverse := bp.store.ReverseNames()
+	sort.Slice(reverse, func(i, j int) bool { return reverse[i].Resolved.After(reverse[j].Resolved) })
+	for i, r := range reverse {
+		if i >= maxReverseNames || now.Sub(r.Resolved) >= bp.ensRefresh {
+			bp.store.RemoveReverseName(r.Address)
+		}
+	}
+	bp.mu.Unlock()
+
+	for _, r := range subscribed {
+		address, err := bp.resolveName(r.Name)
+		if err != nil {
+			L.L.Error("Resolving", r.Name, "failed:", err.Error())
+			continue
+		}
+		bp.mu.Lock()
+		bp.store.StoreSubscribedName(ENSRecord{Name: r.Name, Address: address, Resolved: now})
+		if address != r.Address {
+			L.L.Info("Name", r.Name, "now resolves to", address)
+			bp.observeNameAddress(address)
+			bp.unsubscribeName(r.Address)
+		}
+		bp.mu.Unlock()
+	}
+}
+
+// unsubscribeName stops observing the previous owner of a subscribed name when it was subscribed only because of
+// the name and no other subscribed name still points to it. Caller must hold bp.mu.
+func (bp *BlockParser) unsubscribeName(address string) {
+	if !bp.store.IsNameAddress(address) {
+		return
+	}
+	for _, r := range bp.store.SubscribedNames() {
+		if r.Address == address {
+			return
+		}
+	}
+	bp.store.RemoveAddress(address)
+	bp.store.RemoveNameAddress(address)
+	L.L.Info("Address", address, "is no longer subscribed")
+}
+
+// resolveName resolves the name to an address using its resolver set in the ENS registry.
+func (bp *BlockParser) resolveName(name string) (string, error) {
+	node := namehash(name)
+	resolver, err := bp.ensResolver(node)
+	if err != nil {
+		return "", err
+	}
+	if resolver == "" {
+		return "", fmt.Errorf("%s: %w", name, errNoAddress)
+	}
+	data, err := bp.callContract(resolver, ensAddrSelector+hex.EncodeToString(node[:]))
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return "", fmt.Errorf("%s: %w", name, errNoAddress)
+	} else if err != nil {
+		return "", err
+	}
+	address := topicToAddress(hex.EncodeToString(data))
+	if len(data) != 32 || address == zeroAddress {
+		return "", fmt.Errorf("%s: %w", name, errNoAddress)
+	}
+	return address, nil
+}
+
+// reverseName returns the primary name of the address. The name counts only when it resolves back
+// to the address, anyone can set any name as reverse record of their address.
+func (bp *BlockParser) reverseName(address string) (string, error) {
+	node := namehash(strings.TrimPrefix(address, "0x") + ".addr.reverse")
+	resolver, err := bp.ensResolver(node)
+	if err != nil || resolver == "" {
+		return "", err
+	}
+	data, err := bp.callContract(resolver, ensNameSelector+hex.EncodeToString(node[:]))
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	name, ok := normalizeENSName(decodeTokenString(data))
+	if !ok {
+		return "", nil
+	}
+
+	forward, err := bp.resolveName(name)
+	if err != nil && !errors.Is(err, errNoAddress) {
+		return "", err
+	}
+	if forward != address {
+		L.L.Debug("Primary name", name, "of", address, "does not resolve back to it")
+		return "", nil
+	}
+	return name, nil
+}
+
+// ensResolver returns the resolver of the node set in the registry, empty when there is none.
+func (bp *BlockParser) ensResolver(node [32]byte) (string, error) {
+	data, err := bp.callContract(ensRegistry, ensResolverSelector+hex.EncodeToString(node[:]))
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		// no registry on this chain
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	resolver := topicToAddress(hex.EncodeToString(data))
+	if len(data) != 32 || resolver == zeroAddress {
+		return "", nil
+	}
+	return resolver, nil
+}
@@ -0,0 +1,205 @@
+package parser
+
+import (
+	"encoding/hex"
+	"ethTx/cmd/util/logging"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ensResolver = "0x231b0ee14048e9dccd1d247744d114a4eb5e8e63"
+
+// ensCall returns the mockTokenRPC key of a call of ENS function of the contract with the node of the name
+func ensCall(contract, selector, name string) string {
+	node := namehash(name)
+	return contract + "/" + selector + hex.EncodeToString(node[:])
+}
+
+func addressWord(address string) string {
+	return "0x" + strings.Repeat("0", 24) + address[2:]
+}
+
+// abiString encodes a short string as ABI string return value
+func abiString(s string) string {
+	data := hex.EncodeToString([]byte(s))
+	return fmt.Sprintf("0x%064x%064x%s", 32, len(s), data+strings.Repeat("0", 64-len(data)))
+}
+
+// mockENS registers vitalik.eth resolving to the address and the reverse record of callWallet
+func mockENS(address string) map[string]string {
+	reverse := callWallet[2:] + ".addr.reverse"
+	return map[string]string{
+		ensCall(ensRegistry, ensResolverSelector, "vitalik.eth"): addressWord(ensResolver),
+		ensCall(ensResolver, ensAddrSelector, "vitalik.eth"):     addressWord(address),
+		ensCall(ensRegistry, ensResolverSelector, reverse):       addressWord(ensResolver),
+		ensCall(ensResolver, ensNameSelector, reverse):           abiString("vitalik.eth"),
+	}
+}
+
+func TestNamehash(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", "0000000000000000000000000000000000000000000000000000000000000000"},
+		{"eth", "93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae"},
+		{"foo.eth", "de9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f"},
+	}
+	for _, tt := range tests {
+		node := namehash(tt.name)
+		if got := hex.EncodeToString(node[:]); got != tt.want {
+			t.Errorf("namehash(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBlockParser_SubscribeName(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockTokenRPC(t, mockENS(callWallet))
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+
+	address, ok := bp.SubscribeName("Vitalik.eth")
+	if !ok || address != callWallet || !bp.store.IsObserved(callWallet) {
+		t.Fatalf("SubscribeName() = %s, %v; want %s", address, ok, callWallet)
+	}
+	// address subscribed before, by its address or by another name
+	bp = &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+	for i := 0; i < 2; i++ {
+		if address, ok := bp.SubscribeName("vitalik.eth"); !ok || address != callWallet {
+			t.Errorf("SubscribeName() of subscribed address = %s, %v; want %s", address, ok, callWallet)
+		}
+	}
+	if names := bp.store.SubscribedNames(); len(names) != 1 || names[0].Name != "vitalik.eth" {
+		t.Errorf("name of subscribed address was not recorded: %+v", names)
+	}
+
+	if _, ok := bp.SubscribeName("unknown.eth"); ok {
+		t.Error("name without resolver was subscribed")
+	}
+	if _, ok := bp.SubscribeName("vitalik..eth"); ok {
+		t.Error("invalid name was subscribed")
+	}
+}
+
+func TestBlockParser_LookupName(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockTokenRPC(t, mockENS(callWallet))
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+
+	if name, ok := bp.LookupName(callWallet); !ok || name != "vitalik.eth" {
+		t.Errorf("LookupName() = %s, %v; want vitalik.eth", name, ok)
+	}
+	// addresses without reverse record are cached too
+	if _, ok := bp.LookupName(lifecycleRecipient); ok {
+		t.Error("address without reverse record has a name")
+	}
+	if _, cached := bp.store.ReverseName(lifecycleRecipient); !cached {
+		t.Error("missing reverse record was not cached")
+	}
+
+	// primary name must resolve back to the address
+	spoofed := mockTokenRPC(t, mockENS(lifecycleRecipient))
+	bp = &BlockParser{rpcURL: spoofed.URL, store: NewTransactionStorage()}
+	if name, ok := bp.LookupName(callWallet); ok {
+		t.Errorf("name %s not resolving to the address accepted", name)
+	}
+}
+
+func TestBlockParser_refreshNames(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockTokenRPC(t, mockENS(callWallet))
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage(), ensRefresh: time.Hour}
+	now := time.Now()
+	// vitalik.eth changed owner since it was subscribed
+	bp.store.StoreAddress(lifecycleRecipient)
+	bp.store.StoreNameAddress(lifecycleRecipient)
+	bp.store.StoreSubscribedName(ENSRecord{Name: "vitalik.eth", Address: lifecycleRecipient, Resolved: now.Add(-2 * time.Hour)})
+	bp.store.StoreReverseName(ENSRecord{Address: callWallet, Resolved: now.Add(-2 * time.Hour)})
+	bp.store.StoreTransactions(lifecycleRecipient, Transaction{Hash: "0xaa", From: callWallet, To: lifecycleRecipient})
+
+	// the cache keeps only the most recently resolved names
+	for i := 0; i < maxReverseNames+1; i++ {
+		bp.store.StoreReverseName(ENSRecord{Address: fmt.Sprintf("0x%040x", i), Resolved: now.Add(time.Duration(i) * time.Second)})
+	}
+
+	bp.refreshNames(now)
+	if !bp.store.IsObserved(callWallet) || !bp.store.IsNameAddress(callWallet) || bp.store.IsObserved(lifecycleRecipient) {
+		t.Error("subscription did not move to the new owner of the name")
+	}
+	if got := bp.store.SubscribedNames(); len(got) != 1 || got[0].Address != callWallet || !got[0].Resolved.Equal(now) {
+		t.Errorf("unexpected subscribed names %+v", got)
+	}
+	if _, ok := bp.store.ReverseName(callWallet); ok {
+		t.Error("expired name was not removed from the cache")
+	}
+	if _, ok := bp.store.ReverseName(fmt.Sprintf("0x%040x", 0)); ok || len(bp.store.ReverseNames()) != maxReverseNames {
+		t.Errorf("cache was not bounded to %d names", maxReverseNames)
+	}
+}
+
+func TestBlockParser_refreshNames_DirectSubscription(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockTokenRPC(t, mockENS(callWallet))
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage(), ensRefresh: time.Hour}
+	now := time.Now()
+	// both owners of the name are subscribed by their address as well
+	bp.Subscribe(lifecycleRecipient)
+	bp.Subscribe(callWallet)
+	bp.store.StoreSubscribedName(ENSRecord{Name: "vitalik.eth", Address: lifecycleRecipient, Resolved: now.Add(-2 * time.Hour)})
+
+	bp.refreshNames(now)
+	if !bp.store.IsObserved(lifecycleRecipient) {
+		t.Error("directly subscribed address was unsubscribed")
+	}
+	if !bp.store.IsObserved(callWallet) || bp.store.IsNameAddress(callWallet) {
+		t.Error("directly subscribed new owner should stay subscribed directly")
+	}
+	if got := bp.store.SubscribedNames(); len(got) != 1 || got[0].Address != callWallet {
+		t.Errorf("unexpected subscribed names %+v", got)
+	}
+
+	// address subscribed by a name and then directly stays when the name moves away
+	bp = &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage(), ensRefresh: time.Hour}
+	bp.store.StoreAddress(lifecycleRecipient)
+	bp.store.StoreNameAddress(lifecycleRecipient)
+	bp.store.StoreSubscribedName(ENSRecord{Name: "vitalik.eth", Address: lifecycleRecipient, Resolved: now.Add(-2 * time.Hour)})
+	if !bp.Subscribe(lifecycleRecipient) {
+		t.Fatal("address subscribed by a name could not be subscribed directly")
+	}
+	bp.refreshNames(now)
+	if !bp.store.IsObserved(lifecycleRecipient) {
+		t.Error("directly subscribed address was unsubscribed")
+	}
+}
+
+func TestBlockParser_WatchNames_Stop(t *testing.T) {
+	logging.Init("debug")
+
+	bp := &BlockParser{store: NewTransactionStorage(), ensRefresh: time.Millisecond}
+	bp.store.StoreReverseName(ENSRecord{Address: callWallet, Resolved: time.Now().Add(-time.Hour)})
+	done := make(chan struct{})
+	go func() {
+		bp.WatchNames()
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	bp.StopSynchronisingBlocks()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher kept running after synchronisation was stopped")
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if len(bp.store.ReverseNames()) != 0 {
+		t.Error("expired name was not removed from the cache")
+	}
+}
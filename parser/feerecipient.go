@@ -0,0 +1,126 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+)
+
+// FeeRecipientBlock describes a block whose fee recipient (coinbase) is an observed address
+type FeeRecipientBlock struct {
+	BlockNumber   int    `json:"blockNumber"`
+	BlockHash     string `json:"blockHash"`
+	FeeRecipient  string `json:"feeRecipient"`
+	GasUsed       string `json:"gasUsed"`       // Gas used by the block as decimal string
+	BaseFeePerGas string `json:"baseFeePerGas"` // Base fee in Wei as decimal string
+	PriorityFees  string `json:"priorityFees"`  // Sum of priority fees paid to the fee recipient in Wei
+	BurntFees     string `json:"burntFees"`     // Base fee burnt by the block in Wei (baseFeePerGas * gasUsed)
+}
+
+// GetFeeRecipientBlocks returns blocks in which the address was the fee recipient.
+func (bp *BlockParser) GetFeeRecipientBlocks(address string) []FeeRecipientBlock {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	blocks := bp.store.FeeRecipientBlocks(normalizeAddress(address))
+	if blocks == nil {
+		return []FeeRecipientBlock{}
+	}
+	return blocks
+}
+
+// processBlockFeeRecipient records the block if its fee recipient is observed.
+func (bp *BlockParser) processBlockFeeRecipient(blockData map[string]interface{}) error {
+	miner, _ := blockData["miner"].(string)
+	miner = normalizeAddress(miner)
+
+	bp.mu.Lock()
+	observed := bp.store.IsObserved(miner)
+	bp.mu.Unlock()
+	if !observed {
+		return nil
+	}
+
+	blockNumber, ok := blockData["number"].(string)
+	if !ok {
+		return fmt.Errorf("failed parsing block.result number field")
+	}
+	blockHash, _ := blockData["hash"].(string)
+	gasUsedHex, _ := blockData["gasUsed"].(string)
+	baseFeeHex, _ := blockData["baseFeePerGas"].(string)
+
+	gasUsed := hexToBig(gasUsedHex)
+	baseFee := hexToBig(baseFeeHex)
+
+	receipts, err := bp.getBlockReceipts(blockData)
+	if err != nil {
+		return err
+	}
+
+	priorityFees := new(big.Int)
+	for _, receipt := range receipts {
+		txGasUsed, _ := receipt["gasUsed"].(string)
+		effectiveGasPrice, _ := receipt["effectiveGasPrice"].(string)
+
+		tip := new(big.Int).Sub(hexToBig(effectiveGasPrice), baseFee)
+		if tip.Sign() < 0 {
+			continue
+		}
+		priorityFees.Add(priorityFees, tip.Mul(tip, hexToBig(txGasUsed)))
+	}
+
+	b := FeeRecipientBlock{
+		BlockNumber:   hexToInt(blockNumber),
+		BlockHash:     blockHash,
+		FeeRecipient:  miner,
+		GasUsed:       gasUsed.String(),
+		BaseFeePerGas: baseFee.String(),
+		PriorityFees:  priorityFees.String(),
+		BurntFees:     new(big.Int).Mul(baseFee, gasUsed).String(),
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	L.L.Info("New fee recipient block for", miner)
+	bp.store.StoreFeeRecipientBlock(miner, b)
+	bp.addBalanceDelta(b.BlockNumber, miner, priorityFees)
+	return nil
+}
+
+// getBlockReceipts returns receipts of all block transactions.
+//
+// Falls back to fetching receipts one by one when eth_getBlockReceipts is not supported.
+func (bp *BlockParser) getBlockReceipts(blockData map[string]interface{}) ([]map[string]interface{}, error) {
+	blockNumber, _ := blockData["number"].(string)
+
+	result, err := bp.call("eth_getBlockReceipts", blockNumber)
+	if err == nil {
+		rawReceipts, ok := result.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid eth_getBlockReceipts result: %v", result)
+		}
+		receipts := make([]map[string]interface{}, 0, len(rawReceipts))
+		for _, r := range rawReceipts {
+			if receipt, ok := r.(map[string]interface{}); ok {
+				receipts = append(receipts, receipt)
+			}
+		}
+		return receipts, nil
+	}
+	L.L.Debug("eth_getBlockReceipts failed, fetching receipts one by one:", err.Error())
+
+	transactions, _ := blockData["transactions"].([]interface{})
+	receipts := make([]map[string]interface{}, 0, len(transactions))
+	for _, tx := range transactions {
+		txMap, ok := tx.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hash, _ := txMap["hash"].(string)
+		receipt, err := bp.getTransactionReceipt(hash)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
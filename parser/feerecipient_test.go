@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"encoding/json"
+	"ethTx/cmd/util/logging"
+	"testing"
+)
+
+func TestBlockParser_processBlockFeeRecipient(t *testing.T) {
+	logging.Init("debug")
+
+	const feeRecipient = "0x4838b106fce9647bdf1e7877bf73ce8b0bad5f97"
+
+	// baseFeePerGas of the test block is 0x296e6658a (11121616266)
+	receipts := `[
+        {"transactionHash": "0x01", "gasUsed": "0x5208", "effectiveGasPrice": "0x2d2812f8a"},
+        {"transactionHash": "0x02", "gasUsed": "0x186a0", "effectiveGasPrice": "0x296e6658a"}
+    ]`
+
+	tests := []struct {
+		name    string
+		results map[string]string
+	}{
+		{
+			name:    "block receipts",
+			results: map[string]string{"eth_getBlockReceipts": receipts},
+		},
+		{
+			name: "receipts one by one",
+			results: map[string]string{
+				"eth_getTransactionReceipt": `{"transactionHash": "0x01", "gasUsed": "0x5208", "effectiveGasPrice": "0x2d2812f8a"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpc := mockRPC(t, tt.results)
+			bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+			bp.Subscribe(feeRecipient)
+
+			var blockData map[string]interface{}
+			json.Unmarshal([]byte(block), &blockData)
+			// keep single transaction so both receipt sources see the same block
+			blockData["transactions"] = blockData["transactions"].([]interface{})[:1]
+
+			if err := bp.processBlockFeeRecipient(blockData); err != nil {
+				t.Fatal("Failed processing fee recipient", err.Error())
+			}
+
+			blocks := bp.GetFeeRecipientBlocks(feeRecipient)
+			if len(blocks) != 1 {
+				t.Fatalf("fee recipient should have 1 block, has %d", len(blocks))
+			}
+			want := FeeRecipientBlock{
+				BlockNumber:   0x14386af,
+				BlockHash:     "0x34644288abe3d7c2ed58db7946576e88d000c211bbaada1840ad664be9e93899",
+				FeeRecipient:  feeRecipient,
+				GasUsed:       "18325410",
+				BaseFeePerGas: "11121616266",
+				PriorityFees:  "21000000000000", // 21000 gas * 1 Gwei tip
+				BurntFees:     "203808177937119060",
+			}
+			if blocks[0] != want {
+				t.Errorf("block = %+v; want %+v", blocks[0], want)
+			}
+		})
+	}
+}
+
+func TestBlockParser_processBlockFeeRecipient_NotObserved(t *testing.T) {
+	bp := &BlockParser{store: NewTransactionStorage()}
+
+	var blockData map[string]interface{}
+	json.Unmarshal([]byte(block), &blockData)
+
+	if err := bp.processBlockFeeRecipient(blockData); err != nil {
+		t.Error("Unobserved fee recipient should not fail:", err.Error())
+	}
+}
@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// Intervals by which fee reports are split into periods, in UTC
+const (
+	IntervalDay   = "day"
+	IntervalMonth = "month"
+)
+
+// FeeTotal is the sum of fees paid for outbound transactions within a time range
+type FeeTotal struct {
+	Start         *time.Time `json:"start,omitempty"` // Start of the range, inclusive
+	End           *time.Time `json:"end,omitempty"`   // End of the range, exclusive
+	Transactions  int        `json:"transactions"`
+	GasUsed       string     `json:"gasUsed"`       // Gas used as decimal string
+	ExecutionFees string     `json:"executionFees"` // Sum of gasUsed * effectiveGasPrice in Wei
+	BlobFees      string     `json:"blobFees"`      // Sum of blobGasUsed * blobGasPrice in Wei
+	Fees          string     `json:"fees"`          // Execution and blob fees in Wei
+}
+
+// FeeReport is the fee accounting of a subscribed address
+type FeeReport struct {
+	Address string     `json:"address"`
+	Total   FeeTotal   `json:"total"`
+	Periods []FeeTotal `json:"periods,omitempty"` // Totals per day or month with any fees paid
+}
+
+// feeSum accumulates fees of transactions
+type feeSum struct {
+	count               int
+	gasUsed, exec, blob *big.Int
+}
+
+func newFeeSum() *feeSum {
+	return &feeSum{gasUsed: new(big.Int), exec: new(big.Int), blob: new(big.Int)}
+}
+
+func (s *feeSum) add(tx Transaction) {
+	s.count++
+	s.gasUsed.Add(s.gasUsed, hexToBig(tx.GasUsed))
+	blob := new(big.Int).Mul(hexToBig(tx.BlobGasUsed), hexToBig(tx.BlobGasPrice))
+	s.blob.Add(s.blob, blob)
+	s.exec.Add(s.exec, new(big.Int).Sub(transactionFee(tx), blob))
+}
+
+func (s *feeSum) total(start, end time.Time) FeeTotal {
+	t := FeeTotal{
+		Transactions:  s.count,
+		GasUsed:       s.gasUsed.String(),
+		ExecutionFees: s.exec.String(),
+		BlobFees:      s.blob.String(),
+		Fees:          new(big.Int).Add(s.exec, s.blob).String(),
+	}
+	if !start.IsZero() {
+		t.Start = &start
+	}
+	if !end.IsZero() {
+		t.End = &end
+	}
+	return t
+}
+
+// GetFees returns fees paid by the address for its outbound transactions included in blocks from `from` until `to`.
+// Zero times leave the range open. With IntervalDay or IntervalMonth the totals are also split into periods.
+//
+// Only transactions with a receipt are counted, those reorged out of the chain are not.
+func (bp *BlockParser) GetFees(address string, from, to time.Time, interval string) (FeeReport, error) {
+	if interval != "" && interval != IntervalDay && interval != IntervalMonth {
+		return FeeReport{}, fmt.Errorf("unknown interval %s", interval)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return FeeReport{}, fmt.Errorf("range start %s is not before its end %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	address = normalizeAddress(address)
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	total := newFeeSum()
+	periods := make(map[time.Time]*feeSum)
+	for _, tx := range bp.store.Transactions(address) {
+		if tx.Kind != KindTransaction || tx.From != address || tx.GasUsed == "" {
+			continue
+		}
+		if tx.State == StateReorged || tx.State == StateReplaced {
+			continue
+		}
+		included := time.Unix(tx.Timestamp, 0).UTC()
+		if (!from.IsZero() || !to.IsZero() || interval != "") && tx.Timestamp == 0 {
+			// block time is unknown
+			continue
+		}
+		if (!from.IsZero() && included.Before(from)) || (!to.IsZero() && !included.Before(to)) {
+			continue
+		}
+		total.add(tx)
+		if interval != "" {
+			start := periodStart(included, interval)
+			if periods[start] == nil {
+				periods[start] = newFeeSum()
+			}
+			periods[start].add(tx)
+		}
+	}
+
+	report := FeeReport{Address: address, Total: total.total(from, to)}
+	starts := make([]time.Time, 0, len(periods))
+	for start := range periods {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for _, start := range starts {
+		end := start.AddDate(0, 0, 1)
+		if interval == IntervalMonth {
+			end = start.AddDate(0, 1, 0)
+		}
+		report.Periods = append(report.Periods, periods[start].total(start, end))
+	}
+	return report, nil
+}
+
+// periodStart returns the start of the day or month containing t.
+func periodStart(t time.Time, interval string) time.Time {
+	if interval == IntervalMonth {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
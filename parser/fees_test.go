@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"testing"
+	"time"
+)
+
+func TestBlockParser_GetFees(t *testing.T) {
+	logging.Init("debug")
+
+	bp := &BlockParser{store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+	day := time.Date(2024, 11, 16, 10, 0, 0, 0, time.UTC)
+	for _, tx := range []Transaction{
+		// 21000 gas at 10 gwei
+		{Hash: "0xaa", From: callWallet, To: callToken, Kind: KindTransaction, Timestamp: day.Unix(), GasUsed: "0x5208", EffectiveGasPrice: "0x2540be400"},
+		// blob transaction paying 131072 blob gas at 1 wei on the next day
+		{Hash: "0xbb", From: callWallet, To: callToken, Kind: KindTransaction, Timestamp: day.AddDate(0, 0, 1).Unix(), GasUsed: "0x5208", EffectiveGasPrice: "0x2540be400", BlobGasUsed: "0x20000", BlobGasPrice: "0x1"},
+		// inbound, reorged out and without receipt are not counted
+		{Hash: "0xcc", From: lifecycleRecipient, To: callWallet, Kind: KindTransaction, Timestamp: day.Unix(), GasUsed: "0x5208", EffectiveGasPrice: "0x1"},
+		{Hash: "0xdd", From: callWallet, To: callToken, Kind: KindTransaction, Timestamp: day.Unix(), GasUsed: "0x5208", EffectiveGasPrice: "0x1", State: StateReorged},
+		{Hash: "0xee", From: callWallet, To: callToken, Kind: KindTransaction, Timestamp: day.Unix()},
+	} {
+		bp.store.StoreTransactions(callWallet, tx)
+	}
+
+	report, err := bp.GetFees(callWallet, time.Time{}, time.Time{}, IntervalDay)
+	if err != nil {
+		t.Fatal("Failed getting fees", err.Error())
+	}
+	total := report.Total
+	if total.Transactions != 2 || total.GasUsed != "42000" || total.ExecutionFees != "420000000000000" || total.BlobFees != "131072" || total.Fees != "420000000131072" {
+		t.Errorf("unexpected total %+v", total)
+	}
+	if len(report.Periods) != 2 || !report.Periods[1].Start.Equal(day.AddDate(0, 0, 1).Truncate(24*time.Hour)) || report.Periods[1].Fees != "210000000131072" {
+		t.Errorf("unexpected periods %+v", report.Periods)
+	}
+
+	report, err = bp.GetFees(callWallet, day, day.Add(time.Hour), "")
+	if err != nil || report.Total.Transactions != 1 || report.Total.Fees != "210000000000000" || report.Periods != nil {
+		t.Errorf("GetFees() in range = %+v, %v", report, err)
+	}
+
+	if _, err := bp.GetFees(callWallet, time.Time{}, time.Time{}, "week"); err == nil {
+		t.Error("unknown interval accepted")
+	}
+	if _, err := bp.GetFees(callWallet, day, day, ""); err == nil {
+		t.Error("empty range accepted")
+	}
+}
@@ -0,0 +1,262 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Lifecycle states of top-level transactions
+const (
+	StatePending   = "pending"   // broadcast but not yet included in a block
+	StateIncluded  = "included"  // included in a block
+	StateConfirmed = "confirmed" // included and buried under enough blocks
+	StateReorged   = "reorged"   // included in a block that is no longer part of the chain
+	StateDropped   = "dropped"   // disappeared from the mempool without being included
+	StateReplaced  = "replaced"  // another transaction with the same sender nonce was broadcast or included
+)
+
+// Kinds of replacement
+const (
+	ReplacementSpeedUp = "speed-up" // same call resent, usually with higher fees
+	ReplacementCancel  = "cancel"   // empty self transfer
+	ReplacementOther   = "other"    // different transaction using the same nonce
+)
+
+const (
+	DefaultConfirmations = 12              // blocks (including the one with the transaction) after which it is confirmed
+	DefaultDropTimeout   = 5 * time.Minute // time a pending transaction may be missing from the mempool before it is dropped
+	reorgDepth           = 64              // number of processed block hashes kept for reorg detection
+)
+
+// StateChange is a single transition in the lifecycle of a transaction
+type StateChange struct {
+	State       string    `json:"state"`
+	Time        time.Time `json:"time"`
+	BlockNumber int       `json:"blockNumber,omitempty"` // Block that caused the transition
+}
+
+// WithConfirmations sets the number of blocks after which included transactions are confirmed.
+//
+// Zero disables confirmation tracking.
+func (bp *BlockParser) WithConfirmations(blocks int) *BlockParser {
+	bp.confirmations = blocks
+	return bp
+}
+
+// WithDropTimeout sets the time after which pending transactions missing from the mempool are dropped.
+func (bp *BlockParser) WithDropTimeout(timeout time.Duration) *BlockParser {
+	bp.dropTimeout = timeout
+	return bp
+}
+
+// setState moves tx to state recording the transition in its history.
+func setState(tx *Transaction, state string, blockNumber int) {
+	if tx.State == state {
+		return
+	}
+	tx.State = state
+	tx.History = append(tx.History, StateChange{State: state, Time: time.Now().UTC(), BlockNumber: blockNumber})
+}
+
+// markReplacements marks other transactions of the same sender and nonce as replaced by tx. Caller must hold bp.mu.
+func (bp *BlockParser) markReplacements(tx Transaction) {
+	if tx.Nonce == "" {
+		return
+	}
+	for _, other := range bp.store.TransactionsByNonce(tx.From, tx.Nonce) {
+		if other.Hash == tx.Hash || other.State == StateReplaced {
+			continue
+		}
+		// an included transaction can be replaced only when its block got reorged out
+		if other.State == StateIncluded || other.State == StateConfirmed {
+			continue
+		}
+		setState(&other, StateReplaced, tx.BlockNumber)
+		other.ReplacedBy = tx.Hash
+		other.ReplacementType = replacementType(other, tx)
+		L.L.Info("Transaction", other.Hash, "replaced by", tx.Hash, "("+other.ReplacementType+")")
+		bp.store.UpdateTransaction(other)
+	}
+}
+
+func replacementType(replaced, replacement Transaction) string {
+	empty := replacement.Input == "" || replacement.Input == "0x"
+	switch {
+	case replacement.To == replacement.From && empty && hexToBig(replacement.Value).Sign() == 0:
+		return ReplacementCancel
+	case replacement.To == replaced.To && replacement.Input == replaced.Input && hexToBig(replacement.Value).Cmp(hexToBig(replaced.Value)) == 0:
+		return ReplacementSpeedUp
+	}
+	return ReplacementOther
+}
+
+// awaitConfirmation indexes the included transaction by its block until it is confirmed. Caller must hold bp.mu.
+func (bp *BlockParser) awaitConfirmation(tx Transaction) {
+	if bp.confirmations <= 0 {
+		return
+	}
+	if bp.unconfirmed == nil {
+		bp.unconfirmed = make(map[int][]string)
+	}
+	bp.unconfirmed[tx.BlockNumber] = append(bp.unconfirmed[tx.BlockNumber], tx.Hash)
+}
+
+// confirmTransactions confirms included transactions of blocks buried under enough blocks. Caller must hold bp.mu.
+func (bp *BlockParser) confirmTransactions(blockNumber int) {
+	if bp.confirmations <= 0 {
+		return
+	}
+	for n, hashes := range bp.unconfirmed {
+		if blockNumber-n+1 < bp.confirmations {
+			continue
+		}
+		for _, hash := range hashes {
+			tx, ok := bp.store.Transaction(hash)
+			// reorged out, or included again in another block
+			if !ok || tx.State != StateIncluded || tx.BlockNumber != n {
+				continue
+			}
+			setState(&tx, StateConfirmed, blockNumber)
+			bp.store.UpdateTransaction(tx)
+		}
+		delete(bp.unconfirmed, n)
+	}
+}
+
+// dropMissing drops pending transactions that have not been seen in the mempool for the drop timeout.
+// Caller must hold bp.mu.
+func (bp *BlockParser) dropMissing(now time.Time) {
+	timeout := bp.dropTimeout
+	if timeout <= 0 {
+		timeout = DefaultDropTimeout
+	}
+	for _, tx := range bp.store.TransactionsInState(StatePending) {
+		seen, ok := bp.mempoolSeen[tx.Hash]
+		if ok && now.Sub(seen) < timeout {
+			continue
+		}
+		if !ok {
+			// first sighting happened before the watcher was (re)started, give it another timeout
+			bp.mempoolSeen[tx.Hash] = now
+			continue
+		}
+		L.L.Info("Pending transaction", tx.Hash, "dropped from the mempool")
+		setState(&tx, StateDropped, 0)
+		bp.store.UpdateTransaction(tx)
+		delete(bp.mempoolSeen, tx.Hash)
+	}
+}
+
+// rememberBlock stores hash of a processed block for reorg detection. Caller must hold bp.mu.
+func (bp *BlockParser) rememberBlock(blockNumber int, hash string) {
+	if bp.blockHashes == nil {
+		bp.blockHashes = make(map[int]string)
+	}
+	bp.blockHashes[blockNumber] = hash
+	for n := range bp.blockHashes {
+		if n <= blockNumber-reorgDepth {
+			delete(bp.blockHashes, n)
+		}
+	}
+	for n := range bp.tokenDeltas {
+		if n <= blockNumber-reorgDepth {
+			delete(bp.tokenDeltas, n)
+		}
+	}
+	for n := range bp.balanceDeltas {
+		if n <= blockNumber-reorgDepth {
+			delete(bp.balanceDeltas, n)
+		}
+	}
+}
+
+// handleReorg walks back processed blocks from blockNumber until one that is still part of the chain is found,
+// marks transactions included in the orphaned blocks as reorged out and processes the blocks that replaced them.
+// Transactions included again by the new chain move back to included.
+func (bp *BlockParser) handleReorg(blockNumber int) {
+	bp.mu.Lock()
+	numbers := make([]int, 0, len(bp.blockHashes))
+	for n := range bp.blockHashes {
+		if n <= blockNumber {
+			numbers = append(numbers, n)
+		}
+	}
+	hashes := make(map[int]string, len(numbers))
+	for _, n := range numbers {
+		hashes[n] = bp.blockHashes[n]
+	}
+	bp.mu.Unlock()
+	sort.Sort(sort.Reverse(sort.IntSlice(numbers)))
+
+	orphaned := make(map[int]bool)
+	var replacements []map[string]interface{}
+	for _, n := range numbers {
+		blockData, err := bp.getBlockByNumber(n)
+		if err != nil {
+			L.L.Error("Failed fetching block:", fmt.Sprintf("0x%x", n), "Error:", err.Error())
+			break
+		}
+		if hash, _ := blockData["hash"].(string); hash == hashes[n] {
+			break
+		}
+		orphaned[n] = true
+		replacements = append(replacements, blockData)
+	}
+	if len(orphaned) == 0 {
+		return
+	}
+	L.L.Warn(fmt.Sprintf("Chain reorganization detected, %d processed blocks were orphaned", len(orphaned)))
+
+	bp.mu.Lock()
+	bp.markReorged(orphaned)
+	bp.dropOrphaned(orphaned)
+	// derived balances are verified once the replacement blocks are applied
+	bp.reconcileBalances = true
+	bp.mu.Unlock()
+
+	// replacements were fetched walking back, process them in chain order
+	for i := len(replacements) - 1; i >= 0; i-- {
+		blockData := replacements[i]
+		number, _ := blockData["number"].(string)
+		hash, _ := blockData["hash"].(string)
+		L.L.Info("Processing replacement block", number)
+		bp.processBlock(blockData)
+		bp.mu.Lock()
+		bp.rememberBlock(hexToInt(number), hash)
+		bp.mu.Unlock()
+	}
+}
+
+// dropOrphaned removes activity of the orphaned blocks other than top-level transactions and reverts the ETH and
+// token balance changes it caused, so the replacement blocks can be processed without counting anything twice.
+// Caller must hold bp.mu.
+func (bp *BlockParser) dropOrphaned(orphaned map[int]bool) {
+	numbers := make([]int, 0, len(orphaned))
+	for n := range orphaned {
+		numbers = append(numbers, n)
+	}
+	// balances move back block by block starting with the newest
+	sort.Sort(sort.Reverse(sort.IntSlice(numbers)))
+	for _, n := range numbers {
+		bp.store.RemoveBlock(n)
+		bp.revertTokenDeltas(n)
+		bp.revertBalanceDeltas(n)
+	}
+}
+
+// markReorged marks transactions included in the orphaned blocks as reorged out. Caller must hold bp.mu.
+func (bp *BlockParser) markReorged(orphaned map[int]bool) {
+	for _, tx := range bp.store.TransactionsInState(StateIncluded, StateConfirmed) {
+		if !orphaned[tx.BlockNumber] {
+			continue
+		}
+		L.L.Info("Transaction", tx.Hash, "was reorged out of block", fmt.Sprintf("0x%x", tx.BlockNumber))
+		setState(&tx, StateReorged, tx.BlockNumber)
+		bp.store.UpdateTransaction(tx)
+	}
+	for n := range orphaned {
+		delete(bp.blockHashes, n)
+	}
+}
@@ -0,0 +1,362 @@
+package parser
+
+import (
+	"encoding/json"
+	"ethTx/cmd/util/logging"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const lifecycleRecipient = "0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f"
+
+func lifecycleTx(hash, to, value, nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"hash":  hash,
+		"from":  callWallet,
+		"to":    to,
+		"value": value,
+		"input": "0x",
+		"nonce": nonce,
+	}
+}
+
+func states(tx Transaction) []string {
+	var s []string
+	for _, h := range tx.History {
+		s = append(s, h.State)
+	}
+	return s
+}
+
+func pendingTxpool(txs ...map[string]interface{}) map[string]string {
+	content := `{"pending": {"` + callWallet + `": {`
+	for i, tx := range txs {
+		if i > 0 {
+			content += ","
+		}
+		content += `"` + tx["nonce"].(string) + tx["hash"].(string) + `": {"hash": "` + tx["hash"].(string) + `", "from": "` + callWallet +
+			`", "to": "` + tx["to"].(string) + `", "value": "` + tx["value"].(string) + `", "input": "0x", "nonce": "` + tx["nonce"].(string) + `"}`
+	}
+	return map[string]string{"txpool_content": content + `}}}`}
+}
+
+func TestBlockParser_lifecycle_replacement(t *testing.T) {
+	logging.Init("debug")
+
+	original := lifecycleTx("0xaa", lifecycleRecipient, "0x10", "0x7")
+	cancel := lifecycleTx("0xbb", callWallet, "0x0", "0x7")
+	speedUp := lifecycleTx("0xcc", lifecycleRecipient, "0x10", "0x7")
+
+	t.Run("cancel in mempool", func(t *testing.T) {
+		rpc := mockRPC(t, pendingTxpool(original, cancel))
+		bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithMempool(MempoolModeTxpool, 0)
+		bp.Subscribe(callWallet)
+		if err := bp.processMempool(); err != nil {
+			t.Fatal(err)
+		}
+
+		a, _ := bp.GetTransaction("0xaa")
+		b, _ := bp.GetTransaction("0xbb")
+		// txpool order is random, whichever came second replaced the first one
+		replaced, replacement := a, b
+		if b.State == StateReplaced {
+			replaced, replacement = b, a
+		}
+		if replaced.State != StateReplaced || replaced.ReplacedBy != replacement.Hash || replacement.State != StatePending {
+			t.Errorf("unexpected states %+v, %+v", a, b)
+		}
+	})
+
+	t.Run("speed-up included", func(t *testing.T) {
+		rpc := mockRPC(t, pendingTxpool(original))
+		bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithMempool(MempoolModeTxpool, 0)
+		bp.Subscribe(callWallet)
+		if err := bp.processMempool(); err != nil {
+			t.Fatal(err)
+		}
+
+		speedUp["blockNumber"] = "0xa"
+		if err := bp.processBlockTransactions(map[string]interface{}{"transactions": []interface{}{speedUp}}); err != nil {
+			t.Fatal(err)
+		}
+
+		replaced, _ := bp.GetTransaction("0xaa")
+		if replaced.State != StateReplaced || replaced.ReplacedBy != "0xcc" || replaced.ReplacementType != ReplacementSpeedUp {
+			t.Errorf("0xaa should be replaced by speed-up 0xcc, got %+v", replaced)
+		}
+		if !reflect.DeepEqual(states(replaced), []string{StatePending, StateReplaced}) {
+			t.Errorf("unexpected history of 0xaa: %v", states(replaced))
+		}
+		included, _ := bp.GetTransaction("0xcc")
+		if included.State != StateIncluded || included.Nonce != "0x7" {
+			t.Errorf("0xcc should be included, got %+v", included)
+		}
+		if pending := bp.GetPendingTransactions(callWallet); len(pending) != 1 || pending[0].Hash != "0xaa" {
+			t.Errorf("replaced transaction should stay among not included ones, got %+v", pending)
+		}
+	})
+}
+
+func TestBlockParser_lifecycle_confirmation(t *testing.T) {
+	logging.Init("debug")
+
+	bp := (&BlockParser{store: NewTransactionStorage()}).WithConfirmations(3)
+	bp.Subscribe(callWallet)
+	tx := lifecycleTx("0xaa", lifecycleRecipient, "0x10", "0x7")
+	tx["blockNumber"] = "0xa"
+	if err := bp.processBlockTransactions(map[string]interface{}{"transactions": []interface{}{tx}}); err != nil {
+		t.Fatal(err)
+	}
+
+	bp.confirmTransactions(11)
+	if tx, _ := bp.GetTransaction("0xaa"); tx.State != StateIncluded {
+		t.Errorf("transaction with 2 confirmations should stay included, got %s", tx.State)
+	}
+	bp.confirmTransactions(12)
+	got, _ := bp.GetTransaction("0xaa")
+	if !reflect.DeepEqual(states(got), []string{StateIncluded, StateConfirmed}) {
+		t.Errorf("unexpected history %v", states(got))
+	}
+	if got.History[0].BlockNumber != 10 || got.History[1].BlockNumber != 12 || got.History[1].Time.IsZero() {
+		t.Errorf("unexpected transitions %+v", got.History)
+	}
+	// address listing reflects the state as well
+	if txs := bp.GetTransactions(callWallet); len(txs) != 1 || txs[0].State != StateConfirmed {
+		t.Errorf("unexpected transactions %+v", txs)
+	}
+	if len(bp.unconfirmed) != 0 {
+		t.Errorf("confirmed block is still indexed %v", bp.unconfirmed)
+	}
+}
+
+func TestBlockParser_lifecycle_reorg(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{"eth_getBlockByNumber": `{"number": "0xa", "hash": "0xnew"}`})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+
+	tx := lifecycleTx("0xaa", lifecycleRecipient, "0x10", "0x7")
+	tx["blockNumber"] = "0xa"
+	if err := bp.processBlockTransactions(map[string]interface{}{"transactions": []interface{}{tx}}); err != nil {
+		t.Fatal(err)
+	}
+	bp.rememberBlock(10, "0xold")
+
+	bp.handleReorg(10)
+	if got, _ := bp.GetTransaction("0xaa"); got.State != StateReorged {
+		t.Fatalf("transaction of orphaned block should be reorged, got %s", got.State)
+	}
+
+	// included again by the new chain
+	tx["blockNumber"] = "0xb"
+	if err := bp.processBlockTransactions(map[string]interface{}{"transactions": []interface{}{tx}}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := bp.GetTransaction("0xaa")
+	if !reflect.DeepEqual(states(got), []string{StateIncluded, StateReorged, StateIncluded}) || got.BlockNumber != 11 {
+		t.Errorf("unexpected transaction %+v", got)
+	}
+	if txs := bp.GetTransactions(callWallet); len(txs) != 1 {
+		t.Errorf("re-included transaction should not be duplicated, got %+v", txs)
+	}
+
+	// blocks still on the chain are left alone
+	bp.rememberBlock(11, "0xnew")
+	bp.handleReorg(11)
+	if got, _ := bp.GetTransaction("0xaa"); got.State != StateIncluded {
+		t.Errorf("transaction of canonical block should stay included, got %s", got.State)
+	}
+}
+
+func TestBlockParser_lifecycle_reorg_reincluded(t *testing.T) {
+	logging.Init("debug")
+
+	tx := lifecycleTx("0xaa", lifecycleRecipient, "0x10", "0x7")
+	tx["blockNumber"] = "0xa"
+	// the block replacing the orphaned one includes the transaction again
+	replacement, _ := json.Marshal(map[string]interface{}{"number": "0xa", "hash": "0xnew", "timestamp": "0x6738fe6f", "transactions": []interface{}{tx}})
+	rpc := mockRPC(t, map[string]string{"eth_getBlockByNumber": string(replacement)})
+	bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithConfirmations(3)
+	bp.Subscribe(callWallet)
+
+	if err := bp.processBlockTransactions(map[string]interface{}{"transactions": []interface{}{tx}}); err != nil {
+		t.Fatal(err)
+	}
+	bp.rememberBlock(10, "0xold")
+
+	bp.handleReorg(10)
+	got, _ := bp.GetTransaction("0xaa")
+	if !reflect.DeepEqual(states(got), []string{StateIncluded, StateReorged, StateIncluded}) || got.BlockNumber != 10 {
+		t.Errorf("unexpected transaction %+v", got)
+	}
+	if hash := bp.blockHashes[10]; hash != "0xnew" {
+		t.Errorf("replacement block was not remembered, got %s", hash)
+	}
+
+	bp.confirmTransactions(12)
+	if got, _ := bp.GetTransaction("0xaa"); got.State != StateConfirmed {
+		t.Errorf("re-included transaction should be confirmed, got %s", got.State)
+	}
+}
+
+func TestBlockParser_lifecycle_dropped(t *testing.T) {
+	logging.Init("debug")
+
+	tx := lifecycleTx("0xaa", lifecycleRecipient, "0x10", "0x7")
+	rpc := mockRPC(t, pendingTxpool(tx))
+	bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithMempool(MempoolModeTxpool, 0).WithDropTimeout(time.Minute)
+	bp.Subscribe(callWallet)
+	if err := bp.processMempool(); err != nil {
+		t.Fatal(err)
+	}
+
+	bp.mu.Lock()
+	bp.dropMissing(time.Now().Add(30 * time.Second))
+	bp.mu.Unlock()
+	if got, _ := bp.GetTransaction("0xaa"); got.State != StatePending {
+		t.Errorf("recently seen transaction should stay pending, got %s", got.State)
+	}
+
+	bp.mu.Lock()
+	bp.dropMissing(time.Now().Add(2 * time.Minute))
+	bp.mu.Unlock()
+	if got, _ := bp.GetTransaction("0xaa"); got.State != StateDropped {
+		t.Errorf("transaction missing from the mempool should be dropped, got %s", got.State)
+	}
+
+	// rebroadcast
+	if err := bp.processMempool(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := bp.GetTransaction("0xaa")
+	if !reflect.DeepEqual(states(got), []string{StatePending, StateDropped, StatePending}) {
+		t.Errorf("unexpected history %v", states(got))
+	}
+}
+
+func TestBlockParser_lifecycle_reorg_replay(t *testing.T) {
+	logging.Init("debug")
+
+	block := map[string]interface{}{
+		"number":       "0x14386af",
+		"hash":         "0xold",
+		"timestamp":    "0x6738fe6f",
+		"transactions": []interface{}{},
+		"withdrawals":  []interface{}{map[string]interface{}{"index": "0x1", "validatorIndex": "0x2", "address": traceWallet, "amount": "0x3"}},
+	}
+	replacement := map[string]interface{}{}
+	for k, v := range block {
+		replacement[k] = v
+	}
+	replacement["hash"] = "0xnew"
+	replacementJSON, _ := json.Marshal(replacement)
+	results := map[string]string{
+		"eth_getBlockByNumber": string(replacementJSON),
+		"eth_getLogs":          nftLogs,
+		"trace_block":          traceBlockResult,
+	}
+	rpc := mockRPC(t, results)
+	bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithTraceMode(TraceModeParity)
+	bp.Subscribe(traceWallet)
+	bp.store.StoreTokenBalance(traceWallet, TokenBalance{Contract: callToken, Balance: "5000"})
+
+	counts := func() (transfers, withdrawals, internal int) {
+		for _, tx := range bp.GetTransactions(traceWallet) {
+			switch tx.Kind {
+			case KindWithdrawal:
+				withdrawals++
+			case KindInternal:
+				internal++
+			}
+		}
+		return len(bp.GetNFTTransfers(nftOwner)), withdrawals, internal
+	}
+	check := func(stage string, transfers, withdrawals, internal int, holdings []NFTHolding, tokenBalance string) {
+		t.Helper()
+		if gotTransfers, gotWithdrawals, gotInternal := counts(); gotTransfers != transfers || gotWithdrawals != withdrawals || gotInternal != internal {
+			t.Errorf("%s: %d NFT transfers, %d withdrawals, %d internal transfers; want %d, %d, %d",
+				stage, gotTransfers, gotWithdrawals, gotInternal, transfers, withdrawals, internal)
+		}
+		if got := bp.GetNFTHoldings(nftOwner); !reflect.DeepEqual(got, holdings) {
+			t.Errorf("%s: holdings = %+v; want %+v", stage, got, holdings)
+		}
+		if b, _ := bp.store.TokenBalance(traceWallet, callToken); b.Balance != tokenBalance {
+			t.Errorf("%s: token balance = %s; want %s", stage, b.Balance, tokenBalance)
+		}
+	}
+
+	bp.processBlock(block)
+	bp.rememberBlock(0x14386af, "0xold")
+	// ERC-721 sent, 5 minted and 2 sent of ERC-1155 token 7, USDT transfer of 1000
+	holdings := []NFTHolding{{Standard: StandardERC1155, Contract: nftContract, TokenID: "7", Amount: "3"}}
+	check("processed", 4, 1, 2, holdings, "4000")
+
+	// the replacement block has the same content
+	bp.handleReorg(0x14386af)
+	check("replayed", 4, 1, 2, holdings, "4000")
+
+	// activity existing only in the orphaned block is removed
+	replacement["hash"] = "0xnewer"
+	delete(replacement, "withdrawals")
+	replacementJSON, _ = json.Marshal(replacement)
+	results["eth_getBlockByNumber"] = string(replacementJSON)
+	results["eth_getLogs"] = "[]"
+	results["trace_block"] = "[]"
+	bp.handleReorg(0x14386af)
+	check("orphaned", 0, 0, 0, []NFTHolding{}, "5000")
+}
+
+func TestBlockParser_catchUp(t *testing.T) {
+	logging.Init("debug")
+
+	var mu sync.Mutex
+	fetched := map[int]int{}
+	rpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "eth_getBlockByNumber" {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+		n := hexToInt(req.Params[0].(string))
+		mu.Lock()
+		fetched[n]++
+		mu.Unlock()
+		w.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x%x","hash":"0xh%d","parentHash":"0xh%d","transactions":[]}}`, n, n, n-1)))
+	}))
+	t.Cleanup(rpc.Close)
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage(), currentBlock: -1, running: true}
+
+	// the first synchronised block is the latest one
+	bp.catchUp(10)
+	if bp.GetCurrentBlock() != 10 || !reflect.DeepEqual(fetched, map[int]int{10: 1}) {
+		t.Fatalf("current block %d, fetched %v; want 10, only block 10", bp.GetCurrentBlock(), fetched)
+	}
+
+	// skipped blocks are processed one by one without being taken for a reorg
+	bp.catchUp(13)
+	if bp.GetCurrentBlock() != 13 || !reflect.DeepEqual(fetched, map[int]int{10: 1, 11: 1, 12: 1, 13: 1}) {
+		t.Fatalf("current block %d, fetched %v; want 13, every block once", bp.GetCurrentBlock(), fetched)
+	}
+	for n := 10; n <= 13; n++ {
+		if hash := bp.blockHashes[n]; hash != fmt.Sprintf("0xh%d", n) {
+			t.Errorf("block %d remembered with hash %q", n, hash)
+		}
+	}
+
+	// a parent hash mismatch walks back the processed blocks
+	bp.rememberBlock(13, "0xorphaned")
+	bp.catchUp(14)
+	if fetched[13] != 2 || bp.blockHashes[13] != "0xh13" || bp.GetCurrentBlock() != 14 {
+		t.Errorf("orphaned block should be replaced, fetched %v, hash %q", fetched, bp.blockHashes[13])
+	}
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"time"
+)
+
+const (
+	MempoolModeBlock  = "block"  // eth_getBlockByNumber("pending")
+	MempoolModeTxpool = "txpool" // txpool_content (geth, erigon, reth...)
+)
+
+// WithMempool enables tracking of pending transactions using the given source, polled every interval.
+//
+// Empty mode disables mempool tracking.
+func (bp *BlockParser) WithMempool(mode string, interval time.Duration) *BlockParser {
+	switch mode {
+	case "", MempoolModeBlock, MempoolModeTxpool:
+		bp.mempoolMode = mode
+	default:
+		L.L.Warn("Unknown mempool mode", mode, "pending transactions will not be tracked")
+		bp.mempoolMode = ""
+	}
+	bp.mempoolInterval = interval
+	return bp
+}
+
+// WatchMempool polls the mempool for pending transactions touching observed addresses until
+// block synchronisation is stopped. It returns immediately when mempool tracking is disabled.
+func (bp *BlockParser) WatchMempool() {
+	if bp.mempoolMode == "" {
+		return
+	}
+	interval := bp.mempoolInterval
+	if interval <= 0 {
+		interval = bp.parseInterval
+	}
+
+	stop := bp.stopped()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := bp.processMempool(); err != nil {
+			L.L.Error("Processing mempool failed:", err.Error())
+		}
+	}
+}
+
+// processMempool fetches pending transactions and stores the ones involving observed addresses.
+func (bp *BlockParser) processMempool() error {
+	var pending []interface{}
+	var err error
+	switch bp.mempoolMode {
+	case MempoolModeBlock:
+		pending, err = bp.pendingBlockTransactions()
+	case MempoolModeTxpool:
+		pending, err = bp.txpoolTransactions()
+	}
+	if err != nil {
+		return err
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if bp.mempoolSeen == nil {
+		bp.mempoolSeen = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for _, tx := range pending {
+		txMap, ok := tx.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		txObj, ok := transactionFromMap(txMap)
+		if !ok {
+			continue
+		}
+		// already known, either pending or included
+		if known, exists := bp.store.PendingTransaction(txObj.Hash); exists {
+			bp.mempoolSeen[txObj.Hash] = now
+			if known.State == StateDropped {
+				// rebroadcast
+				setState(&known, StatePending, 0)
+				bp.store.UpdateTransaction(known)
+			}
+			continue
+		}
+		if _, exists := bp.store.Transaction(txObj.Hash); exists {
+			continue
+		}
+		if !bp.store.IsObserved(txObj.From) && !bp.store.IsObserved(txObj.To) {
+			continue
+		}
+
+		txObj.BlockNumber = 0
+		setState(&txObj, StatePending, 0)
+		bp.mempoolSeen[txObj.Hash] = now
+		bp.decodeCall(&txObj)
+		if bp.store.IsObserved(txObj.From) {
+			L.L.Info("New pending transaction for", txObj.From)
+			bp.store.StorePendingTransaction(txObj.From, txObj)
+		}
+		if txObj.To != txObj.From && bp.store.IsObserved(txObj.To) {
+			L.L.Info("New pending transaction for", txObj.To)
+			bp.store.StorePendingTransaction(txObj.To, txObj)
+		}
+		bp.markReplacements(txObj)
+	}
+	bp.dropMissing(now)
+	L.L.Debug(fmt.Sprintf("Processed %d pending transactions", len(pending)))
+	return nil
+}
+
+// pendingBlockTransactions returns transactions of the block the node would build next.
+func (bp *BlockParser) pendingBlockTransactions() ([]interface{}, error) {
+	result, err := bp.call("eth_getBlockByNumber", "pending", true)
+	if err != nil {
+		return nil, err
+	}
+	block, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid pending block: %v", result)
+	}
+	transactions, ok := block["transactions"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed parsing pending block transactions field")
+	}
+	return transactions, nil
+}
+
+// txpoolTransactions returns executable transactions of the node transaction pool.
+//
+// Queued transactions (nonce gaps) are ignored as they cannot be included yet.
+func (bp *BlockParser) txpoolTransactions() ([]interface{}, error) {
+	result, err := bp.call("txpool_content")
+	if err != nil {
+		return nil, err
+	}
+	content, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid txpool_content result: %v", result)
+	}
+
+	// pending: {sender: {nonce: tx}}
+	senders, _ := content["pending"].(map[string]interface{})
+	var transactions []interface{}
+	for _, s := range senders {
+		byNonce, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, tx := range byNonce {
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions, nil
+}
+
+// GetPendingTransactions returns transactions sent from or to the address that are not included in a block,
+// i.e. pending, dropped or replaced ones.
+func (bp *BlockParser) GetPendingTransactions(address string) []Transaction {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	txs := bp.store.PendingTransactions(normalizeAddress(address))
+	out := make([]Transaction, len(txs))
+	for i, tx := range txs {
+		out[i] = bp.withAddressInfo(tx)
+	}
+	return out
+}
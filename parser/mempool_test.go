@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"testing"
+	"time"
+)
+
+const txpoolContent = `{
+    "pending": {
+        "0x98C3d3183C4b8A650614ad179A1a98be0a8d6B8E": {
+            "7": {"hash": "0xaa", "from": "0x98C3d3183C4b8A650614ad179A1a98be0a8d6B8E", "to": "0xdac17f958d2ee523a2206206994597c13d831ec7", "value": "0x0", "input": "0x", "blockNumber": null, "nonce": "0x7"}
+        },
+        "0x0000000000000000000000000000000000000001": {
+            "1": {"hash": "0xbb", "from": "0x0000000000000000000000000000000000000001", "to": "0x0000000000000000000000000000000000000002", "value": "0x1", "input": "0x", "blockNumber": null, "nonce": "0x1"}
+        }
+    },
+    "queued": {
+        "0x98C3d3183C4b8A650614ad179A1a98be0a8d6B8E": {
+            "9": {"hash": "0xcc", "from": "0x98C3d3183C4b8A650614ad179A1a98be0a8d6B8E", "to": "0xdac17f958d2ee523a2206206994597c13d831ec7", "value": "0x0", "input": "0x", "blockNumber": null, "nonce": "0x9"}
+        }
+    }
+}`
+
+const pendingBlock = `{
+    "number": null,
+    "transactions": [
+        {"hash": "0xaa", "from": "0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e", "to": "0xdac17f958d2ee523a2206206994597c13d831ec7", "value": "0x0", "input": "0x", "blockNumber": null},
+        {"hash": "0xbb", "from": "0x0000000000000000000000000000000000000001", "to": "0x0000000000000000000000000000000000000002", "value": "0x1", "input": "0x", "blockNumber": null}
+    ]
+}`
+
+func TestBlockParser_processMempool(t *testing.T) {
+	logging.Init("debug")
+
+	for mode, rpcResults := range map[string]map[string]string{
+		MempoolModeTxpool: {"txpool_content": txpoolContent},
+		MempoolModeBlock:  {"eth_getBlockByNumber": pendingBlock},
+	} {
+		t.Run(mode, func(t *testing.T) {
+			rpc := mockRPC(t, rpcResults)
+			bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithMempool(mode, 0)
+			bp.Subscribe(callWallet)
+
+			if err := bp.processMempool(); err != nil {
+				t.Fatal(err)
+			}
+			// repeated polls must not duplicate entries
+			if err := bp.processMempool(); err != nil {
+				t.Fatal(err)
+			}
+
+			pending := bp.GetPendingTransactions(callWallet)
+			if len(pending) != 1 || pending[0].Hash != "0xaa" || pending[0].State != StatePending || pending[0].From != callWallet {
+				t.Fatalf("expected single pending transaction 0xaa, got %+v", pending)
+			}
+			if tx, ok := bp.GetTransaction("0xaa"); !ok || tx.State != StatePending {
+				t.Errorf("pending transaction should be returned by hash, got %+v", tx)
+			}
+			if len(bp.GetTransactions(callWallet)) != 0 {
+				t.Errorf("pending transaction should not be listed as included")
+			}
+
+			// transaction gets included
+			block := map[string]interface{}{"transactions": []interface{}{
+				map[string]interface{}{"hash": "0xaa", "from": callWallet, "to": callToken, "value": "0x0", "blockNumber": "0x10"},
+			}}
+			if err := bp.processBlockTransactions(block); err != nil {
+				t.Fatal(err)
+			}
+			if pending := bp.GetPendingTransactions(callWallet); len(pending) != 0 {
+				t.Errorf("included transaction should no longer be pending, got %+v", pending)
+			}
+			txs := bp.GetTransactions(callWallet)
+			if len(txs) != 1 || txs[0].State != StateIncluded || txs[0].BlockNumber != 0x10 {
+				t.Errorf("expected included transaction, got %+v", txs)
+			}
+
+			// the mempool may still report the transaction for a while
+			if err := bp.processMempool(); err != nil {
+				t.Fatal(err)
+			}
+			if pending := bp.GetPendingTransactions(callWallet); len(pending) != 0 {
+				t.Errorf("included transaction should not become pending again, got %+v", pending)
+			}
+		})
+	}
+}
+
+func TestBlockParser_WithMempool(t *testing.T) {
+	logging.Init("debug")
+
+	bp := (&BlockParser{}).WithMempool("websocket", 0)
+	if bp.mempoolMode != "" {
+		t.Errorf("unknown mode should disable mempool tracking, got %q", bp.mempoolMode)
+	}
+	// returns immediately when disabled
+	bp.WatchMempool()
+}
+
+func TestBlockParser_WatchMempool_Stop(t *testing.T) {
+	logging.Init("debug")
+
+	bp := (&BlockParser{store: NewTransactionStorage(), rpcURL: mockRPC(t, map[string]string{"eth_getBlockByNumber": `{"transactions": []}`}).URL}).
+		WithMempool(MempoolModeBlock, time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		bp.WatchMempool()
+		close(done)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	bp.StopSynchronisingBlocks()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher kept running after synchronisation was stopped")
+	}
+	if bp.running {
+		t.Error("watcher restarted block synchronisation")
+	}
+}
@@ -0,0 +1,357 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Event signatures (topic0) of the supported NFT transfer events.
+const (
+	// Transfer(address,address,uint256) - shared by ERC-20 and ERC-721, ERC-721 has the token id indexed
+	transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	// TransferSingle(address,address,address,uint256,uint256)
+	transferSingleTopic = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+	// TransferBatch(address,address,address,uint256[],uint256[])
+	transferBatchTopic = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+)
+
+// addressLogFilters select the logs processed by processBlockLogs by the position of the topic holding an address
+// which must be subscribed for the log to be of interest.
+var addressLogFilters = []struct {
+	position int      // index of the address topic
+	events   []string // event signatures (topic0) with an address at the position
+}{
+	// ERC-20 and ERC-721 sender, approval owner
+	{position: 1, events: []string{transferTopic, approvalTopic, approvalForAllTopic}},
+	// ERC-20 and ERC-721 receiver, ERC-1155 sender, user operation sender
+	{position: 2, events: []string{transferTopic, transferSingleTopic, transferBatchTopic, userOperationEventTopic}},
+	// ERC-1155 receiver
+	{position: 3, events: []string{transferSingleTopic, transferBatchTopic}},
+}
+
+const (
+	StandardERC20   = "ERC-20"
+	StandardERC721  = "ERC-721"
+	StandardERC1155 = "ERC-1155"
+)
+
+// NFTTransfer describes a single ERC-721 or ERC-1155 token movement
+type NFTTransfer struct {
+	Standard    string `json:"standard"`              // ERC-721 or ERC-1155
+	Contract    string `json:"contract"`              // Token contract address
+	From        string `json:"from"`                  // Previous owner (zero address on mint)
+	To          string `json:"to"`                    // New owner (zero address on burn)
+	TokenID     string `json:"tokenId"`               // Token id as decimal string
+	Amount      string `json:"amount"`                // Number of tokens moved as decimal string (always 1 for ERC-721)
+	TxHash      string `json:"txHash,omitempty"`      // Transaction that emitted the event
+	LogIndex    int    `json:"logIndex"`              // Position of the event in the block
+	BlockNumber int    `json:"blockNumber,omitempty"` // Block in which the transfer happened
+}
+
+// NFTHolding is a token currently owned by an address, derived from observed transfers
+type NFTHolding struct {
+	Standard string `json:"standard"`
+	Contract string `json:"contract"`
+	TokenID  string `json:"tokenId"`
+	Amount   string `json:"amount"`
+}
+
+// GetNFTTransfers returns NFT transfers involving the address.
+func (bp *BlockParser) GetNFTTransfers(address string) []NFTTransfer {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	transfers := bp.store.NFTTransfers(normalizeAddress(address))
+	if transfers == nil {
+		return []NFTTransfer{}
+	}
+	return transfers
+}
+
+// GetNFTHoldings returns NFTs currently owned by the address.
+//
+// Ownership is derived only from transfers observed while the address was subscribed.
+func (bp *BlockParser) GetNFTHoldings(address string) []NFTHolding {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	holdings := bp.store.NFTHoldings(normalizeAddress(address))
+	if holdings == nil {
+		return []NFTHolding{}
+	}
+	return holdings
+}
+
+// processBlockLogs fetches token transfer, approval and user operation logs of a block involving observed addresses,
+// stores NFT transfers and user operations and applies ERC-20 transfers and approvals of observed owners to their
+// balances and allowances.
+func (bp *BlockParser) processBlockLogs(blockData map[string]interface{}) error {
+	blockNumber, ok := blockData["number"].(string)
+	if !ok {
+		return fmt.Errorf("failed parsing block.result number field")
+	}
+
+	bp.mu.Lock()
+	addresses := bp.store.ObservedAddresses()
+	bp.mu.Unlock()
+	if len(addresses) == 0 {
+		return nil
+	}
+	logs, err := bp.getAddressLogs(blockNumber, addresses)
+	if err != nil {
+		return err
+	}
+
+	var tokens []string
+	var bundlers map[string]string
+	bp.mu.Lock()
+	defer func() {
+		bp.mu.Unlock()
+		for _, contract := range tokens {
+			bp.resolveToken(contract)
+		}
+	}()
+	for _, log := range logs {
+		if t, ok := tokenTransferFromLog(log); ok {
+			if bp.store.IsObserved(t.from) || bp.store.IsObserved(t.to) {
+				tokens = append(tokens, t.contract)
+				txHash, _ := log["transactionHash"].(string)
+				logIndex, _ := log["logIndex"].(string)
+				bp.screenTransfer(t.from, t.to, txHash, hexToInt(blockNumber))
+				bp.queueRuleEntry(ruleEntry{
+					key:         txHash + "/" + logIndex,
+					tx:          Transaction{Hash: txHash, From: t.from, To: t.to, BlockNumber: hexToInt(blockNumber), Kind: KindTokenTransfer},
+					token:       t.contract,
+					tokenAmount: t.amount,
+				})
+			}
+			bp.applyTokenTransfer(t, hexToInt(blockNumber))
+			continue
+		}
+		if op, ok := userOperationFromLog(log); ok {
+			if bp.store.IsObserved(op.Sender) {
+				if bundlers == nil {
+					bundlers = blockSenders(blockData)
+				}
+				op.Bundler = bundlers[op.TxHash]
+				L.L.Info("New user operation for", op.Sender)
+				bp.store.StoreUserOperation(op.Sender, op)
+			}
+			continue
+		}
+		if a, ok := approvalFromLog(log); ok {
+			if bp.store.IsObserved(a.owner) {
+				if a.allowance.Standard == StandardERC20 {
+					tokens = append(tokens, a.allowance.Contract)
+				}
+				bp.applyApproval(a)
+			}
+			continue
+		}
+		for _, t := range nftTransfersFromLog(log) {
+			bp.screenTransfer(t.From, t.To, t.TxHash, t.BlockNumber)
+			if bp.store.IsObserved(t.From) || bp.store.IsObserved(t.To) {
+				amount, _ := new(big.Int).SetString(t.Amount, 10)
+				bp.queueRuleEntry(ruleEntry{
+					key:         fmt.Sprintf("%s/%d/%s", t.TxHash, t.LogIndex, t.TokenID),
+					tx:          Transaction{Hash: t.TxHash, From: t.From, To: t.To, BlockNumber: t.BlockNumber, Kind: KindNFTTransfer},
+					token:       t.Contract,
+					tokenAmount: amount,
+				})
+			}
+			if bp.store.IsObserved(t.From) {
+				L.L.Info("New NFT transfer for", t.From)
+				bp.store.StoreNFTTransfer(t.From, t)
+			}
+			if t.To != t.From && bp.store.IsObserved(t.To) {
+				L.L.Info("New NFT transfer for", t.To)
+				bp.store.StoreNFTTransfer(t.To, t)
+			}
+		}
+	}
+	return nil
+}
+
+// getAddressLogs returns logs of a single block matched by addressLogFilters for any of the addresses, ordered by
+// their position in the block.
+func (bp *BlockParser) getAddressLogs(blockNumber string, addresses []string) ([]map[string]interface{}, error) {
+	addressTopics := make([]string, len(addresses))
+	for i, address := range addresses {
+		addressTopics[i] = addressToTopic(address)
+	}
+
+	var logs []map[string]interface{}
+	seen := make(map[string]bool)
+	for _, f := range addressLogFilters {
+		topics := make([]interface{}, f.position+1)
+		topics[0] = f.events
+		topics[f.position] = addressTopics
+		matched, err := bp.getLogs(blockNumber, topics)
+		if err != nil {
+			return nil, err
+		}
+		// a log with subscribed addresses at several positions is matched more than once
+		for _, log := range matched {
+			txHash, _ := log["transactionHash"].(string)
+			logIndex, _ := log["logIndex"].(string)
+			if key := txHash + "/" + logIndex; !seen[key] {
+				seen[key] = true
+				logs = append(logs, log)
+			}
+		}
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		a, _ := logs[i]["logIndex"].(string)
+		b, _ := logs[j]["logIndex"].(string)
+		return hexToInt(a) < hexToInt(b)
+	})
+	return logs, nil
+}
+
+// getLogs returns logs of a single block matching the topics filter, nil positions match any topic.
+func (bp *BlockParser) getLogs(blockNumber string, topics []interface{}) ([]map[string]interface{}, error) {
+	filter := map[string]interface{}{
+		"fromBlock": blockNumber,
+		"toBlock":   blockNumber,
+		"topics":    topics,
+	}
+	result, err := bp.call("eth_getLogs", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rawLogs, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid eth_getLogs result: %v", result)
+	}
+
+	logs := make([]map[string]interface{}, 0, len(rawLogs))
+	for _, l := range rawLogs {
+		if log, ok := l.(map[string]interface{}); ok {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// nftTransfersFromLog decodes ERC-721 Transfer and ERC-1155 TransferSingle/TransferBatch logs.
+//
+// Logs of any other kind (ERC-20 Transfer included) yield nil.
+func nftTransfersFromLog(log map[string]interface{}) []NFTTransfer {
+	if removed, _ := log["removed"].(bool); removed {
+		return nil
+	}
+
+	rawTopics, _ := log["topics"].([]interface{})
+	topics := make([]string, 0, len(rawTopics))
+	for _, t := range rawTopics {
+		s, _ := t.(string)
+		topics = append(topics, s)
+	}
+	if len(topics) == 0 {
+		return nil
+	}
+
+	contract, _ := log["address"].(string)
+	contract = normalizeAddress(contract)
+	data, _ := log["data"].(string)
+	txHash, _ := log["transactionHash"].(string)
+	logIndex, _ := log["logIndex"].(string)
+	blockNumber, _ := log["blockNumber"].(string)
+
+	base := NFTTransfer{
+		Contract:    contract,
+		TxHash:      txHash,
+		LogIndex:    hexToInt(logIndex),
+		BlockNumber: hexToInt(blockNumber),
+	}
+
+	words := dataWords(data)
+	switch {
+	case topics[0] == transferTopic && len(topics) == 4:
+		t := base
+		t.Standard = StandardERC721
+		t.From = topicToAddress(topics[1])
+		t.To = topicToAddress(topics[2])
+		t.TokenID = hexToBig(topics[3]).String()
+		t.Amount = "1"
+		return []NFTTransfer{t}
+
+	case topics[0] == transferSingleTopic && len(topics) == 4 && len(words) >= 2:
+		t := base
+		t.Standard = StandardERC1155
+		t.From = topicToAddress(topics[2])
+		t.To = topicToAddress(topics[3])
+		t.TokenID = hexToBig(words[0]).String()
+		t.Amount = hexToBig(words[1]).String()
+		return []NFTTransfer{t}
+
+	case topics[0] == transferBatchTopic && len(topics) == 4 && len(words) >= 2:
+		ids := wordArray(words, words[0])
+		values := wordArray(words, words[1])
+		if ids == nil || len(ids) != len(values) {
+			L.L.Warn("Malformed TransferBatch log in", txHash)
+			return nil
+		}
+		transfers := make([]NFTTransfer, 0, len(ids))
+		for i := range ids {
+			t := base
+			t.Standard = StandardERC1155
+			t.From = topicToAddress(topics[2])
+			t.To = topicToAddress(topics[3])
+			t.TokenID = hexToBig(ids[i]).String()
+			t.Amount = hexToBig(values[i]).String()
+			transfers = append(transfers, t)
+		}
+		return transfers
+	}
+	return nil
+}
+
+// wordArray reads an ABI encoded dynamic array starting at byte offset `offsetWord` of the data words.
+func wordArray(words []string, offsetWord string) []string {
+	offset := hexToBig(offsetWord)
+	if !offset.IsInt64() || offset.Int64()%32 != 0 {
+		return nil
+	}
+	start := int(offset.Int64() / 32)
+	if start >= len(words) {
+		return nil
+	}
+	// length comes from the log, compare it before any int arithmetic can overflow
+	length := hexToBig(words[start])
+	if length.Cmp(big.NewInt(int64(len(words)-start-1))) > 0 {
+		return nil
+	}
+	return words[start+1 : start+1+int(length.Int64())]
+}
+
+// applyNFTTransfer updates the holdings of address according to the transfer.
+func applyNFTTransfer(holdings map[string]*big.Int, address string, t NFTTransfer) {
+	key := t.Standard + "/" + t.Contract + "/" + t.TokenID
+	amount, ok := new(big.Int).SetString(t.Amount, 10)
+	if !ok {
+		return
+	}
+
+	balance, exists := holdings[key]
+	if !exists {
+		balance = new(big.Int)
+	}
+	if t.From == address {
+		balance.Sub(balance, amount)
+	}
+	if t.To == address {
+		balance.Add(balance, amount)
+	}
+	// ERC-721 tokens are unique, an inbound transfer always means ownership
+	if t.Standard == StandardERC721 && t.To == address {
+		balance.SetInt64(1)
+	}
+
+	if balance.Sign() <= 0 {
+		delete(holdings, key)
+		return
+	}
+	holdings[key] = balance
+}
@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"encoding/json"
+	"ethTx/cmd/util/logging"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	nftOwner    = "0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e"
+	nftReceiver = "0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f"
+	nftContract = "0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"
+)
+
+func padTopic(addr string) string {
+	return "0x000000000000000000000000" + addr[2:]
+}
+
+var nftLogs = `[
+    {
+        "address": "` + nftContract + `",
+        "topics": [
+            "` + transferTopic + `",
+            "` + padTopic(nftOwner) + `",
+            "` + padTopic(nftReceiver) + `",
+            "0x000000000000000000000000000000000000000000000000000000000000002a"
+        ],
+        "data": "0x",
+        "blockNumber": "0x14386af",
+        "transactionHash": "0x01",
+        "logIndex": "0x1"
+    },
+    {
+        "address": "0xdac17f958d2ee523a2206206994597c13d831ec7",
+        "topics": [
+            "` + transferTopic + `",
+            "` + padTopic(nftOwner) + `",
+            "` + padTopic(nftReceiver) + `"
+        ],
+        "data": "0x00000000000000000000000000000000000000000000000000000000000003e8",
+        "blockNumber": "0x14386af",
+        "transactionHash": "0x02",
+        "logIndex": "0x2"
+    },
+    {
+        "address": "` + nftContract + `",
+        "topics": [
+            "` + transferSingleTopic + `",
+            "` + padTopic(nftOwner) + `",
+            "0x0000000000000000000000000000000000000000000000000000000000000000",
+            "` + padTopic(nftOwner) + `"
+        ],
+        "data": "0x00000000000000000000000000000000000000000000000000000000000000070000000000000000000000000000000000000000000000000000000000000005",
+        "blockNumber": "0x14386af",
+        "transactionHash": "0x03",
+        "logIndex": "0x3"
+    },
+    {
+        "address": "` + nftContract + `",
+        "topics": [
+            "` + transferBatchTopic + `",
+            "` + padTopic(nftOwner) + `",
+            "` + padTopic(nftOwner) + `",
+            "` + padTopic(nftReceiver) + `"
+        ],
+        "data": "0x000000000000000000000000000000000000000000000000000000000000004000000000000000000000000000000000000000000000000000000000000000a000000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000007000000000000000000000000000000000000000000000000000000000000000800000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000a",
+        "blockNumber": "0x14386af",
+        "transactionHash": "0x04",
+        "logIndex": "0x4"
+    },
+    {
+        "address": "` + nftContract + `",
+        "topics": [
+            "` + transferBatchTopic + `",
+            "` + padTopic(nftOwner) + `",
+            "` + padTopic(nftOwner) + `",
+            "` + padTopic(nftReceiver) + `"
+        ],
+        "data": "0x000000000000000000000000000000000000000000000000000000000000004000000000000000000000000000000000000000000000000000000000000000600000000000000000000000000000000000000000000000007fffffffffffffff0000000000000000000000000000000000000000000000000000000000000000",
+        "blockNumber": "0x14386af",
+        "transactionHash": "0x05",
+        "logIndex": "0x5"
+    }
+]`
+
+func TestNFTTransfersFromLog(t *testing.T) {
+	logging.Init("debug")
+	var logs []map[string]interface{}
+	if err := json.Unmarshal([]byte(nftLogs), &logs); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		log  map[string]interface{}
+		want []NFTTransfer
+	}{
+		{
+			name: "ERC-721 transfer",
+			log:  logs[0],
+			want: []NFTTransfer{{Standard: StandardERC721, Contract: nftContract, From: nftOwner, To: nftReceiver, TokenID: "42", Amount: "1", TxHash: "0x01", LogIndex: 1, BlockNumber: 0x14386af}},
+		},
+		{
+			name: "ERC-20 transfer is ignored",
+			log:  logs[1],
+			want: nil,
+		},
+		{
+			name: "ERC-1155 mint",
+			log:  logs[2],
+			want: []NFTTransfer{{Standard: StandardERC1155, Contract: nftContract, From: "0x0000000000000000000000000000000000000000", To: nftOwner, TokenID: "7", Amount: "5", TxHash: "0x03", LogIndex: 3, BlockNumber: 0x14386af}},
+		},
+		{
+			name: "ERC-1155 batch",
+			log:  logs[3],
+			want: []NFTTransfer{
+				{Standard: StandardERC1155, Contract: nftContract, From: nftOwner, To: nftReceiver, TokenID: "7", Amount: "2", TxHash: "0x04", LogIndex: 4, BlockNumber: 0x14386af},
+				{Standard: StandardERC1155, Contract: nftContract, From: nftOwner, To: nftReceiver, TokenID: "8", Amount: "10", TxHash: "0x04", LogIndex: 4, BlockNumber: 0x14386af},
+			},
+		},
+		{
+			// length word overflows int arithmetic on the array bounds
+			name: "ERC-1155 batch with crafted array length",
+			log:  logs[4],
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nftTransfersFromLog(tt.log)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d transfers, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("transfer %d = %+v; want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBlockParser_processBlockLogs(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{"eth_getLogs": nftLogs})
+
+	bp := &BlockParser{
+		mu:     sync.Mutex{},
+		rpcURL: rpc.URL,
+		store:  NewTransactionStorage(),
+	}
+	bp.Subscribe(nftOwner)
+
+	err := bp.processBlockLogs(map[string]interface{}{"number": "0x14386af"})
+	if err != nil {
+		t.Fatal("Failed processing block logs", err.Error())
+	}
+
+	if got := len(bp.GetNFTTransfers(nftOwner)); got != 4 {
+		t.Errorf("owner should have 4 NFT transfers, has %d", got)
+	}
+	if got := len(bp.GetNFTTransfers(nftReceiver)); got != 0 {
+		t.Errorf("unsubscribed receiver should have no NFT transfers, has %d", got)
+	}
+
+	// token 42 was sent away, 5 of token 7 were minted and 2 of them sent away
+	want := []NFTHolding{{Standard: StandardERC1155, Contract: nftContract, TokenID: "7", Amount: "3"}}
+	got := bp.GetNFTHoldings(nftOwner)
+	if len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("holdings = %+v; want %+v", got, want)
+	}
+}
+
+// mockLogsRPC serves the logs matching the topics filter of eth_getLogs requests and counts the requests.
+func mockLogsRPC(t *testing.T, logs string, requests *int) *httptest.Server {
+	t.Helper()
+	var all []map[string]interface{}
+	if err := json.Unmarshal([]byte(logs), &all); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params []struct {
+				Topics []interface{} `json:"topics"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		*requests++
+		matched := []map[string]interface{}{}
+		for _, log := range all {
+			topics, _ := log["topics"].([]interface{})
+			match := len(topics) >= len(req.Params[0].Topics)
+			for i, want := range req.Params[0].Topics {
+				if !match {
+					break
+				}
+				if options, ok := want.([]interface{}); ok {
+					match = false
+					for _, option := range options {
+						match = match || strings.EqualFold(option.(string), topics[i].(string))
+					}
+				}
+			}
+			if match {
+				matched = append(matched, log)
+			}
+		}
+		result, _ := json.Marshal(matched)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + string(result) + `}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBlockParser_getAddressLogs(t *testing.T) {
+	logging.Init("debug")
+
+	var requests int
+	rpc := mockLogsRPC(t, nftLogs, &requests)
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+
+	// nothing is requested without subscriptions
+	if err := bp.processBlockLogs(map[string]interface{}{"number": "0x14386af"}); err != nil || requests != 0 {
+		t.Fatalf("processBlockLogs() = %v with %d requests; want no requests", err, requests)
+	}
+
+	// the ERC-1155 mint of 0x03 does not involve the receiver
+	logs, err := bp.getAddressLogs("0x14386af", []string{nftReceiver})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, log := range logs {
+		got = append(got, log["transactionHash"].(string))
+	}
+	if strings.Join(got, ",") != "0x01,0x02,0x04,0x05" {
+		t.Errorf("logs of receiver = %v", got)
+	}
+
+	// a transfer between subscribed addresses is returned once, logs stay in block order
+	logs, err = bp.getAddressLogs("0x14386af", []string{nftOwner, nftReceiver})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, log := range logs {
+		got = append(got, log["transactionHash"].(string))
+	}
+	if strings.Join(got, ",") != "0x01,0x02,0x03,0x04,0x05" {
+		t.Errorf("logs of owner and receiver = %v", got)
+	}
+}
@@ -0,0 +1,142 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultStuckTimeout is the time after which a pending transaction is reported as stuck
+const DefaultStuckTimeout = 10 * time.Minute
+
+// WithStuckTimeout sets the time after which pending transactions of subscribed senders are reported as stuck.
+func (bp *BlockParser) WithStuckTimeout(timeout time.Duration) *BlockParser {
+	bp.stuckTimeout = timeout
+	return bp
+}
+
+// GetHighestMinedNonce returns the highest nonce of a subscribed sender known to be mined.
+func (bp *BlockParser) GetHighestMinedNonce(address string) (uint64, bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	nonce, ok := bp.minedNonces[normalizeAddress(address)]
+	return nonce, ok
+}
+
+// getTransactionCount returns the number of mined transactions sent by the address, i.e. its next nonce.
+func (bp *BlockParser) getTransactionCount(address string) (uint64, error) {
+	result, err := bp.call("eth_getTransactionCount", address, "latest")
+	if err != nil {
+		return 0, err
+	}
+	count, ok := result.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid eth_getTransactionCount result: %v", result)
+	}
+	return hexToBig(count).Uint64(), nil
+}
+
+// noteMinedNonce records the nonce of an included transaction sent by an observed address. Caller must hold bp.mu.
+func (bp *BlockParser) noteMinedNonce(tx Transaction) {
+	if tx.Nonce == "" || !bp.store.IsObserved(tx.From) {
+		return
+	}
+	bp.setMinedNonce(tx.From, hexToBig(tx.Nonce).Uint64())
+}
+
+func (bp *BlockParser) setMinedNonce(address string, nonce uint64) {
+	if bp.minedNonces == nil {
+		bp.minedNonces = make(map[string]uint64)
+	}
+	if highest, ok := bp.minedNonces[address]; !ok || nonce > highest {
+		bp.minedNonces[address] = nonce
+	}
+}
+
+// checkNonces looks for nonce gaps and long pending transactions of subscribed senders and raises alerts for them.
+func (bp *BlockParser) checkNonces(blockNumber int) {
+	bp.mu.Lock()
+	bySender := make(map[string][]Transaction)
+	for _, tx := range bp.store.TransactionsInState(StatePending) {
+		if tx.Nonce != "" && bp.store.IsObserved(tx.From) {
+			bySender[tx.From] = append(bySender[tx.From], tx)
+		}
+	}
+	bp.mu.Unlock()
+
+	for sender, pending := range bySender {
+		// the node knows about mined transactions we have not observed
+		count, err := bp.getTransactionCount(sender)
+		if err != nil {
+			L.L.Error("Failed fetching transaction count of", sender, "Error:", err.Error())
+		}
+
+		bp.mu.Lock()
+		if err == nil && count > 0 {
+			bp.setMinedNonce(sender, count-1)
+		}
+		// without any mined nonce the first expected one is unknown unless the node says there is none
+		next, known := uint64(0), err == nil
+		if highest, ok := bp.minedNonces[sender]; ok {
+			next, known = highest+1, true
+		}
+		if known {
+			bp.checkNonceGap(sender, pending, next, blockNumber)
+		}
+		bp.checkStuck(pending, blockNumber)
+		bp.mu.Unlock()
+	}
+}
+
+// checkNonceGap raises an alert when pending transactions of the sender skip a nonce after the next expected one.
+// Caller must hold bp.mu.
+func (bp *BlockParser) checkNonceGap(sender string, pending []Transaction, next uint64, blockNumber int) {
+	sort.Slice(pending, func(i, j int) bool {
+		return hexToBig(pending[i].Nonce).Cmp(hexToBig(pending[j].Nonce)) < 0
+	})
+	for _, tx := range pending {
+		nonce := hexToBig(tx.Nonce).Uint64()
+		switch {
+		case nonce < next:
+			// already used, the transaction will be replaced or dropped
+			continue
+		case nonce == next:
+			next++
+			continue
+		}
+		bp.raiseAlert(Alert{
+			Type:        AlertNonceGap,
+			Address:     sender,
+			TxHash:      tx.Hash,
+			BlockNumber: blockNumber,
+			Message:     fmt.Sprintf("nonce %d is missing, transaction %s with nonce %d and later ones are stuck", next, tx.Hash, nonce),
+		}, fmt.Sprintf("%s/%s/%d", AlertNonceGap, sender, next))
+		return
+	}
+}
+
+// checkStuck raises an alert for transactions pending longer than the stuck timeout. Caller must hold bp.mu.
+func (bp *BlockParser) checkStuck(pending []Transaction, blockNumber int) {
+	timeout := bp.stuckTimeout
+	if timeout <= 0 {
+		timeout = DefaultStuckTimeout
+	}
+	for _, tx := range pending {
+		if len(tx.History) == 0 {
+			continue
+		}
+		// time of the latest transition to pending
+		since := tx.History[len(tx.History)-1].Time
+		if time.Since(since) < timeout {
+			continue
+		}
+		bp.raiseAlert(Alert{
+			Type:        AlertStuckTransaction,
+			Address:     tx.From,
+			TxHash:      tx.Hash,
+			BlockNumber: blockNumber,
+			Message:     fmt.Sprintf("transaction with nonce %d has been pending since %s", hexToBig(tx.Nonce).Uint64(), since.Format(time.RFC3339)),
+		}, AlertStuckTransaction+"/"+tx.Hash+"/"+since.String())
+	}
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"testing"
+	"time"
+)
+
+func TestBlockParser_checkNonces(t *testing.T) {
+	logging.Init("debug")
+
+	newParser := func(t *testing.T, nonces ...string) *BlockParser {
+		var txs []map[string]interface{}
+		for _, n := range nonces {
+			txs = append(txs, lifecycleTx("0x"+n[2:]+"aa", lifecycleRecipient, "0x1", n))
+		}
+		results := pendingTxpool(txs...)
+		results["eth_getTransactionCount"] = `"0x5"`
+		rpc := mockRPC(t, results)
+
+		bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithMempool(MempoolModeTxpool, 0)
+		bp.Subscribe(callWallet)
+		if err := bp.processMempool(); err != nil {
+			t.Fatal(err)
+		}
+		return bp
+	}
+
+	t.Run("gap", func(t *testing.T) {
+		bp := newParser(t, "0x5", "0x7", "0x8")
+		bp.checkNonces(100)
+		bp.checkNonces(101)
+
+		alerts := bp.GetAlerts(callWallet)
+		if len(alerts) != 1 {
+			t.Fatalf("expected single alert, got %+v", alerts)
+		}
+		if a := alerts[0]; a.Type != AlertNonceGap || a.TxHash != "0x7aa" || a.BlockNumber != 100 || a.ID != 1 {
+			t.Errorf("unexpected alert %+v", a)
+		}
+		if nonce, ok := bp.GetHighestMinedNonce(callWallet); !ok || nonce != 4 {
+			t.Errorf("GetHighestMinedNonce = %d, %v; want 4", nonce, ok)
+		}
+		if len(bp.GetAlerts("")) != 1 || len(bp.GetAlerts(lifecycleRecipient)) != 0 {
+			t.Errorf("alerts should be filtered by address")
+		}
+	})
+
+	t.Run("no gap", func(t *testing.T) {
+		bp := newParser(t, "0x5", "0x6")
+		bp.checkNonces(100)
+		if alerts := bp.GetAlerts(""); len(alerts) != 0 {
+			t.Errorf("expected no alerts, got %+v", alerts)
+		}
+	})
+
+	t.Run("stuck", func(t *testing.T) {
+		bp := newParser(t, "0x5").WithStuckTimeout(time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		bp.checkNonces(100)
+		bp.checkNonces(101)
+		alerts := bp.GetAlerts(callWallet)
+		if len(alerts) != 1 || alerts[0].Type != AlertStuckTransaction || alerts[0].TxHash != "0x5aa" {
+			t.Errorf("expected single stuck transaction alert, got %+v", alerts)
+		}
+	})
+}
+
+func TestBlockParser_noteMinedNonce(t *testing.T) {
+	logging.Init("debug")
+
+	bp := &BlockParser{store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+	block := map[string]interface{}{"transactions": []interface{}{
+		lifecycleTx("0xaa", lifecycleRecipient, "0x1", "0x9"),
+		lifecycleTx("0xbb", lifecycleRecipient, "0x1", "0x3"),
+	}}
+	if err := bp.processBlockTransactions(block); err != nil {
+		t.Fatal(err)
+	}
+	if nonce, ok := bp.GetHighestMinedNonce(callWallet); !ok || nonce != 9 {
+		t.Errorf("GetHighestMinedNonce = %d, %v; want 9", nonce, ok)
+	}
+	if _, ok := bp.GetHighestMinedNonce(lifecycleRecipient); ok {
+		t.Errorf("nonce of unobserved sender should not be tracked")
+	}
+}
@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds delivery of a single alert notification
+const webhookTimeout = 10 * time.Second
+
+// WithAlertWebhook sets URL to which every raised alert is posted as JSON.
+//
+// Empty URL disables notifications, alerts are still available through GetAlerts.
+func (bp *BlockParser) WithAlertWebhook(url string) *BlockParser {
+	bp.alertWebhook = url
+	return bp
+}
+
+// notify posts the alert to the webhook. Addresses are EIP-55 checksummed as in API responses.
+func (bp *BlockParser) notify(a Alert) {
+	a.Address = ChecksumAddress(a.Address)
+	body, err := json.Marshal(a)
+	if err != nil {
+		L.L.Error("Failed encoding alert", fmt.Sprintf("%d", a.ID), "Error:", err.Error())
+		return
+	}
+
+	client := http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(bp.alertWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		L.L.Error("Failed sending alert", fmt.Sprintf("%d", a.ID), "Error:", err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		L.L.Error("Webhook rejected alert", fmt.Sprintf("%d", a.ID), "Status:", resp.Status)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	L "ethTx/cmd/util/logging"
+	"ethTx/parser/abi"
+	"ethTx/parser/sigdb"
 	"fmt"
+	"math/big"
 	"net/http"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 )
@@ -27,9 +31,54 @@ type Transaction struct {
 	To          string `json:"to,omitempty"`          // Recipient address
 	Value       string `json:"value,omitempty"`       // Amount transferred in Wei (string for large values)
 	BlockNumber int    `json:"blockNumber,omitempty"` // Block number in which the transaction was included
+	Timestamp   int64  `json:"timestamp,omitempty"`   // Unix time of the block in which the transaction was included
+	Kind        string `json:"kind,omitempty"`        // KindTransaction or KindInternal
+
+	FromInfo  *AddressInfo `json:"fromInfo,omitempty"`  // Classification of the sender
+	ToInfo    *AddressInfo `json:"toInfo,omitempty"`    // Classification of the recipient
+	FromName  string       `json:"fromName,omitempty"`  // Primary ENS name of the sender
+	ToName    string       `json:"toName,omitempty"`    // Primary ENS name of the recipient
+	FromLabel string       `json:"fromLabel,omitempty"` // Address book label of the sender
+	ToLabel   string       `json:"toLabel,omitempty"`   // Address book label of the recipient
+
+	ParentHash   string `json:"parentHash,omitempty"`   // Top-level transaction of an internal transfer
+	TraceAddress string `json:"traceAddress,omitempty"` // Position of an internal transfer in the call tree, e.g. "0.1"
+
+	ContractCreation bool   `json:"contractCreation,omitempty"` // Transaction deployed a contract (`to` is empty)
+	ContractAddress  string `json:"contractAddress,omitempty"`  // Address of the deployed contract
+
+	Withdrawal *Withdrawal `json:"withdrawal,omitempty"` // Beacon chain withdrawal details of KindWithdrawal entries
+
+	Input string    `json:"input,omitempty"` // Calldata
+	Call  *abi.Call `json:"call,omitempty"`  // Decoded calldata when contract ABI or method selector is known
+
+	Status            string `json:"status,omitempty"`            // Receipt status, 0x1 on success and 0x0 on failure
+	GasUsed           string `json:"gasUsed,omitempty"`           // Gas used by the transaction
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"` // Price per gas paid by the sender in Wei
+	BlobGasUsed       string `json:"blobGasUsed,omitempty"`       // Blob gas used by EIP-4844 transactions
+	BlobGasPrice      string `json:"blobGasPrice,omitempty"`      // Price per blob gas in Wei
+	Logs              []Log  `json:"logs,omitempty"`              // Events emitted by the transaction
+
+	Swaps []Swap `json:"swaps,omitempty"` // DEX swaps made by the transaction
+
+	Verification *Verification `json:"verification,omitempty"` // Result of checking the transaction against its raw signed form
+
+	DenylistHits []DenylistHit `json:"denylistHits,omitempty"` // Denylisted counterparties of the transaction
+
+	Nonce           string        `json:"nonce,omitempty"`           // Sender nonce
+	State           string        `json:"state,omitempty"`           // Lifecycle state of top-level transactions, e.g. StatePending
+	History         []StateChange `json:"history,omitempty"`         // Lifecycle state transitions
+	ReplacedBy      string        `json:"replacedBy,omitempty"`      // Transaction that used the same nonce
+	ReplacementType string        `json:"replacementType,omitempty"` // ReplacementSpeedUp, ReplacementCancel or ReplacementOther
 	// ...and so on...
 }
 
+const (
+	KindTransaction = "transaction" // top-level transaction
+	KindInternal    = "internal"    // value transfer made by a contract during a transaction
+	KindWithdrawal  = "withdrawal"  // beacon chain withdrawal credited by the block
+)
+
 // Block is a minimal required (shortened) structure describing single block
 type Block struct {
 	Hash         string   `json:"Hash,omitempty"`
@@ -45,7 +94,53 @@ type BlockParser struct {
 	store         Storage
 	mu            sync.Mutex
 
-	running bool
+	traceMode string // TraceModeDebug, TraceModeParity or empty when internal transfers are not tracked
+
+	subscribeDeployments bool // subscribe contracts deployed by observed addresses
+
+	verifyTransactions bool // verify matched transactions against their raw signed form
+
+	mempoolMode     string        // MempoolModeBlock, MempoolModeTxpool or empty when pending transactions are not tracked
+	mempoolInterval time.Duration // interval on which the mempool is polled
+
+	confirmations int                  // blocks after which included transactions are confirmed
+	dropTimeout   time.Duration        // time after which pending transactions missing from the mempool are dropped
+	mempoolSeen   map[string]time.Time // last time pending transactions were seen in the mempool
+	blockHashes   map[int]string       // hashes of recently processed blocks
+	unconfirmed   map[int][]string     // hashes of included transactions by block, until they are confirmed
+
+	stuckTimeout time.Duration     // time after which pending transactions are reported as stuck
+	minedNonces  map[string]uint64 // highest mined nonce of subscribed senders
+	alertWebhook string            // URL to which raised alerts are posted
+
+	reconcileInterval int  // blocks between reconciliations of derived balances with the node
+	reconcileBalances bool // reconcile all balances with the next block, e.g. after a reorg
+
+	balanceDeltas map[int]map[string]*big.Int // ETH balance changes of recently processed blocks by address
+
+	tokenBaselines map[tokenKey]bool             // token balances first touched by a transfer, to be fetched from the node
+	tokenDeltas    map[int]map[tokenKey]*big.Int // token balance changes applied by recently processed blocks
+	tokenCache     string                        // file in which token metadata is cached
+	tokenCacheMu   sync.Mutex                    // serialises writes of the token cache file
+
+	ensRefresh time.Duration // time after which ENS names are resolved again
+
+	addressBook string // file in which address book labels are stored
+
+	denylists      map[string]denylist // loaded denylist files by path
+	denylistReload time.Duration       // interval on which denylist files are checked for changes
+
+	ruleSeq     int                   // ID of the last added alert rule
+	activity    map[string][]activity // recent matched activity of subscribed addresses used by rule aggregates
+	ruleEntries []ruleEntry           // matched activity of the block being processed, evaluated by alert rules
+	// parties of matched activity of subscribed addresses by the block each was first seen in
+	counterparties map[string]map[string]int
+
+	sigs *sigdb.Registry // function and event signatures used when contract ABI is unknown
+
+	running  bool
+	stop     chan struct{} // closed when block synchronisation is stopped, ends the watchers
+	stopOnce sync.Once
 }
 
 // NewBlockParser creates a new instance of BlockParser.
@@ -54,13 +149,16 @@ func NewBlockParser(rpcURL string, parseInterval time.Duration) *BlockParser {
 	return &BlockParser{
 		currentBlock:  -1,
 		parseInterval: parseInterval,
-		store: &TransactionStorage{
-			observedAddrs: make(map[string]struct{}),
-			transactions:  make(map[string][]Transaction),
-		},
-		rpcURL:  rpcURL,
-		mu:      sync.Mutex{},
-		running: true,
+		store:         NewTransactionStorage(),
+		sigs:          sigdb.New(),
+		confirmations: DefaultConfirmations,
+		rpcURL:        rpcURL,
+		mu:            sync.Mutex{},
+		running:       true,
+
+		reconcileInterval: DefaultReconcileInterval,
+		ensRefresh:        DefaultENSRefresh,
+		denylistReload:    DefaultDenylistReload,
 	}
 }
 
@@ -84,10 +182,17 @@ func (bp *BlockParser) Subscribe(address string) bool {
 	defer bp.mu.Unlock()
 
 	if !validAddress(address) {
-		L.L.Warn("Address", address, "is not valid hex number")
+		L.L.Warn("Address", address, "is not valid address")
 		return false
 	}
+	address = normalizeAddress(address)
 
+	if bp.store.IsNameAddress(address) {
+		// subscribed by an ENS name so far, stays subscribed when the name changes owner
+		bp.store.RemoveNameAddress(address)
+		L.L.Info("Address", address, "is now subscribed")
+		return true
+	}
 	if err := bp.store.StoreAddress(address); err != nil {
 		L.L.Warn("Subscribe:", err.Error())
 		return false
@@ -96,10 +201,31 @@ func (bp *BlockParser) Subscribe(address string) bool {
 	return true
 }
 
-// validAddress checks if the given string is a valid Ethereum address in hex format.
+// validAddress checks if the given string is a valid 20 byte Ethereum address in hex format.
+//
+// Mixed case addresses must carry a valid EIP-55 checksum.
 func validAddress(s string) bool {
-	re := regexp.MustCompile(`^0[xX][0-9a-fA-F]+$`)
-	return re.MatchString(s)
+	re := regexp.MustCompile(`^0[xX][0-9a-fA-F]{40}$`)
+	if !re.MatchString(s) {
+		return false
+	}
+
+	hexPart := s[2:]
+	if hexPart == strings.ToLower(hexPart) || hexPart == strings.ToUpper(hexPart) {
+		return true
+	}
+	return ChecksumAddress(s) == "0x"+hexPart
+}
+
+// GetTransaction returns a stored transaction by its hash. Pending transactions are returned as well.
+func (bp *BlockParser) GetTransaction(hash string) (Transaction, bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if tx, ok := bp.store.Transaction(hash); ok {
+		return bp.withAddressInfo(tx), true
+	}
+	tx, ok := bp.store.PendingTransaction(hash)
+	return bp.withAddressInfo(tx), ok
 }
 
 // GetTransactions returns a list of inbound or outbound transactions for an address.
@@ -107,104 +233,132 @@ func (bp *BlockParser) GetTransactions(address string) []Transaction {
 	bp.mu.Lock()
 	defer bp.mu.Unlock()
 	L.L.Info("Getting transactions for:", address)
-	txs := bp.store.Transactions(address)
-	if txs == nil {
-		return []Transaction{}
+	txs := bp.store.Transactions(normalizeAddress(address))
+	out := make([]Transaction, len(txs))
+	for i, tx := range txs {
+		out[i] = bp.withAddressInfo(tx)
 	}
-	return txs
+	return out
 }
 
 func (bp *BlockParser) SynchronizeBlocks() {
 	bp.running = true
 	for bp.running {
 		time.Sleep(bp.parseInterval)
-		//get latest block data
-		latestBlockData, err := bp.getLatestBlock()
+		//get latest block number
+		latestBlockNo, err := bp.getBlockNumber()
 		if err != nil {
 			L.L.Error("Failed fetching latest block.", "Error:", err.Error())
 			continue
-		} else if latestBlockData == nil {
-			// no new blocks...
-			continue
 		}
+		bp.catchUp(latestBlockNo)
+	}
+}
 
-		latestBlockParentHash, ok := latestBlockData["parentHash"].(string)
-		if !ok {
-			L.L.Error("Failed casting parent hash to string", fmt.Sprintf("%v", latestBlockData))
-			continue
+// catchUp processes the blocks following the current one up to latestBlockNo, one by one, so blocks produced since
+// the last poll are not skipped. The first synchronised block is the latest one.
+func (bp *BlockParser) catchUp(latestBlockNo int) {
+	currentBlock := bp.GetCurrentBlock()
+	if latestBlockNo <= currentBlock {
+		L.L.Debug("No new blocks...")
+		return
+	}
+	next := latestBlockNo
+	if currentBlock != -1 {
+		next = currentBlock + 1
+	}
+	for n := next; n <= latestBlockNo && bp.running; n++ {
+		if err := bp.syncBlock(n); err != nil {
+			L.L.Error("Failed synchronizing block:", fmt.Sprintf("0x%x", n), "Error:", err.Error())
+			return
 		}
+	}
+}
 
-		//get present block data
-		currentBlock := bp.GetCurrentBlock()
-		if currentBlock != -1 {
-			blockData, err := bp.getBlockByNumber(currentBlock)
-			if err != nil {
-				L.L.Error("Failed fetching block:", fmt.Sprintf("0x%x", currentBlock), "Error:", err.Error())
-				continue
-			}
+// syncBlock fetches and processes the block following the current one. A parent hash differing from the hash of the
+// current block means blocks we processed were orphaned, they are replaced before the new block is processed.
+func (bp *BlockParser) syncBlock(blockNumber int) error {
+	L.L.Info("Got NEW block:", fmt.Sprintf("0x%x", blockNumber))
+	blockData, err := bp.getBlockByNumber(blockNumber)
+	if err != nil {
+		return err
+	}
 
-			oldBlockHash, ok := blockData["hash"].(string)
-			if !ok {
-				L.L.Error("Failed casting hash to string", fmt.Sprintf("%v", blockData))
-				continue
-			}
+	parentHash, ok := blockData["parentHash"].(string)
+	if !ok {
+		return fmt.Errorf("failed casting parent hash to string: %v", blockData)
+	}
 
-			// Validate chain integrity
-			if latestBlockParentHash != oldBlockHash {
-				// unhandled reorganization happened
-				// L.L.Error("Unhandled block reorg happened. Shutting down...")
-				// bp.running = false
-				// return
-				panic("Unhandled block reorg happened. Shutting down...")
-			}
-		}
+	// Validate chain integrity, blocks we processed may have been orphaned
+	bp.mu.Lock()
+	oldBlockHash, known := bp.blockHashes[blockNumber-1]
+	bp.mu.Unlock()
+	if known && parentHash != oldBlockHash {
+		bp.handleReorg(blockNumber - 1)
+	}
 
-		// Process block transactions
-		err = bp.processBlockTransactions(latestBlockData)
-		if err != nil {
-			L.L.Error("Processing transactions from block failed:", err.Error())
-		}
-		// Update the current block
-		latestBlockNoHex, ok := latestBlockData["number"].(string)
-		if !ok {
-			L.L.Error("Failed casting latest block number to string", latestBlockNoHex)
-			continue
-		}
+	bp.processBlock(blockData)
+	// Update the current block
+	blockHash, _ := blockData["hash"].(string)
+	bp.mu.Lock()
+	bp.currentBlock = blockNumber
+	bp.rememberBlock(blockNumber, blockHash)
+	bp.confirmTransactions(blockNumber)
+	bp.mu.Unlock()
+	// Check pending transactions of subscribed senders
+	bp.checkNonces(blockNumber)
+	// Apply the block to balances of subscribed addresses
+	reconcile := bp.reconcileDue(blockNumber)
+	bp.updateBalances(blockNumber, reconcile)
+	bp.updateTokenBalances(blockNumber, reconcile)
+	return nil
+}
 
-		var latestBlockNo int
-		fmt.Sscanf(latestBlockNoHex, "0x%x", &latestBlockNo)
-		bp.mu.Lock()
-		bp.currentBlock = latestBlockNo
-		bp.mu.Unlock()
+// processBlock processes transactions and other activity of the block.
+func (bp *BlockParser) processBlock(blockData map[string]interface{}) {
+	// Process block transactions
+	err := bp.processBlockTransactions(blockData)
+	if err != nil {
+		L.L.Error("Processing transactions from block failed:", err.Error())
+	}
+	// Process beacon chain withdrawals
+	err = bp.processBlockWithdrawals(blockData)
+	if err != nil {
+		L.L.Error("Processing withdrawals from block failed:", err.Error())
+	}
+	// Process fee recipient rewards
+	err = bp.processBlockFeeRecipient(blockData)
+	if err != nil {
+		L.L.Error("Processing fee recipient of block failed:", err.Error())
+	}
+	// Process NFT transfers
+	err = bp.processBlockLogs(blockData)
+	if err != nil {
+		L.L.Error("Processing logs from block failed:", err.Error())
 	}
+	// Process internal transfers
+	err = bp.processBlockTraces(blockData)
+	if err != nil {
+		L.L.Error("Processing traces from block failed:", err.Error())
+	}
+	// Evaluate alert rules against matched activity of the block
+	bp.evaluateRules(blockData)
 }
 
 func (bp *BlockParser) StopSynchronisingBlocks() {
 	L.L.Info("Stopping block synchronizations...")
 	bp.running = false
+	bp.stopOnce.Do(func() { close(bp.stopped()) })
 }
 
-// getLatestBlock returns latest block data by calling getBlockByNumber(getBlockNumber()).
-//
-// If blockNumber == currentBlocknumber the function returns (nil, nil)
-func (bp *BlockParser) getLatestBlock() (map[string]interface{}, error) {
-	blockNumber, err := bp.getBlockNumber()
-	if err != nil {
-		return nil, err
-	}
-
-	if blockNumber == bp.GetCurrentBlock() {
-		L.L.Debug("No new blocks...")
-		return nil, nil
-	}
-	L.L.Info("Got NEW block:", fmt.Sprintf("0x%x", blockNumber))
-
-	blockData, err := bp.getBlockByNumber(blockNumber)
-	if err != nil {
-		return nil, err
+// stopped returns the channel closed when block synchronisation is stopped.
+func (bp *BlockParser) stopped() chan struct{} {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if bp.stop == nil {
+		bp.stop = make(chan struct{})
 	}
-
-	return blockData, nil
+	return bp.stop
 }
 
 // getBlockNumber returns latest block number
@@ -293,48 +447,157 @@ func (bp *BlockParser) getBlockByNumber(blockNumber int) (map[string]interface{}
 	return result, nil
 }
 
+// transactionFromMap creates a top-level transaction from eth_getBlockByNumber (or txpool) transaction object.
+func transactionFromMap(txMap map[string]interface{}) (Transaction, bool) {
+	hash, ok := txMap["hash"].(string)
+	if !ok {
+		return Transaction{}, false
+	}
+	from, _ := txMap["from"].(string)
+	to, _ := txMap["to"].(string)
+	value, _ := txMap["value"].(string)
+	input, _ := txMap["input"].(string)
+	nonce, _ := txMap["nonce"].(string)
+	blockNumber, _ := txMap["blockNumber"].(string)
+
+	// Convert block number from hex to int
+	var blockNumberInt int
+	fmt.Sscanf(blockNumber, "0x%x", &blockNumberInt)
+
+	txObj := Transaction{
+		Hash:        hash,
+		From:        normalizeAddress(from),
+		To:          normalizeAddress(to),
+		Value:       value,
+		BlockNumber: blockNumberInt,
+		Kind:        KindTransaction,
+		Input:       input,
+		Nonce:       nonce,
+	}
+	if txObj.To == "" {
+		txObj.ContractCreation = true
+	}
+	return txObj, true
+}
+
 // processBlockTransactions processes transactions in a block and stores relevant ones.
 func (bp *BlockParser) processBlockTransactions(blockData map[string]interface{}) error {
 	transactions, ok := blockData["transactions"].([]interface{})
 	if !ok {
 		return fmt.Errorf("failed parsing block.result transactions field")
 	}
+	timestamp, _ := blockData["timestamp"].(string)
 
+	// recovering authorities of EIP-7702 authorizations is expensive, it is needed only to invalidate
+	// cached classifications
+	bp.mu.Lock()
+	classified := bp.store.AddressInfoCount() > 0
+	bp.mu.Unlock()
+
+	var reclassify []string
 	for _, tx := range transactions {
 		txMap, ok := tx.(map[string]interface{})
 		if !ok {
 			continue
 		}
 
-		from, _ := txMap["from"].(string)
-		to, _ := txMap["to"].(string)
-		value, _ := txMap["value"].(string)
-		blockNumber, _ := txMap["blockNumber"].(string)
-
-		// Convert block number from hex to int
-		var blockNumberInt int
-		fmt.Sscanf(blockNumber, "0x%x", &blockNumberInt)
-
-		txObj := Transaction{
-			Hash:        txMap["hash"].(string),
-			From:        from,
-			To:          to,
-			Value:       value,
-			BlockNumber: blockNumberInt,
+		txObj, ok := transactionFromMap(txMap)
+		if !ok {
+			continue
+		}
+		txObj.Timestamp = int64(hexToInt(timestamp))
+		from, to := txObj.From, txObj.To
+		var authorities []string
+		if classified {
+			authorities = codeChanges(txMap)
+		}
+
+		bp.mu.Lock()
+		// accounts that delegated their code are classified again
+		for _, authority := range authorities {
+			if _, cached := bp.store.AddressInfo(authority); cached {
+				bp.store.RemoveAddressInfo(authority)
+				reclassify = append(reclassify, authority)
+			}
+		}
+		matched := bp.store.IsObserved(from) || bp.store.IsObserved(to)
+		// earlier sightings (pending, or included in a block that got reorged out) carry the lifecycle history
+		known, _ := bp.store.Transaction(txObj.Hash)
+		if pending, isPending := bp.store.PendingTransaction(txObj.Hash); isPending {
+			known = pending
+			// pending transaction got included, the block entry replaces it
+			bp.store.RemovePendingTransaction(txObj.Hash)
+		}
+		bp.mu.Unlock()
+		if matched {
+			bp.applyReceipt(&txObj)
+			if bp.verifyTransactions {
+				bp.verifyTransaction(&txObj)
+			}
+			for _, log := range txObj.Logs {
+				if _, ok := tokenTransferAmount(log.Topics, log.Data); ok {
+					bp.resolveToken(log.Address)
+				}
+			}
+			txObj.Swaps = bp.decodeSwaps(txObj)
+			bp.ClassifyAddress(from)
+			bp.ClassifyAddress(to)
+			bp.LookupName(from)
+			bp.LookupName(to)
 		}
 
 		bp.mu.Lock()
-		// Store transaction if address is being observed
-		if bp.store.IsObserved(from) {
-			L.L.Info("New transaction for", from)
-			bp.store.StoreTransactions(from, txObj)
+		if matched {
+			bp.decodeCall(&txObj)
+			bp.decodeLogs(&txObj)
+			bp.screenTransaction(&txObj)
+			txObj.State, txObj.History = known.State, known.History
+			setState(&txObj, StateIncluded, txObj.BlockNumber)
+			bp.awaitConfirmation(txObj)
+			if txObj.ContractAddress != "" {
+				// the address may have been classified before the deployment
+				bp.store.RemoveAddressInfo(txObj.ContractAddress)
+				reclassify = append(reclassify, txObj.ContractAddress)
+			}
+		}
+		if _, stored := bp.store.Transaction(txObj.Hash); stored {
+			// included again after a reorg
+			bp.store.UpdateTransaction(txObj)
+		} else {
+			bp.storeBlockTransaction(txObj)
 		}
-		if bp.store.IsObserved(to) {
-			L.L.Info("New transaction for", to)
-			bp.store.StoreTransactions(to, txObj)
+		bp.noteBalanceDelta(txObj)
+		if matched {
+			bp.queueTransaction(txObj)
 		}
+		bp.markReplacements(txObj)
+		bp.noteMinedNonce(txObj)
 		bp.mu.Unlock()
 	}
+	for _, address := range reclassify {
+		bp.ClassifyAddress(address)
+	}
 	L.L.Info(fmt.Sprintf("Processed %d transactions", len(transactions)))
 	return nil
 }
+
+// storeBlockTransaction stores included transaction for each observed address it involves. Caller must hold bp.mu.
+func (bp *BlockParser) storeBlockTransaction(txObj Transaction) {
+	from, to := txObj.From, txObj.To
+	// Store transaction if address is being observed
+	if bp.store.IsObserved(from) {
+		L.L.Info("New transaction for", from)
+		bp.store.StoreTransactions(from, txObj)
+		if txObj.ContractAddress != "" && bp.subscribeDeployments {
+			bp.subscribeDeployment(txObj)
+		}
+	}
+	if bp.store.IsObserved(to) {
+		L.L.Info("New transaction for", to)
+		bp.store.StoreTransactions(to, txObj)
+	}
+	if txObj.ContractAddress != "" && bp.store.IsObserved(txObj.ContractAddress) {
+		L.L.Info("New transaction for", txObj.ContractAddress)
+		bp.store.StoreTransactions(txObj.ContractAddress, txObj)
+	}
+}
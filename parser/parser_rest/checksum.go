@@ -0,0 +1,194 @@
+package parser_rest
+
+import (
+	P "ethTx/parser"
+	"ethTx/parser/abi"
+)
+
+// Addresses are stored lowercase, API output uses EIP-55 checksum encoding.
+// Values are copied so that the stored data is left untouched.
+
+func checksumTransaction(tx P.Transaction) P.Transaction {
+	tx.From = P.ChecksumAddress(tx.From)
+	tx.To = P.ChecksumAddress(tx.To)
+	tx.ContractAddress = P.ChecksumAddress(tx.ContractAddress)
+	tx.FromInfo = checksumAddressInfo(tx.FromInfo)
+	tx.ToInfo = checksumAddressInfo(tx.ToInfo)
+	if tx.Call != nil {
+		call := *tx.Call
+		call.Args = checksumValues(call.Args)
+		tx.Call = &call
+	}
+	if tx.Verification != nil {
+		v := *tx.Verification
+		v.Sender = P.ChecksumAddress(v.Sender)
+		tx.Verification = &v
+	}
+	if tx.Logs != nil {
+		logs := make([]P.Log, len(tx.Logs))
+		for i, l := range tx.Logs {
+			l.Address = P.ChecksumAddress(l.Address)
+			if l.Event != nil {
+				ev := *l.Event
+				ev.Params = checksumValues(ev.Params)
+				l.Event = &ev
+			}
+			logs[i] = l
+		}
+		tx.Logs = logs
+	}
+	if tx.DenylistHits != nil {
+		hits := make([]P.DenylistHit, len(tx.DenylistHits))
+		for i, h := range tx.DenylistHits {
+			h.Address = P.ChecksumAddress(h.Address)
+			hits[i] = h
+		}
+		tx.DenylistHits = hits
+	}
+	if tx.Swaps != nil {
+		swaps := make([]P.Swap, len(tx.Swaps))
+		for i, s := range tx.Swaps {
+			s.Pool = P.ChecksumAddress(s.Pool)
+			s.Router = P.ChecksumAddress(s.Router)
+			s.Recipient = P.ChecksumAddress(s.Recipient)
+			s.TokenIn = P.ChecksumAddress(s.TokenIn)
+			s.TokenOut = P.ChecksumAddress(s.TokenOut)
+			swaps[i] = s
+		}
+		tx.Swaps = swaps
+	}
+	return tx
+}
+
+func checksumAddressInfo(info *P.AddressInfo) *P.AddressInfo {
+	if info == nil {
+		return nil
+	}
+	out := *info
+	out.Address = P.ChecksumAddress(out.Address)
+	out.Delegate = P.ChecksumAddress(out.Delegate)
+	return &out
+}
+
+func checksumTransactions(txs []P.Transaction) []P.Transaction {
+	out := make([]P.Transaction, len(txs))
+	for i, tx := range txs {
+		out[i] = checksumTransaction(tx)
+	}
+	return out
+}
+
+// checksumValues converts decoded arguments of address type.
+func checksumValues(values []abi.Value) []abi.Value {
+	if values == nil {
+		return nil
+	}
+	out := make([]abi.Value, len(values))
+	for i, v := range values {
+		if s, ok := v.Value.(string); ok && v.Type == "address" {
+			v.Value = P.ChecksumAddress(s)
+		}
+		out[i] = v
+	}
+	return out
+}
+
+func checksumNFTTransfers(transfers []P.NFTTransfer) []P.NFTTransfer {
+	out := make([]P.NFTTransfer, len(transfers))
+	for i, t := range transfers {
+		t.Contract = P.ChecksumAddress(t.Contract)
+		t.From = P.ChecksumAddress(t.From)
+		t.To = P.ChecksumAddress(t.To)
+		out[i] = t
+	}
+	return out
+}
+
+func checksumNFTHoldings(holdings []P.NFTHolding) []P.NFTHolding {
+	out := make([]P.NFTHolding, len(holdings))
+	for i, h := range holdings {
+		h.Contract = P.ChecksumAddress(h.Contract)
+		out[i] = h
+	}
+	return out
+}
+
+func checksumFeeRecipientBlocks(blocks []P.FeeRecipientBlock) []P.FeeRecipientBlock {
+	if blocks == nil {
+		return nil
+	}
+	out := make([]P.FeeRecipientBlock, len(blocks))
+	for i, b := range blocks {
+		b.FeeRecipient = P.ChecksumAddress(b.FeeRecipient)
+		out[i] = b
+	}
+	return out
+}
+
+func checksumTokenBalances(balances []P.TokenBalance) []P.TokenBalance {
+	out := make([]P.TokenBalance, len(balances))
+	for i, b := range balances {
+		b.Contract = P.ChecksumAddress(b.Contract)
+		out[i] = b
+	}
+	return out
+}
+
+func checksumAllowances(allowances []P.Allowance) []P.Allowance {
+	out := make([]P.Allowance, len(allowances))
+	for i, a := range allowances {
+		a.Contract = P.ChecksumAddress(a.Contract)
+		a.Spender = P.ChecksumAddress(a.Spender)
+		out[i] = a
+	}
+	return out
+}
+
+func checksumUserOperations(ops []P.UserOperation) []P.UserOperation {
+	out := make([]P.UserOperation, len(ops))
+	for i, op := range ops {
+		op.Sender = P.ChecksumAddress(op.Sender)
+		op.Paymaster = P.ChecksumAddress(op.Paymaster)
+		op.EntryPoint = P.ChecksumAddress(op.EntryPoint)
+		op.Bundler = P.ChecksumAddress(op.Bundler)
+		out[i] = op
+	}
+	return out
+}
+
+func checksumLabels(labels []P.Label) []P.Label {
+	out := make([]P.Label, len(labels))
+	for i, l := range labels {
+		l.Address = P.ChecksumAddress(l.Address)
+		out[i] = l
+	}
+	return out
+}
+
+func checksumRules(rules []P.Rule) []P.Rule {
+	out := make([]P.Rule, len(rules))
+	for i, r := range rules {
+		if r.Address != "" {
+			r.Address = P.ChecksumAddress(r.Address)
+		}
+		out[i] = r
+	}
+	return out
+}
+
+func checksumAddresses(addresses []string) []string {
+	out := make([]string, len(addresses))
+	for i, a := range addresses {
+		out[i] = P.ChecksumAddress(a)
+	}
+	return out
+}
+
+func checksumAlerts(alerts []P.Alert) []P.Alert {
+	out := make([]P.Alert, len(alerts))
+	for i, a := range alerts {
+		a.Address = P.ChecksumAddress(a.Address)
+		out[i] = a
+	}
+	return out
+}
@@ -10,6 +10,83 @@ type subscribeRequest struct {
 	Address string `json:"address"`
 }
 
+type getAddressInfoResponse struct {
+	Address *parser.AddressInfo `json:"address,omitempty"`
+	Name    string              `json:"name,omitempty"`
+}
+
 type getTransactionsForAddressResponse struct {
-	Transactions []parser.Transaction `json:"transactions"`
+	Transactions       []parser.Transaction       `json:"transactions"`
+	Pending            []parser.Transaction       `json:"pending,omitempty"`
+	FeeRecipientBlocks []parser.FeeRecipientBlock `json:"feeRecipientBlocks,omitempty"`
+}
+
+type getTransactionResponse struct {
+	Transaction parser.Transaction `json:"transaction"`
+}
+
+type getNFTsForAddressResponse struct {
+	Transfers []parser.NFTTransfer `json:"transfers"`
+	Holdings  []parser.NFTHolding  `json:"holdings"`
+}
+
+type getABIsResponse struct {
+	Addresses []string `json:"addresses"`
+}
+
+type getAlertsResponse struct {
+	Alerts            []parser.Alert `json:"alerts"`
+	HighestMinedNonce *uint64        `json:"highestMinedNonce,omitempty"`
+}
+
+type getBalanceResponse struct {
+	Balance parser.Balance `json:"balance"`
+}
+
+type getTokensForAddressResponse struct {
+	Tokens []parser.TokenBalance `json:"tokens"`
+}
+
+type getApprovalsForAddressResponse struct {
+	Approvals []parser.Allowance `json:"approvals"`
+}
+
+type getUserOperationsForAddressResponse struct {
+	UserOperations []parser.UserOperation `json:"userOperations"`
+}
+
+type getFeesForAddressResponse struct {
+	Fees parser.FeeReport `json:"fees"`
+}
+
+type setLabelRequest struct {
+	Label string   `json:"label"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type getLabelResponse struct {
+	Label parser.Label `json:"label"`
+}
+
+type getLabelsResponse struct {
+	Labels []parser.Label `json:"labels"`
+}
+
+type importLabelsResponse struct {
+	Imported int `json:"imported"`
+}
+
+type ruleRequest struct {
+	Name       string `json:"name,omitempty"`
+	Expression string `json:"expression"`
+	Address    string `json:"address,omitempty"`
+	Priority   string `json:"priority,omitempty"`
+}
+
+type getRuleResponse struct {
+	Rule parser.Rule `json:"rule"`
+}
+
+type getRulesResponse struct {
+	Rules []parser.Rule `json:"rules"`
 }
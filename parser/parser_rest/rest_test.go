@@ -5,50 +5,17 @@ import (
 	"encoding/json"
 	"ethTx/cmd/util/logging"
 	"ethTx/parser"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
-type mockStorage struct {
-	observedAddrs map[string]struct{}
-	transactions  map[string][]parser.Transaction
-}
-
-func (ms *mockStorage) StoreAddress(address string) error {
-	if _, exists := ms.observedAddrs[address]; exists {
-		return fmt.Errorf("%s already subscribed", address)
-	}
-
-	ms.observedAddrs[address] = struct{}{}
-	return nil
-}
-
-func (ms *mockStorage) StoreTransactions(address string, tx parser.Transaction) {
-	ms.transactions[address] = append(ms.transactions[address], tx)
-
-}
-
-func (ms *mockStorage) Transactions(address string) []parser.Transaction {
-	return ms.transactions[address]
-}
-
-func (ms *mockStorage) IsObserved(address string) bool {
-	return true
-}
-
 func TestGetItemHandler(t *testing.T) {
 	logging.Init("info")
-	myStorage := &mockStorage{observedAddrs: map[string]struct{}{
-		"":    {},
-		"0x1": {},
-		"0x2": {},
-		"0x3": {},
-		"0x4": {},
-	}}
-
-	bp := parser.NewBlockParser("", 1).WithStorage(myStorage)
+	bp := parser.NewBlockParser("", 1).WithStorage(parser.NewTransactionStorage())
 
 	srv := Server{bp: bp}
 
@@ -68,19 +35,22 @@ func TestGetItemHandler(t *testing.T) {
 
 func TestSubscribeHandler(t *testing.T) {
 	logging.Init("info")
-	myStorage := &mockStorage{observedAddrs: map[string]struct{}{
-		"":    {},
-		"0x1": {},
-		"0x2": {},
-		"0x3": {},
-		"0x4": {},
-	}}
+	store := parser.NewTransactionStorage()
+	// subscribed before
+	store.StoreAddress("0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e")
+	// eth_getCode of an externally owned account
+	var calls atomic.Int32
+	rpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x"}`))
+	}))
+	defer rpc.Close()
 
-	bp := parser.NewBlockParser("", 1).WithStorage(myStorage)
+	bp := parser.NewBlockParser(rpc.URL, 1).WithStorage(store)
 
 	srv := Server{bp: bp}
 
-	requestBody := map[string]string{"address": "0x1A3F"}
+	requestBody := map[string]string{"address": "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"}
 	body, err := json.Marshal(requestBody)
 	if err != nil {
 		t.Fatalf("failed to marshal request body: %v", err)
@@ -95,12 +65,21 @@ func TestSubscribeHandler(t *testing.T) {
 	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to decode response body: %v", err)
 	}
-	if resp != "Address 0x1A3F has been subscribed." {
+	if resp != "Address 0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed has been subscribed." {
 		// t.Log("Expected -1, got:", resp.BlockNumber)
 		t.Fail()
 	}
+	if info, ok := bp.GetAddressInfo("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"); !ok || info.Type != parser.AddressTypeEOA {
+		t.Errorf("Expected classification of subscribed address, got: %+v", info)
+	}
+
+	// already subscribed, in checksummed form
+	requestBody = map[string]string{"address": "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}
+	body, err = json.Marshal(requestBody)
+	if err != nil {
+		t.Fatalf("failed to marshal request body: %v", err)
+	}
 
-	// already subscribed
 	req = httptest.NewRequest(http.MethodGet, "/subscribe", bytes.NewReader(body))
 	rec = httptest.NewRecorder()
 
@@ -131,29 +110,46 @@ func TestSubscribeHandler(t *testing.T) {
 		// t.Log("Expected -1, got:", resp.BlockNumber)
 		t.Fail()
 	}
+
+	calls.Store(0)
+	for _, address := range []string{
+		"0x1A3F", // too short
+		"0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", // wrong checksum
+		"0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e", // already subscribed
+	} {
+		requestBody = map[string]string{"address": address}
+		body, err = json.Marshal(requestBody)
+		if err != nil {
+			t.Fatalf("failed to marshal request body: %v", err)
+		}
+
+		req = httptest.NewRequest(http.MethodPost, "/subscribe", bytes.NewReader(body))
+		rec = httptest.NewRecorder()
+
+		srv.subscribeHandler(rec, req)
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		if resp != "That didn't work" {
+			t.Errorf("expected %s to be rejected", address)
+		}
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("Expected no lookups for rejected addresses, got %d RPC calls", n)
+	}
 }
 
 func TestGetTransactionsHandler(t *testing.T) {
 	logging.Init("info")
-	myStorage := &mockStorage{observedAddrs: map[string]struct{}{
-		"":    {},
-		"0x1": {},
-		"0x2": {},
-		"0x3": {},
-		"0x4": {},
-	},
-		transactions: map[string][]parser.Transaction{
-			"0x1": {
-				parser.Transaction{
-					Hash: "asd",
-					From: "me",
-					To:   "you",
-				},
-			},
-		},
-	}
-
-	bp := parser.NewBlockParser("", 1).WithStorage(myStorage)
+	store := parser.NewTransactionStorage()
+	store.StoreAddress("0x1")
+	store.StoreTransactions("0x1", parser.Transaction{
+		Hash: "asd",
+		From: "me",
+		To:   "you",
+	})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
 
 	srv := Server{bp: bp}
 
@@ -167,7 +163,7 @@ func TestGetTransactionsHandler(t *testing.T) {
 		t.Fatalf("failed to decode response body: %v", err)
 	}
 
-	want := myStorage.transactions["0x1"]
+	want := store.Transactions("0x1")
 	if want[0].From != resp.Transactions[0].From ||
 		want[0].BlockNumber != resp.Transactions[0].BlockNumber ||
 		want[0].Hash != resp.Transactions[0].Hash ||
@@ -177,3 +173,457 @@ func TestGetTransactionsHandler(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGetNFTsHandler(t *testing.T) {
+	logging.Init("info")
+	store := parser.NewTransactionStorage()
+	store.StoreAddress("0x1")
+	store.StoreNFTTransfer("0x1", parser.NFTTransfer{
+		Standard: parser.StandardERC721,
+		Contract: "0xc",
+		From:     "0x2",
+		To:       "0x1",
+		TokenID:  "42",
+		Amount:   "1",
+	})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
+
+	srv := Server{bp: bp}
+
+	req := httptest.NewRequest(http.MethodGet, "/address/0x1/nfts", nil)
+	req.SetPathValue("address", "0x1")
+	rec := httptest.NewRecorder()
+
+	srv.getNFTsHandler(rec, req)
+	var resp getNFTsForAddressResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if len(resp.Transfers) != 1 || len(resp.Holdings) != 1 || resp.Holdings[0].TokenID != "42" {
+		t.Logf("Expected one transfer and holding of token 42, got: %v", resp)
+		t.Fail()
+	}
+}
+
+func TestRegisterABIHandler(t *testing.T) {
+	logging.Init("info")
+	bp := parser.NewBlockParser("", 1)
+
+	srv := Server{bp: bp}
+
+	abiJSON := `[{"type": "function", "name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}]}]`
+
+	req := httptest.NewRequest(http.MethodPost, "/abi/0xdac17f958d2ee523a2206206994597c13d831ec7", bytes.NewReader([]byte(abiJSON)))
+	req.SetPathValue("address", "0xdac17f958d2ee523a2206206994597c13d831ec7")
+	rec := httptest.NewRecorder()
+
+	srv.registerABIHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/abi/0xdac17f958d2ee523a2206206994597c13d831ec7", bytes.NewReader([]byte("{")))
+	req.SetPathValue("address", "0xdac17f958d2ee523a2206206994597c13d831ec7")
+	rec = httptest.NewRecorder()
+
+	srv.registerABIHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid ABI, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/abi", nil)
+	rec = httptest.NewRecorder()
+
+	srv.getABIsHandler(rec, req)
+	var resp getABIsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(resp.Addresses) != 1 || resp.Addresses[0] != "0xdAC17F958D2ee523a2206206994597C13D831ec7" {
+		t.Errorf("Expected single registered ABI, got: %v", resp)
+	}
+}
+
+func TestGetTransactionHandler(t *testing.T) {
+	logging.Init("info")
+	store := parser.NewTransactionStorage()
+	store.StoreTransactions("0x1", parser.Transaction{Hash: "0xaa", From: "0x1", To: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Kind: parser.KindTransaction})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
+
+	srv := Server{bp: bp}
+
+	req := httptest.NewRequest(http.MethodGet, "/tx/0xaa", nil)
+	req.SetPathValue("hash", "0xaa")
+	rec := httptest.NewRecorder()
+
+	srv.getTransactionHandler(rec, req)
+	var resp getTransactionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if resp.Transaction.Hash != "0xaa" || resp.Transaction.To != "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359" {
+		t.Logf("Expected transaction 0xaa, got: %v", resp)
+		t.Fail()
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/tx/0xbb", nil)
+	req.SetPathValue("hash", "0xbb")
+	rec = httptest.NewRecorder()
+
+	srv.getTransactionHandler(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Logf("Expected 404, got: %d", rec.Code)
+		t.Fail()
+	}
+}
+
+func TestGetAlertsHandler(t *testing.T) {
+	logging.Init("info")
+	store := parser.NewTransactionStorage()
+	store.StoreAlert(parser.Alert{ID: 1, Type: parser.AlertNonceGap, Address: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Message: "nonce 6 is missing"})
+	store.StoreAlert(parser.Alert{ID: 2, Type: parser.AlertStuckTransaction, Address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", TxHash: "0xaa"})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
+
+	srv := Server{bp: bp}
+
+	req := httptest.NewRequest(http.MethodGet, "/alerts", nil)
+	rec := httptest.NewRecorder()
+
+	srv.getAlertsHandler(rec, req)
+	var resp getAlertsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(resp.Alerts) != 2 {
+		t.Errorf("Expected 2 alerts, got: %v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/address/0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359/alerts", nil)
+	req.SetPathValue("address", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+	rec = httptest.NewRecorder()
+
+	srv.getAlertsHandler(rec, req)
+	resp = getAlertsResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(resp.Alerts) != 1 || resp.Alerts[0].Address != "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359" || resp.HighestMinedNonce != nil {
+		t.Errorf("Expected single checksummed alert, got: %v", resp)
+	}
+}
+
+func TestGetBalanceHandler(t *testing.T) {
+	logging.Init("info")
+	store := parser.NewTransactionStorage()
+	store.StoreBalance(parser.Balance{Address: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Balance: "1000", BlockNumber: 5})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
+
+	srv := Server{bp: bp}
+
+	req := httptest.NewRequest(http.MethodGet, "/address/0xFB6916095CA1DF60BB79CE92CE3EA74C37C5D359/balance", nil)
+	req.SetPathValue("address", "0xFB6916095CA1DF60BB79CE92CE3EA74C37C5D359")
+	rec := httptest.NewRecorder()
+
+	srv.getBalanceHandler(rec, req)
+	var resp getBalanceResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if resp.Balance.Balance != "1000" || resp.Balance.Address != "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359" {
+		t.Errorf("Expected checksummed balance, got: %v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/address/0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed/balance", nil)
+	req.SetPathValue("address", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	rec = httptest.NewRecorder()
+
+	srv.getBalanceHandler(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got: %d", rec.Code)
+	}
+}
+
+func TestGetAddressInfoHandler(t *testing.T) {
+	logging.Init("info")
+	store := parser.NewTransactionStorage()
+	store.StoreAddressInfo(parser.AddressInfo{Address: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Type: parser.AddressTypeEOA})
+	store.StoreReverseName(parser.ENSRecord{Name: "example.eth", Address: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", Resolved: time.Now()})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
+
+	srv := Server{bp: bp}
+
+	req := httptest.NewRequest(http.MethodGet, "/address/0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359/info", nil)
+	req.SetPathValue("address", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+	rec := httptest.NewRecorder()
+
+	srv.getAddressInfoHandler(rec, req)
+	var resp getAddressInfoResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if a := resp.Address; a == nil || a.Address != "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359" || a.Type != parser.AddressTypeEOA || resp.Name != "example.eth" {
+		t.Errorf("Expected checksummed classification and name, got: %+v", resp)
+	}
+}
+
+func TestGetTokensHandler(t *testing.T) {
+	logging.Init("info")
+	store := parser.NewTransactionStorage()
+	store.StoreTokenBalance("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", parser.TokenBalance{Contract: "0xdac17f958d2ee523a2206206994597c13d831ec7", Balance: "1000", BlockNumber: 5})
+	store.StoreTokenBalance("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", parser.TokenBalance{Contract: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", Balance: "0", BlockNumber: 5})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
+
+	srv := Server{bp: bp}
+
+	req := httptest.NewRequest(http.MethodGet, "/address/0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359/tokens", nil)
+	req.SetPathValue("address", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+	rec := httptest.NewRecorder()
+
+	srv.getTokensHandler(rec, req)
+	var resp getTokensForAddressResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(resp.Tokens) != 1 || resp.Tokens[0].Contract != "0xdAC17F958D2ee523a2206206994597C13D831ec7" || resp.Tokens[0].Balance != "1000" {
+		t.Errorf("Expected single checksummed token balance, got: %v", resp)
+	}
+}
+
+func TestGetApprovalsHandler(t *testing.T) {
+	logging.Init("info")
+	store := parser.NewTransactionStorage()
+	store.StoreAllowance("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", parser.Allowance{
+		Standard: parser.StandardERC20,
+		Contract: "0xdac17f958d2ee523a2206206994597c13d831ec7",
+		Spender:  "0x000000000022d473030f116ddee9f6b43ac78ba3",
+		Amount:   "1000",
+	})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
+
+	srv := Server{bp: bp}
+
+	req := httptest.NewRequest(http.MethodGet, "/address/0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359/approvals", nil)
+	req.SetPathValue("address", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+	rec := httptest.NewRecorder()
+
+	srv.getApprovalsHandler(rec, req)
+	var resp getApprovalsForAddressResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(resp.Approvals) != 1 || resp.Approvals[0].Contract != "0xdAC17F958D2ee523a2206206994597C13D831ec7" || resp.Approvals[0].Spender != "0x000000000022D473030F116dDEE9F6B43aC78BA3" {
+		t.Errorf("Expected single checksummed approval, got: %v", resp)
+	}
+}
+
+func TestGetUserOperationsHandler(t *testing.T) {
+	logging.Init("info")
+	store := parser.NewTransactionStorage()
+	store.StoreUserOperation("0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", parser.UserOperation{
+		Sender:     "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359",
+		EntryPoint: "0x0000000071727de22e5e9d8baf0edac6f37da032",
+		Nonce:      "1",
+		Success:    true,
+	})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
+
+	srv := Server{bp: bp}
+
+	req := httptest.NewRequest(http.MethodGet, "/address/0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359/userops", nil)
+	req.SetPathValue("address", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359")
+	rec := httptest.NewRecorder()
+
+	srv.getUserOperationsHandler(rec, req)
+	var resp getUserOperationsForAddressResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(resp.UserOperations) != 1 || resp.UserOperations[0].Sender != "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359" || resp.UserOperations[0].EntryPoint != "0x0000000071727De22E5E9d8BAf0edAc6f37da032" {
+		t.Errorf("Expected single checksummed user operation, got: %v", resp)
+	}
+}
+
+func TestGetFeesHandler(t *testing.T) {
+	logging.Init("info")
+	store := parser.NewTransactionStorage()
+	store.StoreTransactions("0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e", parser.Transaction{
+		Hash:              "0xaa",
+		From:              "0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e",
+		Kind:              parser.KindTransaction,
+		Timestamp:         1731751279, // 2024-11-16
+		GasUsed:           "0x5208",
+		EffectiveGasPrice: "0x2540be400",
+	})
+
+	bp := parser.NewBlockParser("", 1).WithStorage(store)
+
+	srv := Server{bp: bp}
+
+	req := httptest.NewRequest(http.MethodGet, "/address/0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e/fees?from=2024-11-01&to=2024-12-01T00:00:00Z&interval=month", nil)
+	req.SetPathValue("address", "0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e")
+	rec := httptest.NewRecorder()
+
+	srv.getFeesHandler(rec, req)
+	var resp getFeesForAddressResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if resp.Fees.Address != "0x98C3d3183C4b8A650614ad179A1a98be0a8d6B8E" || resp.Fees.Total.Fees != "210000000000000" || len(resp.Fees.Periods) != 1 {
+		t.Errorf("Expected fees of single transaction, got: %v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/address/0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e/fees?from=yesterday", nil)
+	req.SetPathValue("address", "0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e")
+	rec = httptest.NewRecorder()
+
+	srv.getFeesHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid time, got: %d", rec.Code)
+	}
+}
+
+func TestLabelHandlers(t *testing.T) {
+	logging.Init("info")
+	bp := parser.NewBlockParser("", 1)
+
+	srv := Server{bp: bp}
+
+	body := strings.NewReader(`{"label": "Treasury", "tags": ["own"]}`)
+	req := httptest.NewRequest(http.MethodPut, "/labels/0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", body)
+	req.SetPathValue("address", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	rec := httptest.NewRecorder()
+
+	srv.setLabelHandler(rec, req)
+	var resp getLabelResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if resp.Label.Address != "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed" || resp.Label.Label != "Treasury" {
+		t.Errorf("Expected checksummed label, got: %v", resp)
+	}
+
+	csv := "address,label,tags\n0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e,Binance 14,exchange\n"
+	req = httptest.NewRequest(http.MethodPost, "/labels/import", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	rec = httptest.NewRecorder()
+
+	srv.importLabelsHandler(rec, req)
+	var imported importLabelsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&imported); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if rec.Code != http.StatusOK || imported.Imported != 1 {
+		t.Errorf("Expected single imported label, got: %d %v", rec.Code, imported)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/labels", nil)
+	rec = httptest.NewRecorder()
+
+	srv.getLabelsHandler(rec, req)
+	var labels getLabelsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&labels); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(labels.Labels) != 2 || labels.Labels[1].Address != "0x98C3d3183C4b8A650614ad179A1a98be0a8d6B8E" {
+		t.Errorf("Expected two labels, got: %v", labels)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/labels/0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", nil)
+	req.SetPathValue("address", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	rec = httptest.NewRecorder()
+
+	srv.removeLabelHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 removing label, got: %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/labels/0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", nil)
+	req.SetPathValue("address", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	rec = httptest.NewRecorder()
+
+	srv.getLabelHandler(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for removed label, got: %d", rec.Code)
+	}
+}
+
+func TestRuleHandlers(t *testing.T) {
+	logging.Init("info")
+	bp := parser.NewBlockParser("", 1)
+
+	srv := Server{bp: bp}
+
+	body := strings.NewReader(`{"expression": "value > 11", "address": "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"}`)
+	req := httptest.NewRequest(http.MethodPost, "/rules", body)
+	rec := httptest.NewRecorder()
+
+	srv.addRuleHandler(rec, req)
+	var resp getRuleResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if resp.Rule.ID != 1 || resp.Rule.Address != "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed" || resp.Rule.Priority != parser.PriorityNormal {
+		t.Errorf("Expected added rule, got: %v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/rules", strings.NewReader(`{"expression": "value >"}`))
+	rec = httptest.NewRecorder()
+
+	srv.addRuleHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid expression, got: %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/rules/1", strings.NewReader(`{"name": "large", "expression": "value > 10 ether", "priority": "high"}`))
+	req.SetPathValue("id", "1")
+	rec = httptest.NewRecorder()
+
+	srv.updateRuleHandler(rec, req)
+	resp = getRuleResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if resp.Rule.Name != "large" || resp.Rule.Address != "" || resp.Rule.Priority != parser.PriorityHigh {
+		t.Errorf("Expected updated rule, got: %v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/rules/1/alerts", nil)
+	req.SetPathValue("id", "1")
+	rec = httptest.NewRecorder()
+
+	srv.getRuleAlertsHandler(rec, req)
+	var alerts getAlertsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&alerts); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if rec.Code != http.StatusOK || alerts.Alerts == nil || len(alerts.Alerts) != 0 {
+		t.Errorf("Expected empty alerts, got: %d %v", rec.Code, alerts)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/rules/1", nil)
+	req.SetPathValue("id", "1")
+	rec = httptest.NewRecorder()
+
+	srv.removeRuleHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 removing rule, got: %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/rules/1", nil)
+	req.SetPathValue("id", "1")
+	rec = httptest.NewRecorder()
+
+	srv.getRuleHandler(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for removed rule, got: %d", rec.Code)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	L "ethTx/cmd/util/logging"
 	P "ethTx/parser"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,9 +18,14 @@ type Server struct {
 	router *http.ServeMux
 }
 
-func Init(port, rpcURL string, parseInterval time.Duration) Server {
+// Init creates the server and its block parser. Optional configure functions
+// are applied to the block parser before it is used.
+func Init(port, rpcURL string, parseInterval time.Duration, configure ...func(*P.BlockParser)) Server {
 	L.L.Info("Initializing server...")
 	bp := P.NewBlockParser(rpcURL, parseInterval)
+	for _, c := range configure {
+		c(bp)
+	}
 	srv := Server{port: port, bp: bp}
 	srv.registerRoutes()
 	L.L.Info("Server Initialized...")
@@ -26,6 +34,9 @@ func Init(port, rpcURL string, parseInterval time.Duration) Server {
 
 func (srv *Server) Start() {
 	go srv.bp.SynchronizeBlocks()
+	go srv.bp.WatchMempool()
+	go srv.bp.WatchDenylists()
+	go srv.bp.WatchNames()
 
 	go func() {
 		err := http.ListenAndServe(srv.port, srv.router)
@@ -48,6 +59,29 @@ func (srv *Server) registerRoutes() {
 	srv.router.Handle("GET /block", http.HandlerFunc(srv.getBlockHandler))
 	srv.router.Handle("POST /subscribe", http.HandlerFunc(srv.subscribeHandler))
 	srv.router.Handle("GET /address/{address}", http.HandlerFunc(srv.getTransactionsHandler))
+	srv.router.Handle("GET /address/{address}/info", http.HandlerFunc(srv.getAddressInfoHandler))
+	srv.router.Handle("GET /address/{address}/nfts", http.HandlerFunc(srv.getNFTsHandler))
+	srv.router.Handle("GET /address/{address}/balance", http.HandlerFunc(srv.getBalanceHandler))
+	srv.router.Handle("GET /address/{address}/tokens", http.HandlerFunc(srv.getTokensHandler))
+	srv.router.Handle("GET /address/{address}/approvals", http.HandlerFunc(srv.getApprovalsHandler))
+	srv.router.Handle("GET /address/{address}/userops", http.HandlerFunc(srv.getUserOperationsHandler))
+	srv.router.Handle("GET /address/{address}/fees", http.HandlerFunc(srv.getFeesHandler))
+	srv.router.Handle("GET /tx/{hash}", http.HandlerFunc(srv.getTransactionHandler))
+	srv.router.Handle("GET /address/{address}/alerts", http.HandlerFunc(srv.getAlertsHandler))
+	srv.router.Handle("GET /alerts", http.HandlerFunc(srv.getAlertsHandler))
+	srv.router.Handle("GET /abi", http.HandlerFunc(srv.getABIsHandler))
+	srv.router.Handle("POST /abi/{address}", http.HandlerFunc(srv.registerABIHandler))
+	srv.router.Handle("GET /labels", http.HandlerFunc(srv.getLabelsHandler))
+	srv.router.Handle("POST /labels/import", http.HandlerFunc(srv.importLabelsHandler))
+	srv.router.Handle("GET /labels/{address}", http.HandlerFunc(srv.getLabelHandler))
+	srv.router.Handle("PUT /labels/{address}", http.HandlerFunc(srv.setLabelHandler))
+	srv.router.Handle("DELETE /labels/{address}", http.HandlerFunc(srv.removeLabelHandler))
+	srv.router.Handle("GET /rules", http.HandlerFunc(srv.getRulesHandler))
+	srv.router.Handle("POST /rules", http.HandlerFunc(srv.addRuleHandler))
+	srv.router.Handle("GET /rules/{id}", http.HandlerFunc(srv.getRuleHandler))
+	srv.router.Handle("PUT /rules/{id}", http.HandlerFunc(srv.updateRuleHandler))
+	srv.router.Handle("DELETE /rules/{id}", http.HandlerFunc(srv.removeRuleHandler))
+	srv.router.Handle("GET /rules/{id}/alerts", http.HandlerFunc(srv.getRuleAlertsHandler))
 }
 
 func (srv *Server) getBlockHandler(w http.ResponseWriter, r *http.Request) {
@@ -69,14 +103,28 @@ func (srv *Server) subscribeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ok := srv.bp.Subscribe(req.Address); !ok {
+	// ENS names are resolved to the address they point to
+	address, name := req.Address, ""
+	var ok bool
+	if strings.Contains(address, ".") {
+		name = strings.ToLower(address)
+		address, ok = srv.bp.SubscribeName(name)
+	} else {
+		ok = srv.bp.Subscribe(address)
+	}
+	if !ok {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode("That didn't work")
 		return
 	}
+	// classification and primary name are cached for the info endpoint and transactions of the address
+	if name == "" {
+		srv.bp.LookupName(address)
+	}
+	srv.bp.ClassifyAddress(address)
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(fmt.Sprintf("Address %s has been subscribed.", req.Address))
+	json.NewEncoder(w).Encode(fmt.Sprintf("Address %s has been subscribed.", P.ChecksumAddress(address)))
 }
 
 func (srv *Server) getTransactionsHandler(w http.ResponseWriter, r *http.Request) {
@@ -84,7 +132,389 @@ func (srv *Server) getTransactionsHandler(w http.ResponseWriter, r *http.Request
 	address := r.PathValue("address")
 
 	transactions := srv.bp.GetTransactions(address)
-	resp := getTransactionsForAddressResponse{Transactions: transactions}
+	resp := getTransactionsForAddressResponse{
+		Transactions:       checksumTransactions(transactions),
+		Pending:            checksumTransactions(srv.bp.GetPendingTransactions(address)),
+		FeeRecipientBlocks: checksumFeeRecipientBlocks(srv.bp.GetFeeRecipientBlocks(address)),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getTransactionHandler(w http.ResponseWriter, r *http.Request) {
+
+	hash := r.PathValue("hash")
+
+	tx, ok := srv.bp.GetTransaction(hash)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(fmt.Sprintf("Transaction %s not found.", hash))
+		return
+	}
+
+	resp := getTransactionResponse{Transaction: checksumTransaction(tx)}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getNFTsHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	resp := getNFTsForAddressResponse{
+		Transfers: checksumNFTTransfers(srv.bp.GetNFTTransfers(address)),
+		Holdings:  checksumNFTHoldings(srv.bp.GetNFTHoldings(address)),
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getBalanceHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	balance, ok := srv.bp.GetBalance(address)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(fmt.Sprintf("Balance of %s is not tracked.", address))
+		return
+	}
+
+	balance.Address = P.ChecksumAddress(balance.Address)
+	resp := getBalanceResponse{Balance: balance}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getAddressInfoHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	var resp getAddressInfoResponse
+	if info, ok := srv.bp.ClassifyAddress(address); ok {
+		resp.Address = checksumAddressInfo(&info)
+	}
+	resp.Name, _ = srv.bp.LookupName(address)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getTokensHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	resp := getTokensForAddressResponse{Tokens: checksumTokenBalances(srv.bp.GetTokenBalances(address))}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getApprovalsHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	resp := getApprovalsForAddressResponse{Approvals: checksumAllowances(srv.bp.GetAllowances(address))}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getUserOperationsHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	resp := getUserOperationsForAddressResponse{UserOperations: checksumUserOperations(srv.bp.GetUserOperations(address))}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// getFeesHandler returns fees paid by the address. Optional `from` and `to` query parameters limit the time range,
+// `interval` splits the totals by day or month.
+func (srv *Server) getFeesHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	var from, to time.Time
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err = parseTime(v)
+	}
+	if v := r.URL.Query().Get("to"); v != "" && err == nil {
+		to, err = parseTime(v)
+	}
+
+	var report P.FeeReport
+	if err == nil {
+		report, err = srv.bp.GetFees(address, from, to, r.URL.Query().Get("interval"))
+	}
+	if err != nil {
+		L.L.Warn("Getting fees failed:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(err.Error())
+		return
+	}
+
+	report.Address = P.ChecksumAddress(report.Address)
+	resp := getFeesForAddressResponse{Fees: report}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseTime parses RFC 3339 time or a date, e.g. `2024-11-16`, in UTC.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, expected RFC 3339 time or date", s)
+	}
+	return t.UTC(), nil
+}
+
+func (srv *Server) getAlertsHandler(w http.ResponseWriter, r *http.Request) {
+
+	// empty for all alerts
+	address := r.PathValue("address")
+
+	resp := getAlertsResponse{Alerts: checksumAlerts(srv.bp.GetAlerts(address))}
+	if nonce, ok := srv.bp.GetHighestMinedNonce(address); ok && address != "" {
+		resp.HighestMinedNonce = &nonce
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getABIsHandler(w http.ResponseWriter, r *http.Request) {
+	resp := getABIsResponse{Addresses: checksumAddresses(srv.bp.GetABIAddresses())}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) registerABIHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		L.L.Error("Failed reading request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := srv.bp.RegisterABI(address, body); err != nil {
+		L.L.Warn("Registering ABI failed:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(fmt.Sprintf("ABI for %s has been registered.", P.ChecksumAddress(address)))
+}
+
+func (srv *Server) getLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	resp := getLabelsResponse{Labels: checksumLabels(srv.bp.GetLabels())}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getLabelHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	label, ok := srv.bp.GetLabel(address)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(fmt.Sprintf("Address %s has no label.", address))
+		return
+	}
+
+	label.Address = P.ChecksumAddress(label.Address)
+	resp := getLabelResponse{Label: label}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) setLabelHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var req setLabelRequest
+	if err := d.Decode(&req); err != nil {
+		L.L.Error("Failed decoding request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	label, err := srv.bp.SetLabel(P.Label{Address: address, Label: req.Label, Tags: req.Tags})
+	if err != nil {
+		L.L.Warn("Setting label failed:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(err.Error())
+		return
+	}
+
+	label.Address = P.ChecksumAddress(label.Address)
+	resp := getLabelResponse{Label: label}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) removeLabelHandler(w http.ResponseWriter, r *http.Request) {
+
+	address := r.PathValue("address")
+
+	if !srv.bp.RemoveLabel(address) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(fmt.Sprintf("Address %s has no label.", address))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(fmt.Sprintf("Label of %s has been removed.", P.ChecksumAddress(address)))
+}
+
+// importLabelsHandler imports labels in bulk, either a JSON array of labels or CSV exported from a spreadsheet
+// when sent with `Content-Type: text/csv`.
+func (srv *Server) importLabelsHandler(w http.ResponseWriter, r *http.Request) {
+
+	var labels []P.Label
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		labels, err = P.ParseLabelsCSV(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&labels)
+	}
+	if err == nil {
+		_, err = srv.bp.ImportLabels(labels)
+	}
+	if err != nil {
+		L.L.Warn("Importing labels failed:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(err.Error())
+		return
+	}
+
+	resp := importLabelsResponse{Imported: len(labels)}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getRulesHandler(w http.ResponseWriter, r *http.Request) {
+	resp := getRulesResponse{Rules: checksumRules(srv.bp.GetRules())}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ruleID returns the rule ID from the path, or writes 404 response when there is no such rule.
+func (srv *Server) ruleID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err == nil {
+		if _, ok := srv.bp.GetRule(id); ok {
+			return id, true
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(fmt.Sprintf("Rule %s not found.", r.PathValue("id")))
+	return 0, false
+}
+
+// decodeRule decodes the rule from the request body, or writes 400 response when it is not valid JSON.
+func decodeRule(w http.ResponseWriter, r *http.Request) (P.Rule, bool) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var req ruleRequest
+	if err := d.Decode(&req); err != nil {
+		L.L.Error("Failed decoding request")
+		w.WriteHeader(http.StatusBadRequest)
+		return P.Rule{}, false
+	}
+	return P.Rule{Name: req.Name, Expression: req.Expression, Address: req.Address, Priority: req.Priority}, true
+}
+
+func (srv *Server) addRuleHandler(w http.ResponseWriter, r *http.Request) {
+
+	rule, ok := decodeRule(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := srv.bp.AddRule(rule)
+	if err != nil {
+		L.L.Warn("Adding rule failed:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(err.Error())
+		return
+	}
+
+	resp := getRuleResponse{Rule: checksumRules([]P.Rule{rule})[0]}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) getRuleHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := srv.ruleID(w, r)
+	if !ok {
+		return
+	}
+
+	rule, _ := srv.bp.GetRule(id)
+	resp := getRuleResponse{Rule: checksumRules([]P.Rule{rule})[0]}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) updateRuleHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := srv.ruleID(w, r)
+	if !ok {
+		return
+	}
+	rule, ok := decodeRule(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := srv.bp.UpdateRule(id, rule)
+	if err != nil {
+		L.L.Warn("Updating rule failed:", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(err.Error())
+		return
+	}
+
+	resp := getRuleResponse{Rule: checksumRules([]P.Rule{rule})[0]}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) removeRuleHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := srv.ruleID(w, r)
+	if !ok {
+		return
+	}
+
+	srv.bp.RemoveRule(id)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(fmt.Sprintf("Rule %d has been removed.", id))
+}
+
+// getRuleAlertsHandler returns alerts raised by the rule. They are kept after the rule is removed.
+func (srv *Server) getRuleAlertsHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(fmt.Sprintf("Rule %s not found.", r.PathValue("id")))
+		return
+	}
+
+	resp := getAlertsResponse{Alerts: checksumAlerts(srv.bp.GetRuleAlerts(id))}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(resp)
 }
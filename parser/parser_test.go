@@ -3,6 +3,7 @@ package parser
 import (
 	"encoding/json"
 	"ethTx/cmd/util/logging"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -19,6 +20,10 @@ func TestValidAddress(t *testing.T) {
 		{"", false},   // empty string
 		{"0x1234567890abcdef1234567890abcdef12345678extra", false}, // extra characters after valid hex
 		{"0xG1234567890abcdef1234567890abcdef12345678", false},     // invalid character 'G'
+		{"0x1A3F", false}, // shorter than 20 bytes
+		{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", true},  // valid checksum
+		{"0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED", true},  // all caps, no checksum
+		{"0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", false}, // invalid checksum
 	}
 
 	for _, test := range tests {
@@ -29,6 +34,23 @@ func TestValidAddress(t *testing.T) {
 	}
 }
 
+func TestChecksumAddress(t *testing.T) {
+	// EIP-55 test vectors
+	for _, want := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		if got := ChecksumAddress(strings.ToLower(want)); got != want {
+			t.Errorf("ChecksumAddress(%q) = %q; want %q", strings.ToLower(want), got, want)
+		}
+	}
+	if got := ChecksumAddress("me"); got != "me" {
+		t.Errorf("ChecksumAddress(%q) = %q; want it unchanged", "me", got)
+	}
+}
+
 func TestBlockParser_processBlockTransactions(t *testing.T) {
 	type fields struct {
 		observedAddrs map[string]int
@@ -57,11 +79,8 @@ func TestBlockParser_processBlockTransactions(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			bp := &BlockParser{
-				mu: sync.Mutex{},
-				store: &TransactionStorage{
-					transactions:  make(map[string][]Transaction),
-					observedAddrs: make(map[string]struct{}),
-				},
+				mu:    sync.Mutex{},
+				store: NewTransactionStorage(),
 			}
 
 			for addr := range tt.fields.observedAddrs {
@@ -91,11 +110,8 @@ func TestBlockParser_processBlockTransactions_Error_Handling(t *testing.T) {
 	logging.Init("debug")
 
 	bp := &BlockParser{
-		mu: sync.Mutex{},
-		store: &TransactionStorage{
-			transactions:  make(map[string][]Transaction),
-			observedAddrs: make(map[string]struct{}),
-		},
+		mu:    sync.Mutex{},
+		store: NewTransactionStorage(),
 	}
 
 	var blockData map[string]interface{}
@@ -0,0 +1,37 @@
+package rawtx
+
+import (
+	"encoding/hex"
+	"ethTx/cmd/util/keccak"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// authorizationMagic prefixes the signed message of EIP-7702 authorizations
+const authorizationMagic = 0x05
+
+// Authority recovers the account that signed EIP-7702 authorization to delegate its code to address.
+// chainID is 0 for authorizations valid on any chain.
+//
+// Authorizations with high s values are rejected as by EIP-7702.
+func Authority(chainID *big.Int, address string, nonce uint64, yParity uint64, r, s *big.Int) (string, error) {
+	addr, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(address, "0x"), "0X"))
+	if err != nil || len(addr) != 20 {
+		return "", fmt.Errorf("invalid authorization address %s", address)
+	}
+	if yParity > 1 {
+		return "", fmt.Errorf("invalid authorization y parity %d", yParity)
+	}
+	if s.Cmp(new(big.Int).Rsh(curveN, 1)) > 0 {
+		return "", fmt.Errorf("invalid authorization s value")
+	}
+
+	message := encodeRLP([]interface{}{chainID.Bytes(), addr, new(big.Int).SetUint64(nonce).Bytes()})
+	hash := keccak.Sum256([]byte{authorizationMagic}, message)
+	pub, err := recoverPublicKey(hash[:], r, s, byte(yParity))
+	if err != nil {
+		return "", err
+	}
+	return publicKeyAddress(pub), nil
+}
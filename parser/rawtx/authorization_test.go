@@ -0,0 +1,53 @@
+package rawtx
+
+import (
+	"ethTx/cmd/util/keccak"
+	"math/big"
+	"testing"
+)
+
+// signAuthorization signs EIP-7702 authorization with the EIP-155 example key, returning low s signature.
+func signAuthorization(chainID *big.Int, address []byte, nonce uint64) (uint64, *big.Int, *big.Int) {
+	key, _ := new(big.Int).SetString("4646464646464646464646464646464646464646464646464646464646464646", 16)
+	k := big.NewInt(0x1234567)
+
+	message := encodeRLP([]interface{}{chainID.Bytes(), address, new(big.Int).SetUint64(nonce).Bytes()})
+	hash := keccak.Sum256([]byte{authorizationMagic}, message)
+	z := new(big.Int).SetBytes(hash[:])
+
+	R := scalarMult(point{curveGx, curveGy}, k)
+	r := new(big.Int).Mod(R.x, curveN)
+	// s = k⁻¹(z + r·key)
+	s := new(big.Int).Mul(r, key)
+	s.Add(s, z).Mul(s, new(big.Int).ModInverse(k, curveN)).Mod(s, curveN)
+	yParity := uint64(R.y.Bit(0))
+	if s.Cmp(new(big.Int).Rsh(curveN, 1)) > 0 {
+		s.Sub(curveN, s)
+		yParity ^= 1
+	}
+	return yParity, r, s
+}
+
+func TestAuthority(t *testing.T) {
+	delegate := "0x63c0c19a282a1b52b07dd5a65b58948a07dae32b"
+	address := []byte{0x63, 0xc0, 0xc1, 0x9a, 0x28, 0x2a, 0x1b, 0x52, 0xb0, 0x7d, 0xd5, 0xa6, 0x5b, 0x58, 0x94, 0x8a, 0x07, 0xda, 0xe3, 0x2b}
+
+	yParity, r, s := signAuthorization(big.NewInt(1), address, 7)
+	got, err := Authority(big.NewInt(1), delegate, 7, yParity, r, s)
+	if err != nil || got != testSender {
+		t.Errorf("Authority() = %s, %v, want %s", got, err, testSender)
+	}
+
+	// signature of other nonce recovers other account
+	if got, err := Authority(big.NewInt(1), delegate, 8, yParity, r, s); err == nil && got == testSender {
+		t.Error("authorization of other nonce recovered the signer")
+	}
+
+	// high s values are not accepted
+	if _, err := Authority(big.NewInt(1), delegate, 7, yParity^1, r, new(big.Int).Sub(curveN, s)); err == nil {
+		t.Error("high s value accepted")
+	}
+	if _, err := Authority(big.NewInt(1), "0x1234", 7, yParity, r, s); err == nil {
+		t.Error("invalid address accepted")
+	}
+}
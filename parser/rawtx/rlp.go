@@ -0,0 +1,156 @@
+package rawtx
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// RLP values are either byte strings ([]byte) or lists ([]interface{}) of values.
+
+// decodeRLP decodes a single RLP value that must span the whole input.
+func decodeRLP(data []byte) (interface{}, error) {
+	v, rest, err := decodeRLPValue(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("rlp: %d trailing bytes", len(rest))
+	}
+	return v, nil
+}
+
+// decodeRLPValue decodes the first RLP value of data and returns the remaining bytes.
+func decodeRLPValue(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("rlp: unexpected end of input")
+	}
+
+	prefix := data[0]
+	switch {
+	case prefix < 0x80:
+		return data[:1], data[1:], nil
+
+	case prefix <= 0xb7:
+		size := int(prefix - 0x80)
+		if len(data) < 1+size {
+			return nil, nil, fmt.Errorf("rlp: string exceeds input")
+		}
+		if size == 1 && data[1] < 0x80 {
+			return nil, nil, fmt.Errorf("rlp: non-canonical single byte string")
+		}
+		return data[1 : 1+size], data[1+size:], nil
+
+	case prefix <= 0xbf:
+		size, offset, err := longSize(data, int(prefix-0xb7))
+		if err != nil {
+			return nil, nil, err
+		}
+		return data[offset : offset+size], data[offset+size:], nil
+
+	case prefix <= 0xf7:
+		size := int(prefix - 0xc0)
+		if len(data) < 1+size {
+			return nil, nil, fmt.Errorf("rlp: list exceeds input")
+		}
+		list, err := decodeRLPList(data[1 : 1+size])
+		return list, data[1+size:], err
+
+	default:
+		size, offset, err := longSize(data, int(prefix-0xf7))
+		if err != nil {
+			return nil, nil, err
+		}
+		list, err := decodeRLPList(data[offset : offset+size])
+		return list, data[offset+size:], err
+	}
+}
+
+// longSize reads the big endian payload length that follows the prefix byte.
+func longSize(data []byte, lenOfLen int) (int, int, error) {
+	if len(data) < 1+lenOfLen {
+		return 0, 0, fmt.Errorf("rlp: length exceeds input")
+	}
+	if data[1] == 0 {
+		return 0, 0, fmt.Errorf("rlp: non-canonical size")
+	}
+	if lenOfLen > 4 {
+		return 0, 0, fmt.Errorf("rlp: value too large")
+	}
+	size := 0
+	for _, b := range data[1 : 1+lenOfLen] {
+		size = size<<8 | int(b)
+	}
+	if size < 56 {
+		return 0, 0, fmt.Errorf("rlp: non-canonical size")
+	}
+	if len(data) < 1+lenOfLen+size {
+		return 0, 0, fmt.Errorf("rlp: value exceeds input")
+	}
+	return size, 1 + lenOfLen, nil
+}
+
+func decodeRLPList(data []byte) ([]interface{}, error) {
+	list := []interface{}{}
+	for len(data) > 0 {
+		v, rest, err := decodeRLPValue(data)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		data = rest
+	}
+	return list, nil
+}
+
+// encodeRLP encodes byte strings and (nested) lists of them.
+func encodeRLP(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		if len(v) == 1 && v[0] < 0x80 {
+			return []byte{v[0]}
+		}
+		return append(rlpHeader(0x80, len(v)), v...)
+	case []interface{}:
+		var payload []byte
+		for _, item := range v {
+			payload = append(payload, encodeRLP(item)...)
+		}
+		return append(rlpHeader(0xc0, len(payload)), payload...)
+	}
+	panic(fmt.Sprintf("rlp: unsupported type %T", v))
+}
+
+func rlpHeader(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+	var sizeBytes []byte
+	for s := size; s > 0; s >>= 8 {
+		sizeBytes = append([]byte{byte(s)}, sizeBytes...)
+	}
+	return append([]byte{offset + 55 + byte(len(sizeBytes))}, sizeBytes...)
+}
+
+// rlpBytes returns the byte string item of the list.
+func rlpBytes(list []interface{}, i int) ([]byte, error) {
+	b, ok := list[i].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("field %d: expected string, got list", i)
+	}
+	return b, nil
+}
+
+// rlpInt returns the integer item of the list. Integers must not have leading zero bytes.
+func rlpInt(list []interface{}, i int) (*big.Int, error) {
+	b, err := rlpBytes(list, i)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > 0 && b[0] == 0 {
+		return nil, fmt.Errorf("field %d: integer with leading zero", i)
+	}
+	if len(b) > 32 {
+		return nil, fmt.Errorf("field %d: integer too large", i)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,106 @@
+package rawtx
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// secp256k1 curve y² = x³ + 7 over the prime field p
+var (
+	curveP, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+	curveN, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	curveGx, _ = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+	curveGy, _ = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+	curveB     = big.NewInt(7)
+)
+
+// point is an affine curve point, nil coordinates denote the point at infinity
+type point struct {
+	x, y *big.Int
+}
+
+func (pt point) infinity() bool {
+	return pt.x == nil
+}
+
+func addPoints(a, b point) point {
+	if a.infinity() {
+		return b
+	}
+	if b.infinity() {
+		return a
+	}
+
+	var lambda *big.Int
+	if a.x.Cmp(b.x) == 0 {
+		sum := new(big.Int).Add(a.y, b.y)
+		if sum.Mod(sum, curveP).Sign() == 0 {
+			return point{}
+		}
+		// tangent: 3x² / 2y
+		num := new(big.Int).Mul(a.x, a.x)
+		num.Mul(num, big.NewInt(3))
+		den := new(big.Int).Lsh(a.y, 1)
+		lambda = num.Mul(num, den.ModInverse(den, curveP))
+	} else {
+		// chord: (y2 - y1) / (x2 - x1)
+		num := new(big.Int).Sub(b.y, a.y)
+		den := new(big.Int).Sub(b.x, a.x)
+		den.Mod(den, curveP)
+		lambda = num.Mul(num, den.ModInverse(den, curveP))
+	}
+	lambda.Mod(lambda, curveP)
+
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, a.x).Sub(x, b.x).Mod(x, curveP)
+	y := new(big.Int).Sub(a.x, x)
+	y.Mul(y, lambda).Sub(y, a.y).Mod(y, curveP)
+	return point{x, y}
+}
+
+func scalarMult(pt point, k *big.Int) point {
+	result := point{}
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = addPoints(result, result)
+		if k.Bit(i) == 1 {
+			result = addPoints(result, pt)
+		}
+	}
+	return result
+}
+
+// recoverPublicKey recovers the signer public key from the signature (r, s, recovery id) of the hash.
+//
+// High s values (rejected by EIP-2 for new transactions) are accepted so that historical transactions can be verified.
+func recoverPublicKey(hash []byte, r, s *big.Int, recoveryID byte) (point, error) {
+	if r.Sign() <= 0 || r.Cmp(curveN) >= 0 || s.Sign() <= 0 || s.Cmp(curveN) >= 0 {
+		return point{}, fmt.Errorf("invalid signature values")
+	}
+	if recoveryID > 1 {
+		// x coordinates above n are practically impossible and not accepted by Ethereum
+		return point{}, fmt.Errorf("invalid recovery id %d", recoveryID)
+	}
+
+	// R is the point with x = r and y of the parity given by the recovery id
+	y2 := new(big.Int).Exp(r, big.NewInt(3), curveP)
+	y2.Add(y2, curveB).Mod(y2, curveP)
+	y := new(big.Int).ModSqrt(y2, curveP)
+	if y == nil {
+		return point{}, fmt.Errorf("signature r is not on the curve")
+	}
+	if y.Bit(0) != uint(recoveryID) {
+		y.Sub(curveP, y)
+	}
+	R := point{new(big.Int).Set(r), y}
+
+	// Q = r⁻¹(sR - zG)
+	z := new(big.Int).SetBytes(hash)
+	z.Neg(z).Mod(z, curveN)
+	sR := scalarMult(R, s)
+	zG := scalarMult(point{curveGx, curveGy}, z)
+	Q := scalarMult(addPoints(sR, zG), new(big.Int).ModInverse(r, curveN))
+	if Q.infinity() {
+		return point{}, fmt.Errorf("recovered point at infinity")
+	}
+	return Q, nil
+}
@@ -0,0 +1,202 @@
+// Package rawtx decodes signed raw Ethereum transactions and recovers their sender.
+//
+// It is used to verify transaction data reported by the node independently of it.
+package rawtx
+
+import (
+	"encoding/hex"
+	"ethTx/cmd/util/keccak"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Transaction types
+const (
+	TypeLegacy     = 0x00
+	TypeAccessList = 0x01 // EIP-2930
+	TypeDynamicFee = 0x02 // EIP-1559
+	TypeBlob       = 0x03 // EIP-4844
+	TypeSetCode    = 0x04 // EIP-7702
+)
+
+// number of RLP fields of each transaction type, signature included
+var fieldCount = map[int]int{
+	TypeLegacy:     9,
+	TypeAccessList: 11,
+	TypeDynamicFee: 12,
+	TypeBlob:       14,
+	TypeSetCode:    13,
+}
+
+// Transaction is a decoded signed transaction
+type Transaction struct {
+	Type    int
+	ChainID *big.Int // nil for legacy transactions signed without replay protection
+	Nonce   uint64
+	To      string // lowercase hex address, empty for contract creation
+	Value   *big.Int
+	Input   []byte
+	Hash    string // 0x prefixed keccak of the raw transaction
+	From    string // lowercase hex address recovered from the signature
+}
+
+// DecodeHex decodes 0x prefixed raw transaction.
+func DecodeHex(s string) (*Transaction, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw transaction hex: %w", err)
+	}
+	return Decode(raw)
+}
+
+// Decode decodes raw transaction in its canonical encoding, computes its hash and recovers the sender.
+func Decode(raw []byte) (*Transaction, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty raw transaction")
+	}
+
+	txType := TypeLegacy
+	payload := raw
+	// typed transactions are prefixed with their type, legacy ones start with RLP list prefix
+	if raw[0] < 0x7f {
+		txType = int(raw[0])
+		payload = raw[1:]
+	}
+	count, ok := fieldCount[txType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported transaction type %d", txType)
+	}
+
+	v, err := decodeRLP(payload)
+	if err != nil {
+		return nil, err
+	}
+	fields, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("transaction is not an RLP list")
+	}
+	// blob transactions in network form wrap the transaction together with blobs, commitments and proofs
+	if txType == TypeBlob && len(fields) > 0 {
+		if inner, ok := fields[0].([]interface{}); ok {
+			fields = inner
+			payload = encodeRLP(inner)
+			raw = append([]byte{TypeBlob}, payload...)
+		}
+	}
+	if len(fields) != count {
+		return nil, fmt.Errorf("transaction type %d: expected %d fields, got %d", txType, count, len(fields))
+	}
+
+	tx := &Transaction{Type: txType}
+	hash := keccak.Sum256(raw)
+	tx.Hash = "0x" + hex.EncodeToString(hash[:])
+
+	// field positions of nonce, to, value and data
+	nonceAt, toAt, valueAt, inputAt := 0, 3, 4, 5
+	if txType != TypeLegacy {
+		if tx.ChainID, err = rlpInt(fields, 0); err != nil {
+			return nil, err
+		}
+		nonceAt, toAt, valueAt, inputAt = 1, 4, 5, 6
+		if txType != TypeAccessList {
+			// maxPriorityFeePerGas and maxFeePerGas replace gasPrice
+			toAt, valueAt, inputAt = 5, 6, 7
+		}
+	}
+
+	nonce, err := rlpInt(fields, nonceAt)
+	if err != nil {
+		return nil, err
+	}
+	if !nonce.IsUint64() {
+		return nil, fmt.Errorf("nonce too large")
+	}
+	tx.Nonce = nonce.Uint64()
+
+	to, err := rlpBytes(fields, toAt)
+	if err != nil {
+		return nil, err
+	}
+	switch len(to) {
+	case 0:
+		if txType == TypeBlob || txType == TypeSetCode {
+			return nil, fmt.Errorf("transaction type %d cannot create contracts", txType)
+		}
+	case 20:
+		tx.To = "0x" + hex.EncodeToString(to)
+	default:
+		return nil, fmt.Errorf("invalid recipient length %d", len(to))
+	}
+
+	if tx.Value, err = rlpInt(fields, valueAt); err != nil {
+		return nil, err
+	}
+	if tx.Input, err = rlpBytes(fields, inputAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.recoverSender(fields); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// recoverSender computes the signing hash and recovers the sender from the signature fields.
+func (tx *Transaction) recoverSender(fields []interface{}) error {
+	n := len(fields)
+	v, err := rlpInt(fields, n-3)
+	if err != nil {
+		return err
+	}
+	r, err := rlpInt(fields, n-2)
+	if err != nil {
+		return err
+	}
+	s, err := rlpInt(fields, n-1)
+	if err != nil {
+		return err
+	}
+
+	unsigned := fields[:n-3]
+	var signingHash [32]byte
+	var recoveryID byte
+	if tx.Type == TypeLegacy {
+		switch {
+		case v.Cmp(big.NewInt(27)) == 0 || v.Cmp(big.NewInt(28)) == 0:
+			recoveryID = byte(v.Int64() - 27)
+			signingHash = keccak.Sum256(encodeRLP(unsigned))
+		case v.Cmp(big.NewInt(35)) >= 0:
+			// EIP-155: v = chainId * 2 + 35 + recovery id, chainId, 0, 0 are signed instead of the signature
+			id := new(big.Int).Sub(v, big.NewInt(35))
+			recoveryID = byte(id.Bit(0))
+			tx.ChainID = id.Rsh(id, 1)
+			signed := append(append([]interface{}{}, unsigned...), tx.ChainID.Bytes(), []byte{}, []byte{})
+			signingHash = keccak.Sum256(encodeRLP(signed))
+		default:
+			return fmt.Errorf("invalid signature v %s", v.String())
+		}
+	} else {
+		if v.Cmp(big.NewInt(1)) > 0 {
+			return fmt.Errorf("invalid signature y parity %s", v.String())
+		}
+		recoveryID = byte(v.Int64())
+		signingHash = keccak.Sum256([]byte{byte(tx.Type)}, encodeRLP(unsigned))
+	}
+
+	pub, err := recoverPublicKey(signingHash[:], r, s, recoveryID)
+	if err != nil {
+		return err
+	}
+	tx.From = publicKeyAddress(pub)
+	return nil
+}
+
+// publicKeyAddress returns the address of the public key, the last 20 bytes of keccak of its uncompressed form.
+func publicKeyAddress(pub point) string {
+	var key [64]byte
+	pub.x.FillBytes(key[:32])
+	pub.y.FillBytes(key[32:])
+	addrHash := keccak.Sum256(key[:])
+	return "0x" + hex.EncodeToString(addrHash[12:])
+}
@@ -0,0 +1,162 @@
+package rawtx
+
+import (
+	"strings"
+	"testing"
+)
+
+// all transactions are signed with the EIP-155 example key 0x4646...46
+const testSender = "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f"
+
+func TestDecodeHex(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		txType  int
+		chainID int64 // -1 when not replay protected
+		nonce   uint64
+		to      string
+		value   string
+		hash    string
+	}{
+		{
+			name:    "EIP-155 example",
+			raw:     "0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83",
+			txType:  TypeLegacy,
+			chainID: 1,
+			nonce:   9,
+			to:      "0x3535353535353535353535353535353535353535",
+			value:   "1000000000000000000",
+			hash:    "0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788",
+		},
+		{
+			name:    "legacy without replay protection",
+			raw:     "0xf8640b8504a817c80082520894353535353535353535353535353535353535353505801ba087815e7cd1e5e88eeb882b23955de59ad63f14fd457a8d964b47a611b3e40043a01df8efe10aef8ee19b85df1a75ad54f3ecb13fcfad76348ee1b66fd67fe3d22e",
+			txType:  TypeLegacy,
+			chainID: -1,
+			nonce:   11,
+			to:      "0x3535353535353535353535353535353535353535",
+			value:   "5",
+			hash:    "0xf9d24bf618f8eca1141667cc20937f7b78d65098b7bd9768eade11c5738368d1",
+		},
+		{
+			name:    "access list",
+			raw:     "0x01f8a701078504a817c800825208943535353535353535353535353535353535353535880de0b6b3a764000080f838f794dac17f958d2ee523a2206206994597c13d831ec7e1a0000000000000000000000000000000000000000000000000000000000000000180a0f01d6b9018ab421dd410404cb869072065522bf85734008f105cf385a023a80fa01673dd3d1549cda74f89bafbc1a857b9dff9f935d0fc50758e1b3bdd884161d2",
+			txType:  TypeAccessList,
+			chainID: 1,
+			nonce:   7,
+			to:      "0x3535353535353535353535353535353535353535",
+			value:   "1000000000000000000",
+			hash:    "0x204f861534ae1300cae717068662e69000c1e7ad4facfd8f35ac146bd96c45ab",
+		},
+		{
+			name:    "dynamic fee",
+			raw:     "0x02f86f010884773594008506fc23ac0082ea609435353535353535353535353535353535353535358084a9059cbbc080a054006a630e70fca8d45a696d7dba3a1cb7d81cc830cfce3be0f634373aac3f0aa06ceb8bdc3c8ad8bc10729256147da1c88ff173182032a1b4f1a730b6542bebf7",
+			txType:  TypeDynamicFee,
+			chainID: 1,
+			nonce:   8,
+			to:      "0x3535353535353535353535353535353535353535",
+			value:   "0",
+			hash:    "0xcf45fd24fd4ef55f17eaaf9438922d4cdb047770abb47f10b30dc9da848bd0d6",
+		},
+		{
+			name:    "dynamic fee contract creation",
+			raw:     "0x02f85d018084773594008506fc23ac00830f42408080856080604052c080a0a0f0cc17fd00a2cb5fc7c981860f02f3eb31c26d54841dde189eac65a446f516a004b032e8f4e4e65eb52427f5d415155c512f965ecd543d64145e959d50e3d085",
+			txType:  TypeDynamicFee,
+			chainID: 1,
+			nonce:   0,
+			to:      "",
+			value:   "0",
+			hash:    "0xedac1ce1f210f0a92e18e22c9e206f1149162907a1d8525d35489552520d6f6f",
+		},
+		{
+			name:    "blob",
+			raw:     "0x03f8920109843b9aca008504a817c8008252089435353535353535353535353535353535353535358080c0843b9aca00e1a0010000000000000000000000000000000000000000000000000000000000000080a06448670ac8ae31f33ca2841f8a741c6d24b1d31e056c3c8f2f899bf6b90a46c0a052cbfbefd505fe613f9d44879610ba8c94224961a03fe2665243eb502ad0c597",
+			txType:  TypeBlob,
+			chainID: 1,
+			nonce:   9,
+			to:      "0x3535353535353535353535353535353535353535",
+			value:   "0",
+			hash:    "0xfa5fa0d4647f7146eebb2403c76f94bacb4b275e7405ed2397f5214402ac00fe",
+		},
+		{
+			name:    "set code",
+			raw:     "0x04f888010a843b9aca008504a817c800830186a09435353535353535353535353535353535353535358080c0dbda019435353535353535353535353535353535353535350380010201a083b746c7bf57e42e94097ef91816bc8f071ccc2964ed8d5e63be9a8a158c253ea07d6c6008bf84dbdcb77bfc6ac223c07177a38836f9c7b14f1a61cc7020830886",
+			txType:  TypeSetCode,
+			chainID: 1,
+			nonce:   10,
+			to:      "0x3535353535353535353535353535353535353535",
+			value:   "0",
+			hash:    "0x996851f15321b8c49d9989cae94c261d63327154687957865a2c306b1a8f169a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := DecodeHex(tt.raw)
+			if err != nil {
+				t.Fatalf("DecodeHex failed: %v", err)
+			}
+			if tx.From != testSender {
+				t.Errorf("From = %s; want %s", tx.From, testSender)
+			}
+			if tx.Hash != tt.hash {
+				t.Errorf("Hash = %s; want %s", tx.Hash, tt.hash)
+			}
+			if tx.Type != tt.txType || tx.Nonce != tt.nonce || tx.To != tt.to || tx.Value.String() != tt.value {
+				t.Errorf("got type %d nonce %d to %q value %s", tx.Type, tx.Nonce, tx.To, tx.Value)
+			}
+			if (tt.chainID == -1) != (tx.ChainID == nil) || (tx.ChainID != nil && tx.ChainID.Int64() != tt.chainID) {
+				t.Errorf("ChainID = %v; want %d", tx.ChainID, tt.chainID)
+			}
+		})
+	}
+}
+
+func TestDecodeHexTampered(t *testing.T) {
+	// EIP-155 example with the value changed from 1 to 2 ether, the signature no longer matches the sender
+	raw := "0xf86c098504a817c800825208943535353535353535353535353535353535353535881bc16d674ec800008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+	tx, err := DecodeHex(raw)
+	if err != nil {
+		return
+	}
+	if tx.From == testSender {
+		t.Errorf("tampered transaction recovered to the original sender")
+	}
+}
+
+func TestDecodeHexInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		err  string
+	}{
+		{"empty", "0x", "empty raw transaction"},
+		{"not hex", "0xzz", "invalid raw transaction hex"},
+		{"unknown type", "0x05c0", "unsupported transaction type 5"},
+		{"not a list", "0x8180", "not an RLP list"},
+		{"truncated", "0xf86c0985", "rlp:"},
+		{"missing fields", "0x02c3010203", "expected 12 fields, got 3"},
+		{"non-canonical byte", "0xc28101", "non-canonical single byte string"},
+	}
+
+	for _, tt := range tests {
+		_, err := DecodeHex(tt.raw)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v; want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestRLPRoundTrip(t *testing.T) {
+	long := make([]byte, 60)
+	v := []interface{}{[]byte{}, []byte{0x7f}, []byte{0x80}, long, []interface{}{[]byte("dog"), []interface{}{}}}
+	encoded := encodeRLP(v)
+	decoded, err := decodeRLP(encoded)
+	if err != nil {
+		t.Fatalf("decodeRLP failed: %v", err)
+	}
+	if string(encodeRLP(decoded)) != string(encoded) {
+		t.Errorf("round trip changed the encoding: %x", encodeRLP(decoded))
+	}
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"encoding/hex"
+	L "ethTx/cmd/util/logging"
+	"ethTx/parser/abi"
+	"fmt"
+	"strings"
+)
+
+// Log is an event emitted by a transaction
+type Log struct {
+	Address  string            `json:"address"`
+	Topics   []string          `json:"topics"`
+	Data     string            `json:"data"`
+	LogIndex int               `json:"logIndex"`
+	Event    *abi.DecodedEvent `json:"event,omitempty"` // Decoded event when contract ABI is known
+
+	Token     *TokenMetadata `json:"token,omitempty"`     // Metadata of the token for ERC-20 Transfer events
+	Formatted string         `json:"formatted,omitempty"` // Transferred amount in token units, e.g. `1.5 USDC`
+}
+
+// getTransactionReceipt fetches transaction receipt using eth_getTransactionReceipt.
+func (bp *BlockParser) getTransactionReceipt(hash string) (map[string]interface{}, error) {
+	result, err := bp.call("eth_getTransactionReceipt", hash)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("receipt of %s not available: %v", hash, result)
+	}
+	return receipt, nil
+}
+
+// applyReceipt fetches receipt of tx and copies execution results and logs to it.
+func (bp *BlockParser) applyReceipt(tx *Transaction) {
+	receipt, err := bp.getTransactionReceipt(tx.Hash)
+	if err != nil {
+		L.L.Error("Failed fetching receipt of", tx.Hash, "Error:", err.Error())
+		return
+	}
+
+	tx.Status, _ = receipt["status"].(string)
+	tx.GasUsed, _ = receipt["gasUsed"].(string)
+	tx.EffectiveGasPrice, _ = receipt["effectiveGasPrice"].(string)
+	tx.BlobGasUsed, _ = receipt["blobGasUsed"].(string)
+	tx.BlobGasPrice, _ = receipt["blobGasPrice"].(string)
+	if contractAddress, _ := receipt["contractAddress"].(string); tx.ContractCreation {
+		tx.ContractAddress = normalizeAddress(contractAddress)
+	}
+
+	rawLogs, _ := receipt["logs"].([]interface{})
+	tx.Logs = make([]Log, 0, len(rawLogs))
+	for _, l := range rawLogs {
+		logMap, ok := l.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		address, _ := logMap["address"].(string)
+		data, _ := logMap["data"].(string)
+		logIndex, _ := logMap["logIndex"].(string)
+		rawTopics, _ := logMap["topics"].([]interface{})
+		topics := make([]string, 0, len(rawTopics))
+		for _, t := range rawTopics {
+			topic, _ := t.(string)
+			topics = append(topics, topic)
+		}
+		tx.Logs = append(tx.Logs, Log{
+			Address:  normalizeAddress(address),
+			Topics:   topics,
+			Data:     data,
+			LogIndex: hexToInt(logIndex),
+		})
+	}
+}
+
+// decodeLogs decodes logs of tx using ABIs registered for the emitting contracts,
+// falling back to the signature registry. Caller must hold bp.mu.
+func (bp *BlockParser) decodeLogs(tx *Transaction) {
+	for i := range tx.Logs {
+		log := &tx.Logs[i]
+		if amount, ok := tokenTransferAmount(log.Topics, log.Data); ok {
+			log.Token, log.Formatted = bp.formatTokenAmount(log.Address, amount)
+		}
+
+		topics, data, err := log.decodeHex()
+		if err != nil || len(topics) == 0 {
+			continue
+		}
+
+		if contractABI := bp.store.ABI(log.Address); contractABI != nil {
+			event, err := contractABI.DecodeLog(topics, data)
+			if err == nil {
+				log.Event = event
+				continue
+			}
+			L.L.Debug("Decoding log", fmt.Sprintf("%d", log.LogIndex), "of", tx.Hash, "failed:", err.Error())
+		}
+
+		if bp.sigs != nil {
+			log.Event = guessEvent(bp.sigs.Events(log.Topics[0]), topics, data)
+		}
+	}
+}
+
+// guessEvent decodes log using the first matching signature. Parameters are
+// assumed to be indexed in declaration order, as most contracts do.
+//
+// When no signature matches the log is only labeled with the first signature.
+func guessEvent(signatures []string, topics [][]byte, data []byte) *abi.DecodedEvent {
+	for _, sig := range signatures {
+		ev, err := abi.ParseEventSignature(sig, len(topics)-1)
+		if err != nil {
+			continue
+		}
+		event, err := ev.Decode(topics, data)
+		if err != nil {
+			continue
+		}
+		event.Guessed = true
+		return event
+	}
+	if len(signatures) > 0 {
+		name, _, _ := strings.Cut(signatures[0], "(")
+		return &abi.DecodedEvent{Name: name, Signature: signatures[0], Guessed: true}
+	}
+	return nil
+}
+
+// decodeHex returns raw bytes of log topics and data.
+func (log Log) decodeHex() ([][]byte, []byte, error) {
+	topics := make([][]byte, 0, len(log.Topics))
+	for _, t := range log.Topics {
+		topic, err := hex.DecodeString(strings.TrimPrefix(t, "0x"))
+		if err != nil {
+			return nil, nil, err
+		}
+		topics = append(topics, topic)
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(log.Data, "0x"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return topics, data, nil
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"ethTx/parser/sigdb"
+	"testing"
+)
+
+const approvalABI = `[{"type": "event", "name": "Approval", "inputs": [
+    {"name": "owner", "type": "address", "indexed": true},
+    {"name": "spender", "type": "address", "indexed": true},
+    {"name": "value", "type": "uint256", "indexed": false}
+]}]`
+
+const callReceipt = `{
+    "transactionHash": "0xaa",
+    "status": "0x1",
+    "gasUsed": "0xb411",
+    "effectiveGasPrice": "0x2a6278c8a",
+    "contractAddress": null,
+    "logs": [
+        {
+            "address": "` + callToken + `",
+            "topics": [
+                "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925",
+                "0x00000000000000000000000098c3d3183c4b8a650614ad179a1a98be0a8d6b8e",
+                "0x0000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f"
+            ],
+            "data": "0x00000000000000000000000000000000000000000000000000000000000003e8",
+            "logIndex": "0x5"
+        },
+        {
+            "address": "0x0000000000000000000000000000000000000001",
+            "topics": ["0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"],
+            "data": "0x",
+            "logIndex": "0x6"
+        }
+    ]
+}`
+
+func TestBlockParser_processBlockTransactions_Receipt(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{"eth_getTransactionReceipt": callReceipt})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+	if err := bp.RegisterABI(callToken, []byte(approvalABI)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bp.processBlockTransactions(callBlock()); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, ok := bp.GetTransaction("0xaa")
+	if !ok {
+		t.Fatal("transaction 0xaa should be stored")
+	}
+	if tx.Status != "0x1" || tx.GasUsed != "0xb411" || tx.EffectiveGasPrice != "0x2a6278c8a" {
+		t.Errorf("unexpected receipt fields %+v", tx)
+	}
+	if len(tx.Logs) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(tx.Logs))
+	}
+
+	event := tx.Logs[0].Event
+	if event == nil || event.Name != "Approval" || event.Params[1].Value != "0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f" || event.Params[2].Value != "1000" {
+		t.Errorf("unexpected decoded event %+v", event)
+	}
+	if tx.Logs[1].Event != nil || tx.Logs[1].LogIndex != 6 {
+		t.Errorf("log of contract without ABI should stay undecoded: %+v", tx.Logs[1])
+	}
+}
+
+func TestBlockParser_processBlockTransactions_GuessedEvents(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{"eth_getTransactionReceipt": callReceipt})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage(), sigs: sigdb.New()}
+	bp.Subscribe(callWallet)
+
+	if err := bp.processBlockTransactions(callBlock()); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, _ := bp.GetTransaction("0xaa")
+	event := tx.Logs[0].Event
+	if event == nil || !event.Guessed || event.Signature != "Approval(address,address,uint256)" || event.Params[2].Value != "1000" {
+		t.Errorf("unexpected guessed event %+v", event)
+	}
+	// topic matches but there is no data to decode
+	event = tx.Logs[1].Event
+	if event == nil || event.Name != "Approval" || event.Params != nil {
+		t.Errorf("log should be labeled only, got %+v", event)
+	}
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+// call performs a single JSON-RPC request and returns the `result` field of the response.
+func (bp *BlockParser) call(method string, params ...interface{}) (interface{}, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	requestBody := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"id":      1,
+	}
+	requestData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	L.L.Debug("URL:", bp.rpcURL, "Request data:", string(requestData))
+
+	resp, err := http.Post(bp.rpcURL, "application/json", bytes.NewReader(requestData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]interface{}
+	decoder := json.NewDecoder(resp.Body)
+	err = decoder.Decode(&response)
+	if err != nil {
+		L.L.Error(method+": failed decoding response body", err.Error())
+		return nil, err
+	}
+
+	if rpcErr, ok := response["error"].(map[string]interface{}); ok {
+		return nil, &rpcError{method: method, message: fmt.Sprintf("%v", rpcErr["message"])}
+	}
+
+	result, ok := response["result"]
+	if !ok {
+		return nil, fmt.Errorf("invalid response: %v", response)
+	}
+	return result, nil
+}
+
+// rpcError is an error returned by the node, as opposed to failures reaching it
+type rpcError struct {
+	method  string
+	message string
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.method, e.message)
+}
+
+// hexToInt converts `0x` prefixed hex quantity to int.
+func hexToInt(s string) int {
+	var i int
+	fmt.Sscanf(s, "0x%x", &i)
+	return i
+}
+
+// hexToBig converts `0x` prefixed hex quantity (or 32 byte word) to big.Int.
+// Invalid input yields zero.
+func hexToBig(s string) *big.Int {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"), 16)
+	if !ok {
+		return new(big.Int)
+	}
+	return n
+}
+
+// topicToAddress extracts the address from a 32 byte, left padded, log topic.
+func topicToAddress(topic string) string {
+	topic = strings.TrimPrefix(topic, "0x")
+	if len(topic) < 40 {
+		return ""
+	}
+	return "0x" + strings.ToLower(topic[len(topic)-40:])
+}
+
+// addressToTopic left pads the address to a 32 byte log topic.
+func addressToTopic(address string) string {
+	return "0x" + strings.Repeat("0", 24) + strings.TrimPrefix(address, "0x")
+}
+
+// dataWords splits log data into 32 byte hex words.
+func dataWords(data string) []string {
+	data = strings.TrimPrefix(data, "0x")
+	words := make([]string, 0, len(data)/64)
+	for i := 0; i+64 <= len(data); i += 64 {
+		words = append(words, data[i:i+64])
+	}
+	return words
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// mockRPC starts JSON-RPC server answering each method with the given raw JSON result.
+//
+// Unknown methods are answered with JSON-RPC error.
+func mockRPC(t *testing.T, results map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		result, ok := results[req.Method]
+		if !ok {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBlockParser_call(t *testing.T) {
+	rpc := mockRPC(t, map[string]string{"eth_chainId": `"0x1"`})
+	bp := &BlockParser{rpcURL: rpc.URL}
+
+	result, err := bp.call("eth_chainId")
+	if err != nil || result != "0x1" {
+		t.Errorf("call(eth_chainId) = %v, %v; want 0x1", result, err)
+	}
+
+	if _, err := bp.call("eth_unknown"); err == nil {
+		t.Error("call(eth_unknown) should fail")
+	}
+}
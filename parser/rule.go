@@ -0,0 +1,410 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"ethTx/parser/rules"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// AlertRule is raised when a matched transaction satisfies an alert rule
+const AlertRule = "rule"
+
+// ruleWindow is the longest window of rule aggregates, older activity is forgotten
+const ruleWindow = 24 * time.Hour
+
+// Rule is a condition evaluated against matched transactions of subscribed addresses, e.g.
+// `outbound && value > 10 ether`, `firstCounterparty` or `txCount(10m) > 5`
+type Rule struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Address    string `json:"address,omitempty"` // Subscribed address the rule applies to, all subscribed addresses when empty
+	Priority   string `json:"priority"`          // Priority of raised alerts, PriorityNormal or PriorityHigh
+
+	expr *rules.Expr
+}
+
+// ruleSchema declares fields and aggregates available to rule expressions. Values are in Wei.
+var ruleSchema = rules.Schema{
+	Fields: map[string]rules.Kind{
+		"value":             rules.Number,
+		"gasUsed":           rules.Number,
+		"blockNumber":       rules.Number,
+		"from":              rules.String,
+		"to":                rules.String,
+		"address":           rules.String, // subscribed address the transaction is evaluated for
+		"counterparty":      rules.String, // the other party of the transaction
+		"kind":              rules.String, // KindTransaction, KindInternal, KindWithdrawal, KindTokenTransfer or KindNFTTransfer
+		"token":             rules.String, // contract of token transfers
+		"tokenAmount":       rules.Number, // amount of token transfers in base units
+		"status":            rules.String,
+		"method":            rules.String, // name of the decoded method
+		"outbound":          rules.Bool,
+		"inbound":           rules.Bool,
+		"failed":            rules.Bool,
+		"contractCreation":  rules.Bool,
+		"firstCounterparty": rules.Bool, // no earlier transaction with the counterparty was seen
+		"denylisted":        rules.Bool,
+	},
+	Functions: map[string]rules.Kind{
+		"txCount":       rules.Number, // transactions of the address within the window, including the evaluated one
+		"outboundValue": rules.Number, // value sent by the address within the window
+		"inboundValue":  rules.Number, // value received by the address within the window
+	},
+	MaxWindow: ruleWindow,
+}
+
+// Kinds of token movements evaluated by alert rules, in addition to the kinds of stored transactions
+const (
+	KindTokenTransfer = "token-transfer" // ERC-20 transfer
+	KindNFTTransfer   = "nft-transfer"   // ERC-721 or ERC-1155 transfer
+)
+
+// ruleEntry is matched activity of the processed block to be evaluated by alert rules
+type ruleEntry struct {
+	key         string      // identifies the entry, e.g. hash of top-level transaction or position of internal transfer
+	tx          Transaction // the entry, token transfers carry only parties, hash and block number
+	token       string      // contract of token transfers
+	tokenAmount *big.Int    // amount of token transfers in base units
+}
+
+// txHash returns the transaction the entry belongs to, empty for withdrawals.
+func (e ruleEntry) txHash() string {
+	if e.tx.Hash != "" {
+		return e.tx.Hash
+	}
+	return e.tx.ParentHash
+}
+
+// activity is matched activity of a subscribed address remembered for rule aggregates
+type activity struct {
+	key      string
+	txHash   string
+	time     time.Time
+	value    *big.Int
+	outbound bool
+}
+
+// normalizeRule validates the rule and compiles its expression.
+func normalizeRule(r Rule) (Rule, error) {
+	r.Expression = strings.TrimSpace(r.Expression)
+	if r.Expression == "" {
+		return Rule{}, fmt.Errorf("expression is empty")
+	}
+	expr, err := rules.Parse(r.Expression, ruleSchema)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid expression: %w", err)
+	}
+	r.expr = expr
+
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		r.Name = r.Expression
+	}
+	if r.Address != "" {
+		if !validAddress(r.Address) {
+			return Rule{}, fmt.Errorf("address %s is not valid hex number", r.Address)
+		}
+		r.Address = normalizeAddress(r.Address)
+	}
+	switch r.Priority {
+	case "":
+		r.Priority = PriorityNormal
+	case PriorityNormal, PriorityHigh:
+	default:
+		return Rule{}, fmt.Errorf("unknown priority %s", r.Priority)
+	}
+	return r, nil
+}
+
+// AddRule adds the alert rule and returns it with its assigned ID.
+func (bp *BlockParser) AddRule(r Rule) (Rule, error) {
+	r, err := normalizeRule(r)
+	if err != nil {
+		return Rule{}, err
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.ruleSeq++
+	r.ID = bp.ruleSeq
+	bp.store.StoreRule(r)
+	L.L.Info("Rule", fmt.Sprintf("%d", r.ID), "added:", r.Expression)
+	return r, nil
+}
+
+// UpdateRule replaces the alert rule with the given ID.
+func (bp *BlockParser) UpdateRule(id int, r Rule) (Rule, error) {
+	r, err := normalizeRule(r)
+	if err != nil {
+		return Rule{}, err
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if _, ok := bp.store.Rule(id); !ok {
+		return Rule{}, fmt.Errorf("rule %d not found", id)
+	}
+	r.ID = id
+	bp.store.StoreRule(r)
+	L.L.Info("Rule", fmt.Sprintf("%d", r.ID), "updated:", r.Expression)
+	return r, nil
+}
+
+// GetRule returns the alert rule with the given ID.
+func (bp *BlockParser) GetRule(id int) (Rule, bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.store.Rule(id)
+}
+
+// GetRules returns all alert rules ordered by ID.
+func (bp *BlockParser) GetRules() []Rule {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.store.Rules()
+}
+
+// RemoveRule removes the alert rule. It returns false when there was none. Alerts it raised are kept.
+func (bp *BlockParser) RemoveRule(id int) bool {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if _, ok := bp.store.Rule(id); !ok {
+		return false
+	}
+	bp.store.RemoveRule(id)
+	L.L.Info("Rule", fmt.Sprintf("%d", id), "removed")
+	return true
+}
+
+// GetRuleAlerts returns alerts raised by the alert rule.
+func (bp *BlockParser) GetRuleAlerts(id int) []Alert {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	alerts := []Alert{}
+	for _, a := range bp.store.Alerts() {
+		if a.Type == AlertRule && a.RuleID == id {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
+
+// queueRuleEntry queues matched activity of the processed block for evaluateRules. Caller must hold bp.mu.
+func (bp *BlockParser) queueRuleEntry(e ruleEntry) {
+	bp.ruleEntries = append(bp.ruleEntries, e)
+}
+
+// queueTransaction queues the stored transaction, internal transfer or withdrawal. Caller must hold bp.mu.
+func (bp *BlockParser) queueTransaction(tx Transaction) {
+	key := tx.Hash
+	switch tx.Kind {
+	case KindInternal:
+		key = tx.ParentHash + "/" + tx.TraceAddress
+	case KindWithdrawal:
+		key = fmt.Sprintf("withdrawal/%d", tx.Withdrawal.Index)
+	}
+	bp.queueRuleEntry(ruleEntry{key: key, tx: tx})
+}
+
+// evaluateRules evaluates alert rules against matched activity queued while the block was processed, for each
+// subscribed address it involves. Aggregates are computed over block timestamps.
+func (bp *BlockParser) evaluateRules(blockData map[string]interface{}) {
+	blockTime := time.Now().UTC()
+	if timestamp, ok := blockData["timestamp"].(string); ok {
+		blockTime = time.Unix(int64(hexToInt(timestamp)), 0).UTC()
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	entries := bp.ruleEntries
+	bp.ruleEntries = nil
+	rs := bp.store.Rules()
+	for _, entry := range entries {
+		seen := make(map[string]bool)
+		for _, address := range []string{entry.tx.From, entry.tx.To, entry.tx.ContractAddress} {
+			// transfer to self is evaluated once
+			if address == "" || seen[address] || !bp.store.IsObserved(address) {
+				continue
+			}
+			seen[address] = true
+			bp.recordActivity(address, entry, blockTime)
+			env := ruleEnv{bp: bp, address: address, entry: entry, now: blockTime}
+			for _, r := range rs {
+				if r.Address != "" && r.Address != address {
+					continue
+				}
+				if r.expr.Eval(env) {
+					bp.raiseRuleAlert(r, address, entry)
+				}
+			}
+		}
+	}
+}
+
+// recordActivity remembers the entry of the address and its parties and forgets activity older than ruleWindow.
+// Caller must hold bp.mu.
+func (bp *BlockParser) recordActivity(address string, entry ruleEntry, now time.Time) {
+	bp.recordCounterparties(address, entry)
+	if bp.activity == nil {
+		bp.activity = make(map[string][]activity)
+	}
+	var kept []activity
+	for _, a := range bp.activity[address] {
+		if a.key == entry.key {
+			// included again after a reorg
+			continue
+		}
+		if now.Sub(a.time) <= ruleWindow {
+			kept = append(kept, a)
+		}
+	}
+	bp.activity[address] = append(kept, activity{
+		key:      entry.key,
+		txHash:   entry.txHash(),
+		time:     now,
+		value:    hexToBig(entry.tx.Value),
+		outbound: entry.tx.From == address,
+	})
+}
+
+// recordCounterparties remembers the parties of the entry of the address, including those of token and NFT
+// transfers, with the earliest block each was seen in. Caller must hold bp.mu.
+func (bp *BlockParser) recordCounterparties(address string, entry ruleEntry) {
+	if bp.counterparties == nil {
+		bp.counterparties = make(map[string]map[string]int)
+	}
+	parties := bp.counterparties[address]
+	if parties == nil {
+		parties = make(map[string]int)
+		bp.counterparties[address] = parties
+	}
+	for _, party := range []string{entry.tx.From, entry.tx.To, entry.tx.ContractAddress} {
+		if party == "" {
+			continue
+		}
+		if first, seen := parties[party]; !seen || entry.tx.BlockNumber < first {
+			parties[party] = entry.tx.BlockNumber
+		}
+	}
+}
+
+// raiseRuleAlert raises alert of the rule for the address. Caller must hold bp.mu.
+func (bp *BlockParser) raiseRuleAlert(r Rule, address string, entry ruleEntry) {
+	bp.raiseAlert(Alert{
+		Type:        AlertRule,
+		Priority:    r.Priority,
+		RuleID:      r.ID,
+		Address:     address,
+		TxHash:      entry.txHash(),
+		BlockNumber: entry.tx.BlockNumber,
+		Message:     fmt.Sprintf("rule %q matched: %s", r.Name, r.Expression),
+	}, fmt.Sprintf("%s/%d/%s/%s", AlertRule, r.ID, address, entry.key))
+}
+
+// ruleEnv provides fields of the entry and aggregates of the subscribed address to rule expressions.
+// bp.mu must be held while rules are evaluated.
+type ruleEnv struct {
+	bp      *BlockParser
+	address string
+	entry   ruleEntry
+	now     time.Time
+}
+
+func (e ruleEnv) Field(name string) rules.Value {
+	tx := e.entry.tx
+	switch name {
+	case "value":
+		return rules.IntValue(hexToBig(tx.Value))
+	case "gasUsed":
+		return rules.IntValue(hexToBig(tx.GasUsed))
+	case "blockNumber":
+		return rules.Int64Value(int64(tx.BlockNumber))
+	case "from":
+		return rules.StringValue(tx.From)
+	case "to":
+		return rules.StringValue(tx.To)
+	case "address":
+		return rules.StringValue(e.address)
+	case "counterparty":
+		return rules.StringValue(e.counterparty())
+	case "kind":
+		return rules.StringValue(tx.Kind)
+	case "token":
+		return rules.StringValue(e.entry.token)
+	case "tokenAmount":
+		if e.entry.tokenAmount == nil {
+			return rules.Int64Value(0)
+		}
+		return rules.IntValue(e.entry.tokenAmount)
+	case "status":
+		return rules.StringValue(tx.Status)
+	case "method":
+		if tx.Call != nil {
+			return rules.StringValue(tx.Call.Method)
+		}
+		return rules.StringValue("")
+	case "outbound":
+		return rules.BoolValue(tx.From == e.address)
+	case "inbound":
+		return rules.BoolValue(tx.To == e.address || tx.ContractAddress == e.address)
+	case "failed":
+		return rules.BoolValue(tx.Status == "0x0")
+	case "contractCreation":
+		return rules.BoolValue(tx.ContractCreation)
+	case "firstCounterparty":
+		return rules.BoolValue(e.firstCounterparty())
+	case "denylisted":
+		return rules.BoolValue(len(tx.DenylistHits) > 0)
+	}
+	return rules.Value{}
+}
+
+func (e ruleEnv) Call(name string, window time.Duration) rules.Value {
+	txs, outbound, inbound := make(map[string]bool), new(big.Int), new(big.Int)
+	for _, a := range e.bp.activity[e.address] {
+		if e.now.Sub(a.time) > window {
+			continue
+		}
+		// internal and token transfers count with their transaction
+		if a.txHash != "" {
+			txs[a.txHash] = true
+		}
+		if a.outbound {
+			outbound.Add(outbound, a.value)
+		} else {
+			inbound.Add(inbound, a.value)
+		}
+	}
+	switch name {
+	case "outboundValue":
+		return rules.IntValue(outbound)
+	case "inboundValue":
+		return rules.IntValue(inbound)
+	}
+	return rules.Int64Value(int64(len(txs)))
+}
+
+// counterparty returns the other party of the entry, the deployed contract of contract creations.
+func (e ruleEnv) counterparty() string {
+	tx := e.entry.tx
+	if tx.From != e.address {
+		return tx.From
+	}
+	if tx.ContractCreation {
+		return tx.ContractAddress
+	}
+	return tx.To
+}
+
+// firstCounterparty reports whether no transaction, token or NFT transfer of the address from an earlier block
+// involves the counterparty.
+func (e ruleEnv) firstCounterparty() bool {
+	counterparty := e.counterparty()
+	if counterparty == "" || counterparty == zeroAddress {
+		return false
+	}
+	first, seen := e.bp.counterparties[e.address][counterparty]
+	return !seen || first >= e.entry.tx.BlockNumber
+}
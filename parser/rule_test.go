@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// ruleBlock stores and queues the transactions of the subscribed wallet and returns the block including them.
+func ruleBlock(bp *BlockParser, number int, timestamp int64, txs ...Transaction) map[string]interface{} {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	for _, tx := range txs {
+		tx.BlockNumber = number
+		bp.store.StoreTransactions(callWallet, tx)
+		bp.queueTransaction(tx)
+	}
+	return map[string]interface{}{"number": fmt.Sprintf("0x%x", number), "timestamp": fmt.Sprintf("0x%x", timestamp)}
+}
+
+func TestBlockParser_AddRule(t *testing.T) {
+	logging.Init("debug")
+
+	bp := &BlockParser{store: NewTransactionStorage()}
+	r, err := bp.AddRule(Rule{Expression: " outbound && value > 10 ether ", Address: treasury})
+	if err != nil {
+		t.Fatal("Failed adding rule", err.Error())
+	}
+	if r.ID != 1 || r.Name != "outbound && value > 10 ether" || r.Priority != PriorityNormal || r.Address != "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed" {
+		t.Errorf("unexpected rule %+v", r)
+	}
+
+	for _, invalid := range []Rule{
+		{Expression: "value > "},
+		{Expression: "outbound", Priority: "urgent"},
+		{Expression: "outbound", Address: "0x12"},
+	} {
+		if _, err := bp.AddRule(invalid); err == nil {
+			t.Errorf("invalid rule %+v accepted", invalid)
+		}
+	}
+
+	if _, err := bp.UpdateRule(2, Rule{Expression: "inbound"}); err == nil {
+		t.Error("unknown rule updated")
+	}
+	if r, err := bp.UpdateRule(1, Rule{Name: "incoming", Expression: "inbound"}); err != nil || r.ID != 1 || r.Name != "incoming" {
+		t.Errorf("UpdateRule() = %+v, %v", r, err)
+	}
+	if !bp.RemoveRule(1) || bp.RemoveRule(1) || len(bp.GetRules()) != 0 {
+		t.Error("unexpected result of removing rule")
+	}
+}
+
+func TestBlockParser_evaluateRules(t *testing.T) {
+	logging.Init("debug")
+
+	bp := &BlockParser{store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+	large, _ := bp.AddRule(Rule{Name: "large outbound", Expression: "outbound && value > 10 ether", Priority: PriorityHigh})
+	first, _ := bp.AddRule(Rule{Expression: "firstCounterparty"})
+	burst, _ := bp.AddRule(Rule{Expression: "txCount(10m) > 2"})
+	other, _ := bp.AddRule(Rule{Expression: "outbound", Address: treasury})
+
+	const start = 1700000000
+	bp.evaluateRules(ruleBlock(bp, 1, start,
+		Transaction{Hash: "0xaa", From: callWallet, To: callToken, Value: "0x9c2007651b2500000", Kind: KindTransaction}, // 180 ETH
+		Transaction{Hash: "0xbb", From: lifecycleRecipient, To: callWallet, Value: "0x1", Kind: KindTransaction},
+	))
+	// counterparty seen before, third transaction is more than 10 minutes later
+	bp.evaluateRules(ruleBlock(bp, 2, start+11*60,
+		Transaction{Hash: "0xcc", From: callWallet, To: callToken, Value: "0x1", Kind: KindTransaction},
+	))
+	bp.evaluateRules(ruleBlock(bp, 3, start+12*60,
+		Transaction{Hash: "0xdd", From: callWallet, To: callToken, Value: "0x1", Kind: KindTransaction},
+		Transaction{Hash: "0xee", From: callWallet, To: callToken, Value: "0x1", Kind: KindTransaction},
+	))
+
+	if alerts := bp.GetRuleAlerts(large.ID); len(alerts) != 1 || alerts[0].TxHash != "0xaa" || alerts[0].Priority != PriorityHigh || alerts[0].Address != callWallet {
+		t.Errorf("unexpected alerts of %s: %+v", large.Name, alerts)
+	}
+	if alerts := bp.GetRuleAlerts(first.ID); len(alerts) != 2 || alerts[0].TxHash != "0xaa" || alerts[1].TxHash != "0xbb" {
+		t.Errorf("unexpected alerts of %s: %+v", first.Name, alerts)
+	}
+	if alerts := bp.GetRuleAlerts(burst.ID); len(alerts) != 1 || alerts[0].TxHash != "0xee" {
+		t.Errorf("unexpected alerts of %s: %+v", burst.Name, alerts)
+	}
+	if alerts := bp.GetRuleAlerts(other.ID); len(alerts) != 0 {
+		t.Errorf("rule of other address raised %+v", alerts)
+	}
+
+	// block included again after a reorg raises nothing new
+	before := len(bp.GetAlerts(""))
+	bp.evaluateRules(ruleBlock(bp, 4, start+12*60, Transaction{Hash: "0xee", From: callWallet, To: callToken, Value: "0x1", Kind: KindTransaction}))
+	if len(bp.GetAlerts("")) != before {
+		t.Error("alert raised twice for the same transaction")
+	}
+}
+
+func TestBlockParser_evaluateRules_InternalTransfer(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{"trace_block": traceBlockResult})
+	bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithTraceMode(TraceModeParity)
+	bp.Subscribe(traceWallet)
+	r, _ := bp.AddRule(Rule{Expression: "kind == 'internal' && inbound && value >= 1 ether"})
+
+	block := map[string]interface{}{"number": "0x14386af", "timestamp": "0x6738fe6f"}
+	if err := bp.processBlockTraces(block); err != nil {
+		t.Fatal("Failed processing block traces", err.Error())
+	}
+	bp.evaluateRules(block)
+
+	alerts := bp.GetRuleAlerts(r.ID)
+	if len(alerts) != 1 || alerts[0].Address != traceWallet || alerts[0].TxHash != "0xaa" || alerts[0].BlockNumber != 0x14386af {
+		t.Errorf("unexpected alerts %+v", alerts)
+	}
+	if len(bp.ruleEntries) != 0 {
+		t.Errorf("evaluated entries are still queued: %+v", bp.ruleEntries)
+	}
+}
+
+func TestBlockParser_evaluateRules_TokenTransfer(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{"eth_getLogs": nftLogs})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+	bp.Subscribe(nftOwner)
+	nft, _ := bp.AddRule(Rule{Expression: "kind == 'nft-transfer' && outbound && token == '" + nftContract + "'"})
+	erc20, _ := bp.AddRule(Rule{Expression: "kind == 'token-transfer' && tokenAmount >= 1000"})
+
+	block := map[string]interface{}{"number": "0x14386af", "timestamp": "0x6738fe6f"}
+	if err := bp.processBlockLogs(block); err != nil {
+		t.Fatal("Failed processing block logs", err.Error())
+	}
+	bp.evaluateRules(block)
+
+	// ERC-721 transfer and each token moved by the batch
+	if alerts := bp.GetRuleAlerts(nft.ID); len(alerts) != 3 || alerts[0].TxHash != "0x01" || alerts[2].TxHash != "0x04" {
+		t.Errorf("unexpected NFT transfer alerts %+v", alerts)
+	}
+	if alerts := bp.GetRuleAlerts(erc20.ID); len(alerts) != 1 || alerts[0].TxHash != "0x02" {
+		t.Errorf("unexpected token transfer alerts %+v", alerts)
+	}
+}
+
+func TestBlockParser_evaluateRules_FirstCounterpartyOfTokenTransfer(t *testing.T) {
+	logging.Init("debug")
+
+	bp := &BlockParser{store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+	first, _ := bp.AddRule(Rule{Expression: "firstCounterparty"})
+
+	const start = 1700000000
+	bp.mu.Lock()
+	bp.queueRuleEntry(ruleEntry{
+		key:         "0xaa/0x0",
+		tx:          Transaction{Hash: "0xaa", From: lifecycleRecipient, To: callWallet, BlockNumber: 1, Kind: KindTokenTransfer},
+		token:       callToken,
+		tokenAmount: big.NewInt(1000),
+	})
+	bp.mu.Unlock()
+	bp.evaluateRules(map[string]interface{}{"number": "0x1", "timestamp": fmt.Sprintf("0x%x", start)})
+	// the sender of the earlier token transfer is not a new counterparty
+	bp.evaluateRules(ruleBlock(bp, 2, start+12,
+		Transaction{Hash: "0xbb", From: callWallet, To: lifecycleRecipient, Value: "0x1", Kind: KindTransaction},
+		Transaction{Hash: "0xcc", From: callWallet, To: callToken, Value: "0x1", Kind: KindTransaction},
+	))
+
+	if alerts := bp.GetRuleAlerts(first.ID); len(alerts) != 2 || alerts[0].TxHash != "0xaa" || alerts[1].TxHash != "0xcc" {
+		t.Errorf("unexpected alerts of %s: %+v", first.Name, alerts)
+	}
+}
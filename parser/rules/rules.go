@@ -0,0 +1,464 @@
+// Package rules implements the small expression language of alert rules, e.g.
+//
+//	outbound && value > 10 ether
+//	firstCounterparty || txCount(10m) > 5
+//
+// Expressions combine comparisons of fields and aggregate functions with `&&`, `||`, `!` and parentheses.
+// Numbers may carry a unit (`wei`, `gwei`, `ether`), function arguments are durations such as `10m`.
+// Strings are quoted with `'` or `"` and compared case insensitively.
+package rules
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Kind is the type of a value
+type Kind int
+
+const (
+	Bool Kind = iota
+	Number
+	String
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Bool:
+		return "bool"
+	case Number:
+		return "number"
+	}
+	return "string"
+}
+
+// units multiply numbers given with them, amounts are in Wei
+var units = map[string]*big.Rat{
+	"wei":   big.NewRat(1, 1),
+	"gwei":  new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(9), nil)),
+	"ether": new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)),
+	"eth":   new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)),
+}
+
+// Value is a bool, number or string value
+type Value struct {
+	Kind Kind
+	Bool bool
+	Num  *big.Rat
+	Str  string
+}
+
+func BoolValue(b bool) Value       { return Value{Kind: Bool, Bool: b} }
+func StringValue(s string) Value   { return Value{Kind: String, Str: s} }
+func NumberValue(n *big.Rat) Value { return Value{Kind: Number, Num: n} }
+func IntValue(n *big.Int) Value    { return NumberValue(new(big.Rat).SetInt(n)) }
+func Int64Value(n int64) Value     { return NumberValue(big.NewRat(n, 1)) }
+
+// Schema declares fields and functions available to expressions. Functions take a single duration argument.
+type Schema struct {
+	Fields    map[string]Kind
+	Functions map[string]Kind
+	MaxWindow time.Duration // longest accepted function argument, unlimited when zero
+}
+
+// Env provides values of fields and results of functions declared by the schema
+type Env interface {
+	Field(name string) Value
+	Call(name string, window time.Duration) Value
+}
+
+// Expr is a parsed boolean expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parses and type checks the boolean expression against the schema.
+func Parse(src string, schema Schema) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, schema: schema}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	if root.kind() != Bool {
+		return nil, fmt.Errorf("expression is %s, not bool", root.kind())
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Eval evaluates the expression in the environment.
+func (e *Expr) Eval(env Env) bool {
+	return e.root.eval(env).Bool
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber // digits with optional unit or duration suffix, e.g. 10, 1.5ether, 10m
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"&&", "||", "==", "!=", ">=", "<=", ">", "<", "!", "(", ")"}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isLetter(c):
+			start := i
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, src[start:i], start})
+		case isDigit(c):
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{tokString, src[i+1 : i+1+end], i})
+			i += end + 2
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", string(c), i)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "end of expression", len(src)}), nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Parser
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | comparison
+//	comparison = operand [ ( "==" | "!=" | ">" | ">=" | "<" | "<=" ) operand ]
+//	operand    = "(" or ")" | ident [ "(" duration ")" ] | number [ unit ] | string | "true" | "false"
+
+type parser struct {
+	tokens []token
+	pos    int
+	schema Schema
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(op string) error {
+	if t := p.next(); t.kind != tokOp || t.text != op {
+		return fmt.Errorf("expected %q at %d, got %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && t.text == "||"; t = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = newLogical("||", left, right, t.pos); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && t.text == "&&"; t = p.peek() {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = newLogical("&&", left, right, t.pos); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if t := p.peek(); t.kind == tokOp && t.text == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.kind() != Bool {
+			return nil, fmt.Errorf("operand of ! at %d is %s, not bool", t.pos, operand.kind())
+		}
+		return notNode{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch t.text {
+	case "==", "!=", ">", ">=", "<", "<=":
+		if t.kind != tokOp {
+			return left, nil
+		}
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if left.kind() != right.kind() {
+		return nil, fmt.Errorf("cannot compare %s with %s at %d", left.kind(), right.kind(), t.pos)
+	}
+	if left.kind() != Number && t.text != "==" && t.text != "!=" {
+		return nil, fmt.Errorf("operator %s at %d requires numbers", t.text, t.pos)
+	}
+	return compareNode{op: t.text, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokOp:
+		if t.text != "(" {
+			break
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case tokString:
+		return literal{StringValue(t.text)}, nil
+	case tokNumber:
+		return p.parseNumber(t)
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal{BoolValue(true)}, nil
+		case "false":
+			return literal{BoolValue(false)}, nil
+		}
+		if next := p.peek(); next.kind == tokOp && next.text == "(" {
+			return p.parseCall(t)
+		}
+		kind, ok := p.schema.Fields[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q at %d", t.text, t.pos)
+		}
+		return field{name: t.text, k: kind}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) parseNumber(t token) (node, error) {
+	digits := strings.TrimRightFunc(t.text, func(r rune) bool { return r > '9' })
+	unit := t.text[len(digits):]
+	if unit == "" {
+		// unit separated by space, e.g. `10 ether`
+		if next := p.peek(); next.kind == tokIdent && units[strings.ToLower(next.text)] != nil {
+			unit = p.next().text
+		}
+	}
+	n, ok := new(big.Rat).SetString(digits)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q at %d", t.text, t.pos)
+	}
+	if unit != "" {
+		scale := units[strings.ToLower(unit)]
+		if scale == nil {
+			return nil, fmt.Errorf("unknown unit %q at %d", unit, t.pos)
+		}
+		n.Mul(n, scale)
+	}
+	return literal{NumberValue(n)}, nil
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	kind, ok := p.schema.Functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+	p.next() // (
+	arg := p.next()
+	window, err := time.ParseDuration(arg.text)
+	if arg.kind != tokNumber || err != nil || window <= 0 {
+		return nil, fmt.Errorf("%s at %d expects a duration such as 10m, got %q", name.text, arg.pos, arg.text)
+	}
+	if p.schema.MaxWindow > 0 && window > p.schema.MaxWindow {
+		return nil, fmt.Errorf("window of %s at %d exceeds %s", name.text, arg.pos, p.schema.MaxWindow)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return call{name: name.text, window: window, k: kind}, nil
+}
+
+// Nodes
+
+type node interface {
+	kind() Kind
+	eval(env Env) Value
+}
+
+type literal struct{ v Value }
+
+func (n literal) kind() Kind         { return n.v.Kind }
+func (n literal) eval(env Env) Value { return n.v }
+
+type field struct {
+	name string
+	k    Kind
+}
+
+func (n field) kind() Kind         { return n.k }
+func (n field) eval(env Env) Value { return env.Field(n.name) }
+
+type call struct {
+	name   string
+	window time.Duration
+	k      Kind
+}
+
+func (n call) kind() Kind         { return n.k }
+func (n call) eval(env Env) Value { return env.Call(n.name, n.window) }
+
+type notNode struct{ operand node }
+
+func (n notNode) kind() Kind         { return Bool }
+func (n notNode) eval(env Env) Value { return BoolValue(!n.operand.eval(env).Bool) }
+
+type logical struct {
+	op          string
+	left, right node
+}
+
+func newLogical(op string, left, right node, pos int) (node, error) {
+	if left.kind() != Bool || right.kind() != Bool {
+		return nil, fmt.Errorf("operands of %s at %d must be bool", op, pos)
+	}
+	return logical{op: op, left: left, right: right}, nil
+}
+
+func (n logical) kind() Kind { return Bool }
+func (n logical) eval(env Env) Value {
+	left := n.left.eval(env).Bool
+	if n.op == "&&" {
+		return BoolValue(left && n.right.eval(env).Bool)
+	}
+	return BoolValue(left || n.right.eval(env).Bool)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) kind() Kind { return Bool }
+func (n compareNode) eval(env Env) Value {
+	left, right := n.left.eval(env), n.right.eval(env)
+	var cmp int
+	switch left.Kind {
+	case Number:
+		if left.Num == nil || right.Num == nil {
+			return BoolValue(false)
+		}
+		cmp = left.Num.Cmp(right.Num)
+	case String:
+		if !strings.EqualFold(left.Str, right.Str) {
+			cmp = 1
+		}
+	case Bool:
+		if left.Bool != right.Bool {
+			cmp = 1
+		}
+	}
+	switch n.op {
+	case "==":
+		return BoolValue(cmp == 0)
+	case "!=":
+		return BoolValue(cmp != 0)
+	case ">":
+		return BoolValue(cmp > 0)
+	case ">=":
+		return BoolValue(cmp >= 0)
+	case "<":
+		return BoolValue(cmp < 0)
+	}
+	return BoolValue(cmp <= 0)
+}
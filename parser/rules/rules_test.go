@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+var schema = Schema{
+	Fields:    map[string]Kind{"value": Number, "to": String, "outbound": Bool},
+	Functions: map[string]Kind{"txCount": Number},
+	MaxWindow: 24 * time.Hour,
+}
+
+type env struct {
+	value    *big.Int
+	to       string
+	outbound bool
+	txCount  map[time.Duration]int64
+}
+
+func (e env) Field(name string) Value {
+	switch name {
+	case "value":
+		return IntValue(e.value)
+	case "to":
+		return StringValue(e.to)
+	}
+	return BoolValue(e.outbound)
+}
+
+func (e env) Call(name string, window time.Duration) Value {
+	return Int64Value(e.txCount[window])
+}
+
+func TestParse_Eval(t *testing.T) {
+	ether, _ := new(big.Int).SetString("11000000000000000000", 10)
+	e := env{
+		value:    ether,
+		to:       "0xdac17f958d2ee523a2206206994597c13d831ec7",
+		outbound: true,
+		txCount:  map[time.Duration]int64{10 * time.Minute: 6},
+	}
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"outbound", true},
+		{"!outbound", false},
+		{"value > 10 ether", true},
+		{"value > 11ether", false},
+		{"value >= 11 ether && outbound", true},
+		{"value < 1.5 gwei || txCount(10m) > 5", true},
+		{"txCount(10m) > 6", false},
+		{"txCount(1h) == 0", true},
+		{"to == '0xDAC17F958D2ee523a2206206994597C13D831ec7'", true},
+		{`to != "0xdac17f958d2ee523a2206206994597c13d831ec7"`, false},
+		{"!(outbound && value > 10 ether) || false", false},
+		{"outbound == true", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := Parse(tt.src, schema)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := expr.Eval(e); got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"value",
+		"value > ",
+		"value > 10 lightyears",
+		"value > 'a'",
+		"to > 'a'",
+		"outbound && value",
+		"!value",
+		"balance > 1",
+		"txCount(10) > 5",
+		"txCount(48h) > 5",
+		"unknown(10m) > 5",
+		"(outbound",
+		"outbound outbound",
+		"to == 'a",
+		"value # 1",
+	} {
+		if _, err := Parse(src, schema); err == nil {
+			t.Errorf("Parse(%q) accepted invalid expression", src)
+		}
+	}
+}
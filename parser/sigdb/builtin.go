@@ -0,0 +1,101 @@
+package sigdb
+
+// builtinFunctions are commonly used function signatures
+var builtinFunctions = []string{
+	// ERC-20
+	"transfer(address,uint256)",
+	"transferFrom(address,address,uint256)",
+	"approve(address,uint256)",
+	"increaseAllowance(address,uint256)",
+	"decreaseAllowance(address,uint256)",
+	"permit(address,address,uint256,uint256,uint8,bytes32,bytes32)",
+	"balanceOf(address)",
+	"allowance(address,address)",
+	"totalSupply()",
+	"name()",
+	"symbol()",
+	"decimals()",
+	// ERC-721 / ERC-1155
+	"safeTransferFrom(address,address,uint256)",
+	"safeTransferFrom(address,address,uint256,bytes)",
+	"setApprovalForAll(address,bool)",
+	"safeTransferFrom(address,address,uint256,uint256,bytes)",
+	"safeBatchTransferFrom(address,address,uint256[],uint256[],bytes)",
+	"mint(address,uint256)",
+	"burn(uint256)",
+	// WETH
+	"deposit()",
+	"withdraw(uint256)",
+	// ownership
+	"transferOwnership(address)",
+	"renounceOwnership()",
+	// Uniswap V2 router
+	"swapExactETHForTokens(uint256,address[],address,uint256)",
+	"swapExactTokensForETH(uint256,uint256,address[],address,uint256)",
+	"swapExactTokensForTokens(uint256,uint256,address[],address,uint256)",
+	"swapETHForExactTokens(uint256,address[],address,uint256)",
+	"swapTokensForExactTokens(uint256,uint256,address[],address,uint256)",
+	"swapTokensForExactETH(uint256,uint256,address[],address,uint256)",
+	"swapExactTokensForTokensSupportingFeeOnTransferTokens(uint256,uint256,address[],address,uint256)",
+	"swapExactETHForTokensSupportingFeeOnTransferTokens(uint256,address[],address,uint256)",
+	"swapExactTokensForETHSupportingFeeOnTransferTokens(uint256,uint256,address[],address,uint256)",
+	"addLiquidity(address,address,uint256,uint256,uint256,uint256,address,uint256)",
+	"addLiquidityETH(address,uint256,uint256,uint256,address,uint256)",
+	"removeLiquidity(address,address,uint256,uint256,uint256,address,uint256)",
+	"removeLiquidityETH(address,uint256,uint256,uint256,address,uint256)",
+	// Uniswap V3 router
+	"exactInputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))",
+	"exactInput((bytes,address,uint256,uint256,uint256))",
+	"exactOutputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))",
+	"exactOutput((bytes,address,uint256,uint256,uint256))",
+	"exactInputSingle((address,address,uint24,address,uint256,uint256,uint160))",
+	"exactInput((bytes,address,uint256,uint256))",
+	// Uniswap universal router
+	"execute(bytes,bytes[],uint256)",
+	"execute(bytes,bytes[])",
+	// multicall
+	"multicall(bytes[])",
+	"multicall(uint256,bytes[])",
+	"aggregate((address,bytes)[])",
+	"aggregate3((address,bool,bytes)[])",
+	// Gnosis Safe
+	"execTransaction(address,uint256,bytes,uint8,uint256,uint256,uint256,address,address,bytes)",
+	// ERC-4337 EntryPoint
+	"handleOps((address,uint256,bytes,bytes,uint256,uint256,uint256,uint256,uint256,bytes,bytes)[],address)",
+	"handleOps((address,uint256,bytes,bytes,bytes32,uint256,bytes32,bytes,bytes)[],address)",
+}
+
+// builtinEvents are commonly used event signatures
+var builtinEvents = []string{
+	// ERC-20 / ERC-721
+	"Transfer(address,address,uint256)",
+	"Approval(address,address,uint256)",
+	"ApprovalForAll(address,address,bool)",
+	// ERC-1155
+	"TransferSingle(address,address,address,uint256,uint256)",
+	"TransferBatch(address,address,address,uint256[],uint256[])",
+	"URI(string,uint256)",
+	// WETH
+	"Deposit(address,uint256)",
+	"Withdrawal(address,uint256)",
+	// ownership
+	"OwnershipTransferred(address,address)",
+	// Uniswap V2
+	"Swap(address,uint256,uint256,uint256,uint256,address)",
+	"Sync(uint112,uint112)",
+	"Mint(address,uint256,uint256)",
+	"Burn(address,uint256,uint256,address)",
+	"PairCreated(address,address,address,uint256)",
+	// Uniswap V3
+	"Swap(address,address,int256,int256,uint160,uint128,int24)",
+	"Mint(address,address,int24,int24,uint128,uint256,uint256)",
+	"Burn(address,int24,int24,uint128,uint256,uint256)",
+	"PoolCreated(address,address,uint24,int24,address)",
+	// ERC-4337 EntryPoint
+	"UserOperationEvent(bytes32,address,address,uint256,bool,uint256,uint256)",
+	"AccountDeployed(bytes32,address,address,address)",
+	"BeforeExecution()",
+	// proxies
+	"Upgraded(address)",
+	"AdminChanged(address,address)",
+}
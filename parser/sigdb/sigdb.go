@@ -0,0 +1,132 @@
+// Package sigdb is an offline registry of function selectors and event topics.
+//
+// It is used to label calldata and logs of contracts without known ABI.
+package sigdb
+
+import (
+	"bufio"
+	"ethTx/parser/abi"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Registry maps 4 byte selectors and event topics to their signatures
+type Registry struct {
+	mu        sync.RWMutex
+	functions map[string][]string // selector -> signatures
+	events    map[string][]string // topic -> signatures
+}
+
+// New creates a registry populated with the built-in signatures.
+func New() *Registry {
+	r := &Registry{
+		functions: make(map[string][]string),
+		events:    make(map[string][]string),
+	}
+	for _, sig := range builtinFunctions {
+		if err := r.AddFunction(sig); err != nil {
+			panic("invalid built-in function signature: " + err.Error())
+		}
+	}
+	for _, sig := range builtinEvents {
+		if err := r.AddEvent(sig); err != nil {
+			panic("invalid built-in event signature: " + err.Error())
+		}
+	}
+	return r
+}
+
+// AddFunction registers function signature, e.g. transfer(address,uint256).
+func (r *Registry) AddFunction(signature string) error {
+	m, err := abi.ParseSignature(strings.TrimSpace(signature))
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.functions[m.Selector] = appendUnique(r.functions[m.Selector], m.Signature)
+	return nil
+}
+
+// AddEvent registers event signature, e.g. Transfer(address,address,uint256).
+func (r *Registry) AddEvent(signature string) error {
+	ev, err := abi.ParseEventSignature(strings.TrimSpace(signature), 0)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[ev.Topic] = appendUnique(r.events[ev.Topic], ev.Signature)
+	return nil
+}
+
+// Functions returns signatures matching 0x prefixed 4 byte selector.
+//
+// Several signatures may share a selector.
+func (r *Registry) Functions(selector string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.functions[strings.ToLower(selector)]
+}
+
+// Events returns signatures matching 0x prefixed event topic.
+func (r *Registry) Events(topic string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.events[strings.ToLower(topic)]
+}
+
+// Import reads signatures, one per line. Lines starting with `event ` are event
+// signatures, optional `function ` prefix marks function signatures.
+// Empty lines and lines starting with `#` are skipped.
+func (r *Registry) Import(reader io.Reader) (int, error) {
+	scanner := bufio.NewScanner(reader)
+	count := 0
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var err error
+		if sig, isEvent := strings.CutPrefix(line, "event "); isEvent {
+			err = r.AddEvent(sig)
+		} else {
+			err = r.AddFunction(strings.TrimPrefix(line, "function "))
+		}
+		if err != nil {
+			return count, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		count++
+	}
+	return count, scanner.Err()
+}
+
+// ImportFile imports signatures from a file, see Import.
+func (r *Registry) ImportFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	count, err := r.Import(f)
+	if err != nil {
+		return count, fmt.Errorf("%s: %w", path, err)
+	}
+	return count, nil
+}
+
+func appendUnique(signatures []string, signature string) []string {
+	for _, s := range signatures {
+		if s == signature {
+			return signatures
+		}
+	}
+	return append(signatures, signature)
+}
@@ -0,0 +1,71 @@
+package sigdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	r := New()
+
+	if got := r.Functions("0xa9059cbb"); len(got) != 1 || got[0] != "transfer(address,uint256)" {
+		t.Errorf("Functions(0xa9059cbb) = %v", got)
+	}
+	if got := r.Functions("0xA9059CBB"); len(got) != 1 {
+		t.Errorf("selector lookup should be case insensitive, got %v", got)
+	}
+	if got := r.Events("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"); len(got) != 1 || got[0] != "Transfer(address,address,uint256)" {
+		t.Errorf("Events(Transfer) = %v", got)
+	}
+	if got := r.Functions("0x12345678"); got != nil {
+		t.Errorf("unknown selector returned %v", got)
+	}
+}
+
+func TestRegistry_Import(t *testing.T) {
+	r := New()
+
+	input := `# custom contracts
+function claim(uint256)
+payout(address, uint)
+
+event Claimed(address,uint256)
+transfer(address,uint256)
+`
+	count, err := r.Import(strings.NewReader(input))
+	if err != nil || count != 4 {
+		t.Fatalf("Import() = %d, %v; want 4, nil", count, err)
+	}
+	if got := r.Functions("0x379607f5"); len(got) != 1 || got[0] != "claim(uint256)" {
+		t.Errorf("claim(uint256) not imported, got %v", got)
+	}
+	// canonical form is stored
+	m := r.Functions("0x117de2fd")
+	if len(m) != 1 || m[0] != "payout(address,uint256)" {
+		t.Errorf("payout(address,uint256) not imported, got %v", m)
+	}
+	// duplicates are ignored
+	if got := r.Functions("0xa9059cbb"); len(got) != 1 {
+		t.Errorf("duplicate signature imported, got %v", got)
+	}
+
+	_, err = r.Import(strings.NewReader("claim(uint256)\nnot a signature\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("invalid signature should fail with line number, got %v", err)
+	}
+}
+
+func TestRegistry_ImportFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.txt")
+	os.WriteFile(path, []byte("event Claimed(address,uint256)\n"), 0o644)
+
+	r := New()
+	if count, err := r.ImportFile(path); err != nil || count != 1 {
+		t.Errorf("ImportFile() = %d, %v; want 1, nil", count, err)
+	}
+	if _, err := r.ImportFile(path + ".missing"); err == nil {
+		t.Error("missing file should fail")
+	}
+}
@@ -1,17 +1,169 @@
 package parser
 
-import "fmt"
+import (
+	"ethTx/parser/abi"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
 
 type Storage interface {
 	StoreAddress(address string) error
+	RemoveAddress(address string)
 	StoreTransactions(address string, tx Transaction)
 	Transactions(address string) []Transaction
+	Transaction(hash string) (Transaction, bool)
 	IsObserved(address string) bool
+	ObservedAddresses() []string
+
+	StorePendingTransaction(address string, tx Transaction)
+	PendingTransactions(address string) []Transaction
+	PendingTransaction(hash string) (Transaction, bool)
+	RemovePendingTransaction(hash string)
+
+	UpdateTransaction(tx Transaction)
+	TransactionsInState(states ...string) []Transaction
+	TransactionsByNonce(from, nonce string) []Transaction
+
+	StoreAlert(a Alert)
+	Alerts() []Alert
+	IsAlertRaised(key string) bool
+
+	StoreBalance(b Balance)
+	Balance(address string) (Balance, bool)
+	StoreTokenBalance(address string, b TokenBalance)
+	TokenBalance(address, contract string) (TokenBalance, bool)
+	TokenBalances(address string) []TokenBalance
+	StoreTokenMetadata(contract string, m TokenMetadata)
+	TokenMetadata(contract string) (TokenMetadata, bool)
+	AllTokenMetadata() map[string]TokenMetadata
+
+	StoreAllowance(owner string, a Allowance)
+	Allowance(owner, contract, spender string) (Allowance, bool)
+	RemoveAllowance(owner, contract, spender string)
+	Allowances(owner string) []Allowance
+
+	StoreAddressInfo(info AddressInfo)
+	AddressInfo(address string) (AddressInfo, bool)
+	RemoveAddressInfo(address string)
+	AddressInfoCount() int
+
+	StoreReverseName(r ENSRecord)
+	ReverseName(address string) (ENSRecord, bool)
+	ReverseNames() []ENSRecord
+	RemoveReverseName(address string)
+	StoreSubscribedName(r ENSRecord)
+	SubscribedNames() []ENSRecord
+	StoreNameAddress(address string)
+	IsNameAddress(address string) bool
+	RemoveNameAddress(address string)
+
+	StoreLabel(l Label)
+	Label(address string) (Label, bool)
+	RemoveLabel(address string)
+	Labels() []Label
+
+	StoreRule(r Rule)
+	Rule(id int) (Rule, bool)
+	RemoveRule(id int)
+	Rules() []Rule
+
+	StoreUserOperation(address string, op UserOperation)
+	UserOperations(address string) []UserOperation
+
+	StorePoolTokens(pool string, tokens [2]string)
+	PoolTokens(pool string) ([2]string, bool)
+
+	StoreNFTTransfer(address string, t NFTTransfer)
+	NFTTransfers(address string) []NFTTransfer
+	NFTHoldings(address string) []NFTHolding
+
+	StoreFeeRecipientBlock(address string, b FeeRecipientBlock)
+	FeeRecipientBlocks(address string) []FeeRecipientBlock
+
+	RemoveBlock(blockNumber int)
+
+	StoreABI(address string, a *abi.ABI)
+	ABI(address string) *abi.ABI
+	ABIAddresses() []string
 }
 
 type TransactionStorage struct {
 	observedAddrs map[string]struct{}
 	transactions  map[string][]Transaction
+	txByHash      map[string]Transaction
+
+	pending       map[string][]Transaction
+	pendingByHash map[string]Transaction
+	byNonce       map[string][]string // hashes of top-level transactions by sender and nonce
+
+	alerts    []Alert
+	alertKeys map[string]struct{} // keys of raised alerts
+
+	balances      map[string]Balance
+	tokenBalances map[string]map[string]TokenBalance
+	tokens        map[string]TokenMetadata
+
+	allowances map[string]map[string]Allowance // by owner, contract/spender
+
+	poolTokens map[string][2]string
+
+	userOperations map[string][]UserOperation
+
+	addressInfo map[string]AddressInfo
+
+	reverseNames    map[string]ENSRecord // by address
+	subscribedNames map[string]ENSRecord // by name
+	nameAddrs       map[string]struct{}  // addresses subscribed only because a subscribed name points to them
+
+	labels map[string]Label
+
+	rules map[int]Rule
+
+	nftTransfers map[string][]NFTTransfer
+	nftHoldings  map[string]map[string]*big.Int
+
+	feeRecipientBlocks map[string][]FeeRecipientBlock
+
+	abis map[string]*abi.ABI
+}
+
+// NewTransactionStorage creates empty in-memory storage.
+func NewTransactionStorage() *TransactionStorage {
+	return &TransactionStorage{
+		observedAddrs: make(map[string]struct{}),
+		transactions:  make(map[string][]Transaction),
+		txByHash:      make(map[string]Transaction),
+		pending:       make(map[string][]Transaction),
+		pendingByHash: make(map[string]Transaction),
+		byNonce:       make(map[string][]string),
+		alertKeys:     make(map[string]struct{}),
+		balances:      make(map[string]Balance),
+		tokenBalances: make(map[string]map[string]TokenBalance),
+		tokens:        make(map[string]TokenMetadata),
+		nftTransfers:  make(map[string][]NFTTransfer),
+		nftHoldings:   make(map[string]map[string]*big.Int),
+
+		allowances: make(map[string]map[string]Allowance),
+		poolTokens: make(map[string][2]string),
+
+		userOperations: make(map[string][]UserOperation),
+
+		addressInfo: make(map[string]AddressInfo),
+
+		reverseNames:    make(map[string]ENSRecord),
+		subscribedNames: make(map[string]ENSRecord),
+		nameAddrs:       make(map[string]struct{}),
+
+		labels: make(map[string]Label),
+
+		rules: make(map[int]Rule),
+
+		feeRecipientBlocks: make(map[string][]FeeRecipientBlock),
+
+		abis: make(map[string]*abi.ABI),
+	}
 }
 
 func (ts *TransactionStorage) StoreAddress(address string) error {
@@ -23,15 +175,459 @@ func (ts *TransactionStorage) StoreAddress(address string) error {
 	return nil
 }
 
+// RemoveAddress stops observing the address, its stored transactions are kept.
+func (ts *TransactionStorage) RemoveAddress(address string) {
+	delete(ts.observedAddrs, address)
+}
+
 func (ts *TransactionStorage) StoreTransactions(address string, tx Transaction) {
 	ts.transactions[address] = append(ts.transactions[address], tx)
+	if tx.Kind == KindTransaction && tx.Hash != "" {
+		ts.txByHash[tx.Hash] = tx
+		ts.indexNonce(tx)
+	}
+}
+
+func (ts *TransactionStorage) indexNonce(tx Transaction) {
+	if tx.Nonce == "" {
+		return
+	}
+	key := tx.From + "/" + tx.Nonce
+	for _, hash := range ts.byNonce[key] {
+		if hash == tx.Hash {
+			return
+		}
+	}
+	ts.byNonce[key] = append(ts.byNonce[key], tx.Hash)
 }
 
 func (ts *TransactionStorage) Transactions(address string) []Transaction {
 	return ts.transactions[address]
 }
 
+func (ts *TransactionStorage) Transaction(hash string) (Transaction, bool) {
+	tx, exists := ts.txByHash[hash]
+	return tx, exists
+}
+
 func (ts *TransactionStorage) IsObserved(address string) bool {
 	_, observed := ts.observedAddrs[address]
 	return observed
 }
+
+func (ts *TransactionStorage) ObservedAddresses() []string {
+	addresses := make([]string, 0, len(ts.observedAddrs))
+	for address := range ts.observedAddrs {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+func (ts *TransactionStorage) StorePendingTransaction(address string, tx Transaction) {
+	ts.pending[address] = append(ts.pending[address], tx)
+	ts.pendingByHash[tx.Hash] = tx
+	ts.indexNonce(tx)
+}
+
+func (ts *TransactionStorage) PendingTransactions(address string) []Transaction {
+	return ts.pending[address]
+}
+
+func (ts *TransactionStorage) PendingTransaction(hash string) (Transaction, bool) {
+	tx, exists := ts.pendingByHash[hash]
+	return tx, exists
+}
+
+func (ts *TransactionStorage) RemovePendingTransaction(hash string) {
+	tx, exists := ts.pendingByHash[hash]
+	if !exists {
+		return
+	}
+	delete(ts.pendingByHash, hash)
+	for _, address := range []string{tx.From, tx.To} {
+		var txs []Transaction
+		for _, p := range ts.pending[address] {
+			if p.Hash != hash {
+				txs = append(txs, p)
+			}
+		}
+		if len(txs) == 0 {
+			delete(ts.pending, address)
+			continue
+		}
+		ts.pending[address] = txs
+	}
+}
+
+// UpdateTransaction replaces stored (included or pending) top-level transaction with the same hash.
+func (ts *TransactionStorage) UpdateTransaction(tx Transaction) {
+	if _, exists := ts.txByHash[tx.Hash]; exists {
+		ts.txByHash[tx.Hash] = tx
+	}
+	if _, exists := ts.pendingByHash[tx.Hash]; exists {
+		ts.pendingByHash[tx.Hash] = tx
+	}
+	for _, address := range []string{tx.From, tx.To, tx.ContractAddress} {
+		for _, txs := range [][]Transaction{ts.transactions[address], ts.pending[address]} {
+			for i := range txs {
+				if txs[i].Kind == KindTransaction && txs[i].Hash == tx.Hash {
+					txs[i] = tx
+				}
+			}
+		}
+	}
+}
+
+// TransactionsInState returns included and pending top-level transactions in any of the states.
+func (ts *TransactionStorage) TransactionsInState(states ...string) []Transaction {
+	var txs []Transaction
+	for _, byHash := range []map[string]Transaction{ts.txByHash, ts.pendingByHash} {
+		for _, tx := range byHash {
+			for _, state := range states {
+				if tx.State == state {
+					txs = append(txs, tx)
+					break
+				}
+			}
+		}
+	}
+	return txs
+}
+
+// TransactionsByNonce returns included and pending top-level transactions of the sender with the nonce.
+func (ts *TransactionStorage) TransactionsByNonce(from, nonce string) []Transaction {
+	var txs []Transaction
+	for _, hash := range ts.byNonce[from+"/"+nonce] {
+		if tx, exists := ts.txByHash[hash]; exists {
+			txs = append(txs, tx)
+		} else if tx, exists := ts.pendingByHash[hash]; exists {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+func (ts *TransactionStorage) StoreAlert(a Alert) {
+	ts.alerts = append(ts.alerts, a)
+	ts.alertKeys[a.key] = struct{}{}
+}
+
+func (ts *TransactionStorage) Alerts() []Alert {
+	return ts.alerts
+}
+
+// IsAlertRaised reports whether an alert with the key was stored.
+func (ts *TransactionStorage) IsAlertRaised(key string) bool {
+	_, exists := ts.alertKeys[key]
+	return exists
+}
+
+func (ts *TransactionStorage) StoreBalance(b Balance) {
+	ts.balances[b.Address] = b
+}
+
+func (ts *TransactionStorage) Balance(address string) (Balance, bool) {
+	b, exists := ts.balances[address]
+	return b, exists
+}
+
+func (ts *TransactionStorage) StoreTokenBalance(address string, b TokenBalance) {
+	if ts.tokenBalances[address] == nil {
+		ts.tokenBalances[address] = make(map[string]TokenBalance)
+	}
+	ts.tokenBalances[address][b.Contract] = b
+}
+
+func (ts *TransactionStorage) TokenBalance(address, contract string) (TokenBalance, bool) {
+	b, exists := ts.tokenBalances[address][contract]
+	return b, exists
+}
+
+// TokenBalances returns token balances of the address ordered by contract.
+func (ts *TransactionStorage) TokenBalances(address string) []TokenBalance {
+	balances := make([]TokenBalance, 0, len(ts.tokenBalances[address]))
+	for _, b := range ts.tokenBalances[address] {
+		balances = append(balances, b)
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Contract < balances[j].Contract })
+	return balances
+}
+
+func (ts *TransactionStorage) StoreAllowance(owner string, a Allowance) {
+	if ts.allowances[owner] == nil {
+		ts.allowances[owner] = make(map[string]Allowance)
+	}
+	ts.allowances[owner][a.Contract+"/"+a.Spender] = a
+}
+
+func (ts *TransactionStorage) Allowance(owner, contract, spender string) (Allowance, bool) {
+	a, exists := ts.allowances[owner][contract+"/"+spender]
+	return a, exists
+}
+
+func (ts *TransactionStorage) RemoveAllowance(owner, contract, spender string) {
+	delete(ts.allowances[owner], contract+"/"+spender)
+}
+
+// Allowances returns allowances granted by the owner ordered by contract and spender.
+func (ts *TransactionStorage) Allowances(owner string) []Allowance {
+	keys := make([]string, 0, len(ts.allowances[owner]))
+	for key := range ts.allowances[owner] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	allowances := make([]Allowance, 0, len(keys))
+	for _, key := range keys {
+		allowances = append(allowances, ts.allowances[owner][key])
+	}
+	return allowances
+}
+
+func (ts *TransactionStorage) StoreAddressInfo(info AddressInfo) {
+	ts.addressInfo[info.Address] = info
+}
+
+func (ts *TransactionStorage) AddressInfo(address string) (AddressInfo, bool) {
+	info, ok := ts.addressInfo[address]
+	return info, ok
+}
+
+func (ts *TransactionStorage) RemoveAddressInfo(address string) {
+	delete(ts.addressInfo, address)
+}
+
+func (ts *TransactionStorage) AddressInfoCount() int {
+	return len(ts.addressInfo)
+}
+
+func (ts *TransactionStorage) StoreReverseName(r ENSRecord) {
+	ts.reverseNames[r.Address] = r
+}
+
+func (ts *TransactionStorage) ReverseName(address string) (ENSRecord, bool) {
+	r, ok := ts.reverseNames[address]
+	return r, ok
+}
+
+func (ts *TransactionStorage) RemoveReverseName(address string) {
+	delete(ts.reverseNames, address)
+}
+
+func (ts *TransactionStorage) ReverseNames() []ENSRecord {
+	records := make([]ENSRecord, 0, len(ts.reverseNames))
+	for _, r := range ts.reverseNames {
+		records = append(records, r)
+	}
+	return records
+}
+
+func (ts *TransactionStorage) StoreSubscribedName(r ENSRecord) {
+	ts.subscribedNames[r.Name] = r
+}
+
+func (ts *TransactionStorage) SubscribedNames() []ENSRecord {
+	records := make([]ENSRecord, 0, len(ts.subscribedNames))
+	for _, r := range ts.subscribedNames {
+		records = append(records, r)
+	}
+	return records
+}
+
+func (ts *TransactionStorage) StoreNameAddress(address string) {
+	ts.nameAddrs[address] = struct{}{}
+}
+
+func (ts *TransactionStorage) IsNameAddress(address string) bool {
+	_, ok := ts.nameAddrs[address]
+	return ok
+}
+
+func (ts *TransactionStorage) RemoveNameAddress(address string) {
+	delete(ts.nameAddrs, address)
+}
+
+func (ts *TransactionStorage) StoreLabel(l Label) {
+	ts.labels[l.Address] = l
+}
+
+func (ts *TransactionStorage) Label(address string) (Label, bool) {
+	l, ok := ts.labels[address]
+	return l, ok
+}
+
+func (ts *TransactionStorage) RemoveLabel(address string) {
+	delete(ts.labels, address)
+}
+
+func (ts *TransactionStorage) Labels() []Label {
+	labels := make([]Label, 0, len(ts.labels))
+	for _, l := range ts.labels {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Address < labels[j].Address })
+	return labels
+}
+
+func (ts *TransactionStorage) StoreRule(r Rule) {
+	ts.rules[r.ID] = r
+}
+
+func (ts *TransactionStorage) Rule(id int) (Rule, bool) {
+	r, ok := ts.rules[id]
+	return r, ok
+}
+
+func (ts *TransactionStorage) RemoveRule(id int) {
+	delete(ts.rules, id)
+}
+
+func (ts *TransactionStorage) Rules() []Rule {
+	rules := make([]Rule, 0, len(ts.rules))
+	for _, r := range ts.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+func (ts *TransactionStorage) StoreUserOperation(address string, op UserOperation) {
+	ts.userOperations[address] = append(ts.userOperations[address], op)
+}
+
+func (ts *TransactionStorage) UserOperations(address string) []UserOperation {
+	return ts.userOperations[address]
+}
+
+func (ts *TransactionStorage) StorePoolTokens(pool string, tokens [2]string) {
+	ts.poolTokens[pool] = tokens
+}
+
+func (ts *TransactionStorage) PoolTokens(pool string) ([2]string, bool) {
+	tokens, exists := ts.poolTokens[pool]
+	return tokens, exists
+}
+
+func (ts *TransactionStorage) StoreTokenMetadata(contract string, m TokenMetadata) {
+	ts.tokens[contract] = m
+}
+
+func (ts *TransactionStorage) TokenMetadata(contract string) (TokenMetadata, bool) {
+	m, exists := ts.tokens[contract]
+	return m, exists
+}
+
+func (ts *TransactionStorage) AllTokenMetadata() map[string]TokenMetadata {
+	return ts.tokens
+}
+
+func (ts *TransactionStorage) StoreNFTTransfer(address string, t NFTTransfer) {
+	ts.nftTransfers[address] = append(ts.nftTransfers[address], t)
+
+	if ts.nftHoldings[address] == nil {
+		ts.nftHoldings[address] = make(map[string]*big.Int)
+	}
+	applyNFTTransfer(ts.nftHoldings[address], address, t)
+}
+
+func (ts *TransactionStorage) NFTTransfers(address string) []NFTTransfer {
+	return ts.nftTransfers[address]
+}
+
+func (ts *TransactionStorage) NFTHoldings(address string) []NFTHolding {
+	keys := make([]string, 0, len(ts.nftHoldings[address]))
+	for key := range ts.nftHoldings[address] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	holdings := make([]NFTHolding, 0, len(keys))
+	for _, key := range keys {
+		parts := strings.SplitN(key, "/", 3)
+		holdings = append(holdings, NFTHolding{
+			Standard: parts[0],
+			Contract: parts[1],
+			TokenID:  parts[2],
+			Amount:   ts.nftHoldings[address][key].String(),
+		})
+	}
+	return holdings
+}
+
+func (ts *TransactionStorage) StoreFeeRecipientBlock(address string, b FeeRecipientBlock) {
+	ts.feeRecipientBlocks[address] = append(ts.feeRecipientBlocks[address], b)
+}
+
+func (ts *TransactionStorage) FeeRecipientBlocks(address string) []FeeRecipientBlock {
+	return ts.feeRecipientBlocks[address]
+}
+
+// RemoveBlock removes internal transfers, withdrawals, NFT transfers, user operations and fee recipient entries
+// of the block. Top-level transactions are kept, their lifecycle state tells whether the block is still canonical.
+func (ts *TransactionStorage) RemoveBlock(blockNumber int) {
+	for address, txs := range ts.transactions {
+		kept := txs[:0]
+		for _, tx := range txs {
+			if tx.Kind == KindTransaction || tx.BlockNumber != blockNumber {
+				kept = append(kept, tx)
+			}
+		}
+		ts.transactions[address] = kept
+	}
+
+	for address, transfers := range ts.nftTransfers {
+		kept := make([]NFTTransfer, 0, len(transfers))
+		for _, t := range transfers {
+			if t.BlockNumber != blockNumber {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == len(transfers) {
+			continue
+		}
+		// holdings are derived from the transfers
+		ts.nftTransfers[address] = kept
+		ts.nftHoldings[address] = make(map[string]*big.Int)
+		for _, t := range kept {
+			applyNFTTransfer(ts.nftHoldings[address], address, t)
+		}
+	}
+
+	for address, ops := range ts.userOperations {
+		kept := ops[:0]
+		for _, op := range ops {
+			if op.BlockNumber != blockNumber {
+				kept = append(kept, op)
+			}
+		}
+		ts.userOperations[address] = kept
+	}
+
+	for address, blocks := range ts.feeRecipientBlocks {
+		kept := blocks[:0]
+		for _, b := range blocks {
+			if b.BlockNumber != blockNumber {
+				kept = append(kept, b)
+			}
+		}
+		ts.feeRecipientBlocks[address] = kept
+	}
+}
+
+func (ts *TransactionStorage) StoreABI(address string, a *abi.ABI) {
+	ts.abis[address] = a
+}
+
+func (ts *TransactionStorage) ABI(address string) *abi.ABI {
+	return ts.abis[address]
+}
+
+func (ts *TransactionStorage) ABIAddresses() []string {
+	addresses := make([]string, 0, len(ts.abis))
+	for address := range ts.abis {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
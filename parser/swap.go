@@ -0,0 +1,352 @@
+package parser
+
+import (
+	"encoding/hex"
+	L "ethTx/cmd/util/logging"
+	"ethTx/parser/abi"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Event signatures (topic0) of the supported swap events.
+const (
+	// Swap(address,uint256,uint256,uint256,uint256,address)
+	swapV2Topic = "0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822"
+	// Swap(address,address,int256,int256,uint160,uint128,int24)
+	swapV3Topic = "0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67"
+)
+
+// Swap protocols, forks emitting the same events (Sushiswap, Pancakeswap...) are reported as Uniswap
+const (
+	ProtocolUniswapV2 = "uniswap-v2"
+	ProtocolUniswapV3 = "uniswap-v3"
+)
+
+// Selectors of the pool functions returning its tokens
+const (
+	token0Selector = "0x0dfe1681" // token0()
+	token1Selector = "0xd21220a7" // token1()
+)
+
+// routerSignatures are swap functions of Uniswap V2 and V3 routers and their forks
+var routerSignatures = []string{
+	// V2
+	"swapExactTokensForTokens(uint256,uint256,address[],address,uint256)",
+	"swapExactTokensForETH(uint256,uint256,address[],address,uint256)",
+	"swapExactTokensForTokensSupportingFeeOnTransferTokens(uint256,uint256,address[],address,uint256)",
+	"swapExactTokensForETHSupportingFeeOnTransferTokens(uint256,uint256,address[],address,uint256)",
+	"swapExactETHForTokens(uint256,address[],address,uint256)",
+	"swapExactETHForTokensSupportingFeeOnTransferTokens(uint256,address[],address,uint256)",
+	"swapTokensForExactTokens(uint256,uint256,address[],address,uint256)",
+	"swapTokensForExactETH(uint256,uint256,address[],address,uint256)",
+	"swapETHForExactTokens(uint256,address[],address,uint256)",
+	// V3 SwapRouter
+	"exactInputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))",
+	"exactOutputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))",
+	"exactInput((bytes,address,uint256,uint256,uint256))",
+	"exactOutput((bytes,address,uint256,uint256,uint256))",
+	// V3 SwapRouter02, without deadline
+	"exactInputSingle((address,address,uint24,address,uint256,uint256,uint160))",
+	"exactOutputSingle((address,address,uint24,address,uint256,uint256,uint160))",
+	"exactInput((bytes,address,uint256,uint256))",
+	"exactOutput((bytes,address,uint256,uint256))",
+	// batched router calls
+	"multicall(bytes[])",
+	"multicall(uint256,bytes[])",
+}
+
+// routerMethods are routerSignatures by selector
+var routerMethods = func() map[string]abi.Method {
+	methods := make(map[string]abi.Method, len(routerSignatures))
+	for _, sig := range routerSignatures {
+		m, err := abi.ParseSignature(sig)
+		if err != nil {
+			panic(err)
+		}
+		methods[m.Selector] = m
+	}
+	return methods
+}()
+
+// Swap is a token swap made by a transaction
+type Swap struct {
+	Protocol  string `json:"protocol"`
+	Pool      string `json:"pool,omitempty"`     // Pool that emitted the Swap event
+	Router    string `json:"router,omitempty"`   // Router called by the transaction, for swaps decoded from calldata
+	Recipient string `json:"recipient"`          // Receiver of the bought tokens
+	TokenIn   string `json:"tokenIn,omitempty"`  // Sold token, empty when the pool tokens are unknown
+	TokenOut  string `json:"tokenOut,omitempty"` // Bought token, empty when the pool tokens are unknown
+	AmountIn  string `json:"amountIn"`           // Sold amount in token base units as decimal string
+	AmountOut string `json:"amountOut"`          // Bought amount in token base units as decimal string
+	LogIndex  int    `json:"logIndex,omitempty"`
+
+	// Swap decoded from router calldata of a transaction without Swap events, e.g. a failed one.
+	// One of the amounts is a limit set by the sender: minimal AmountOut or maximal AmountIn.
+	Intent     bool   `json:"intent,omitempty"`
+	ExactInput bool   `json:"exactInput,omitempty"` // AmountIn is exact, AmountOut is the minimum
+	Summary    string `json:"summary,omitempty"`    // e.g. `sold 1.5 WETH for 3000 USDC`
+}
+
+// decodeSwaps returns swaps of tx decoded from its Swap events or, when it has none, from the router call.
+// Tokens of the pools and their metadata are resolved with the node.
+func (bp *BlockParser) decodeSwaps(tx Transaction) []Swap {
+	var swaps []Swap
+	for _, log := range tx.Logs {
+		swap, soldToken1, ok := swapFromLog(log)
+		if !ok {
+			continue
+		}
+		if tokens, ok := bp.poolTokens(log.Address); ok {
+			swap.TokenIn, swap.TokenOut = tokens[0], tokens[1]
+			if soldToken1 {
+				swap.TokenIn, swap.TokenOut = tokens[1], tokens[0]
+			}
+		}
+		swaps = append(swaps, swap)
+	}
+	if len(swaps) == 0 {
+		swaps = decodeRouterCall(tx.To, tx.Input, tx.Value)
+	}
+	if len(swaps) == 0 {
+		return nil
+	}
+
+	for _, s := range swaps {
+		for _, token := range []string{s.TokenIn, s.TokenOut} {
+			if token != "" {
+				bp.resolveToken(token)
+			}
+		}
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	for i := range swaps {
+		swaps[i].Summary = bp.swapSummary(swaps[i])
+	}
+	return swaps
+}
+
+// swapFromLog decodes Uniswap V2 or V3 Swap event. Tokens of the pool are not known from the event,
+// soldToken1 reports whether token1 of the pool was sold for token0 or the other way round.
+func swapFromLog(log Log) (swap Swap, soldToken1 bool, ok bool) {
+	if len(log.Topics) != 3 {
+		return Swap{}, false, false
+	}
+	words := dataWords(log.Data)
+	swap = Swap{Pool: log.Address, Recipient: topicToAddress(log.Topics[2]), LogIndex: log.LogIndex}
+
+	switch {
+	case log.Topics[0] == swapV2Topic && len(words) == 4:
+		// amount0In, amount1In, amount0Out, amount1Out
+		swap.Protocol = ProtocolUniswapV2
+		amount0In, amount1In := hexToBig(words[0]), hexToBig(words[1])
+		if amount0In.Sign() > 0 {
+			swap.AmountIn, swap.AmountOut = amount0In.String(), hexToBig(words[3]).String()
+		} else {
+			swap.AmountIn, swap.AmountOut = amount1In.String(), hexToBig(words[2]).String()
+			soldToken1 = true
+		}
+
+	case log.Topics[0] == swapV3Topic && len(words) == 5:
+		// amount0, amount1 as seen by the pool: positive amount was received, negative sent
+		swap.Protocol = ProtocolUniswapV3
+		amount0, amount1 := signedWord(words[0]), signedWord(words[1])
+		if amount0.Sign() > 0 {
+			swap.AmountIn, swap.AmountOut = amount0.String(), new(big.Int).Neg(amount1).String()
+		} else {
+			swap.AmountIn, swap.AmountOut = amount1.String(), new(big.Int).Neg(amount0).String()
+			soldToken1 = true
+		}
+
+	default:
+		return Swap{}, false, false
+	}
+	return swap, soldToken1, true
+}
+
+// signedWord decodes 32 byte hex word as two's complement int256.
+func signedWord(word string) *big.Int {
+	n := hexToBig(word)
+	if len(word) == 64 && word[0] >= '8' {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return n
+}
+
+// decodeRouterCall decodes swaps requested by a call to Uniswap V2 or V3 router, including calls batched
+// with multicall. value is the ETH sent with the call, sold by the ETH variants of V2 router functions.
+func decodeRouterCall(router, input, value string) []Swap {
+	data, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil || len(data) < 4 {
+		return nil
+	}
+	method, ok := routerMethods["0x"+hex.EncodeToString(data[:4])]
+	if !ok {
+		return nil
+	}
+	call, err := method.Decode(data)
+	if err != nil {
+		L.L.Debug("Decoding router call", method.Signature, "failed:", err.Error())
+		return nil
+	}
+	args := make([]interface{}, len(call.Args))
+	for i, a := range call.Args {
+		args[i] = a.Value
+	}
+
+	swap := Swap{Router: router, Intent: true}
+	switch call.Method {
+	case "multicall":
+		var swaps []Swap
+		calls, _ := args[len(args)-1].([]interface{})
+		for _, c := range calls {
+			inner, _ := c.(string)
+			swaps = append(swaps, decodeRouterCall(router, inner, value)...)
+		}
+		return swaps
+
+	case "swapExactTokensForTokens", "swapExactTokensForETH",
+		"swapExactTokensForTokensSupportingFeeOnTransferTokens", "swapExactTokensForETHSupportingFeeOnTransferTokens":
+		// amountIn, amountOutMin, path, to, deadline
+		swap.Protocol, swap.ExactInput = ProtocolUniswapV2, true
+		swap.AmountIn, swap.AmountOut = argString(args, 0), argString(args, 1)
+		swap.TokenIn, swap.TokenOut = pathEnds(args[2])
+		swap.Recipient = argString(args, 3)
+
+	case "swapExactETHForTokens", "swapExactETHForTokensSupportingFeeOnTransferTokens":
+		// amountOutMin, path, to, deadline
+		swap.Protocol, swap.ExactInput = ProtocolUniswapV2, true
+		swap.AmountIn, swap.AmountOut = hexToBig(value).String(), argString(args, 0)
+		swap.TokenIn, swap.TokenOut = pathEnds(args[1])
+		swap.Recipient = argString(args, 2)
+
+	case "swapTokensForExactTokens", "swapTokensForExactETH":
+		// amountOut, amountInMax, path, to, deadline
+		swap.Protocol = ProtocolUniswapV2
+		swap.AmountOut, swap.AmountIn = argString(args, 0), argString(args, 1)
+		swap.TokenIn, swap.TokenOut = pathEnds(args[2])
+		swap.Recipient = argString(args, 3)
+
+	case "swapETHForExactTokens":
+		// amountOut, path, to, deadline
+		swap.Protocol = ProtocolUniswapV2
+		swap.AmountOut, swap.AmountIn = argString(args, 0), hexToBig(value).String()
+		swap.TokenIn, swap.TokenOut = pathEnds(args[1])
+		swap.Recipient = argString(args, 2)
+
+	case "exactInputSingle", "exactOutputSingle":
+		// tokenIn, tokenOut, fee, recipient, [deadline], amount, limit, sqrtPriceLimitX96
+		params, _ := args[0].([]interface{})
+		n := len(params)
+		swap.Protocol, swap.ExactInput = ProtocolUniswapV3, call.Method == "exactInputSingle"
+		swap.TokenIn, swap.TokenOut = argString(params, 0), argString(params, 1)
+		swap.Recipient = argString(params, 3)
+		swap.AmountIn, swap.AmountOut = argString(params, n-3), argString(params, n-2)
+		if !swap.ExactInput {
+			swap.AmountOut, swap.AmountIn = argString(params, n-3), argString(params, n-2)
+		}
+
+	case "exactInput", "exactOutput":
+		// path, recipient, [deadline], amount, limit
+		params, _ := args[0].([]interface{})
+		n := len(params)
+		swap.Protocol, swap.ExactInput = ProtocolUniswapV3, call.Method == "exactInput"
+		path := v3PathTokens(argString(params, 0))
+		if len(path) < 2 {
+			return nil
+		}
+		swap.TokenIn, swap.TokenOut = path[0], path[len(path)-1]
+		swap.Recipient = argString(params, 1)
+		swap.AmountIn, swap.AmountOut = argString(params, n-2), argString(params, n-1)
+		if !swap.ExactInput {
+			// exact output path is encoded from the bought token
+			swap.TokenIn, swap.TokenOut = swap.TokenOut, swap.TokenIn
+			swap.AmountOut, swap.AmountIn = argString(params, n-2), argString(params, n-1)
+		}
+
+	default:
+		return nil
+	}
+	return []Swap{swap}
+}
+
+func argString(args []interface{}, i int) string {
+	if i < 0 || i >= len(args) {
+		return ""
+	}
+	s, _ := args[i].(string)
+	return s
+}
+
+// pathEnds returns the first and the last token of V2 router path.
+func pathEnds(path interface{}) (string, string) {
+	tokens, _ := path.([]interface{})
+	if len(tokens) < 2 {
+		return "", ""
+	}
+	return argString(tokens, 0), argString(tokens, len(tokens)-1)
+}
+
+// v3PathTokens returns tokens of V3 router path encoded as token (20 bytes), fee (3 bytes), token...
+func v3PathTokens(path string) []string {
+	data, err := hex.DecodeString(strings.TrimPrefix(path, "0x"))
+	if err != nil || len(data) < 20 || (len(data)-20)%23 != 0 {
+		return nil
+	}
+	var tokens []string
+	for i := 0; i < len(data); i += 23 {
+		tokens = append(tokens, "0x"+hex.EncodeToString(data[i:i+20]))
+	}
+	return tokens
+}
+
+// poolTokens returns token0 and token1 of the pool, fetched with eth_call the first time.
+func (bp *BlockParser) poolTokens(pool string) ([2]string, bool) {
+	bp.mu.Lock()
+	tokens, known := bp.store.PoolTokens(pool)
+	bp.mu.Unlock()
+	if known {
+		return tokens, true
+	}
+
+	for i, selector := range []string{token0Selector, token1Selector} {
+		data, err := bp.callContract(pool, selector)
+		if err != nil || len(data) != 32 {
+			L.L.Error("Failed resolving tokens of pool", pool, "Error:", fmt.Sprint(err))
+			return tokens, false
+		}
+		tokens[i] = "0x" + hex.EncodeToString(data[12:])
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.store.StorePoolTokens(pool, tokens)
+	return tokens, true
+}
+
+// swapSummary describes the swap, e.g. `sold 1.5 WETH for 3000 USDC`. Caller must hold bp.mu.
+func (bp *BlockParser) swapSummary(s Swap) string {
+	amountIn, _ := new(big.Int).SetString(s.AmountIn, 10)
+	amountOut, _ := new(big.Int).SetString(s.AmountOut, 10)
+	if amountIn == nil || amountOut == nil || s.TokenIn == "" || s.TokenOut == "" {
+		return ""
+	}
+	in, out := bp.tokenAmount(s.TokenIn, amountIn), bp.tokenAmount(s.TokenOut, amountOut)
+	switch {
+	case !s.Intent:
+		return fmt.Sprintf("sold %s for %s", in, out)
+	case s.ExactInput:
+		return fmt.Sprintf("sell %s for at least %s", in, out)
+	}
+	return fmt.Sprintf("buy %s for at most %s", out, in)
+}
+
+// tokenAmount renders the amount in token units when metadata of the token is known,
+// otherwise in base units followed by the token address. Caller must hold bp.mu.
+func (bp *BlockParser) tokenAmount(token string, amount *big.Int) string {
+	if _, formatted := bp.formatTokenAmount(token, amount); formatted != "" {
+		return formatted
+	}
+	return amount.String() + " " + token
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const (
+	swapPool = "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640"
+	swapUSDC = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+	swapWETH = "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"
+)
+
+// abiWords encodes values as concatenated 32 byte words. Addresses and hex strings are left padded,
+// negative numbers use two's complement.
+func abiWords(values ...interface{}) string {
+	var sb strings.Builder
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			sb.WriteString(fmt.Sprintf("%064s", strings.TrimPrefix(v, "0x")))
+		case int:
+			n := big.NewInt(int64(v))
+			if n.Sign() < 0 {
+				n.Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+			}
+			sb.WriteString(fmt.Sprintf("%064x", n))
+		case *big.Int:
+			n := new(big.Int).Set(v)
+			if n.Sign() < 0 {
+				n.Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+			}
+			sb.WriteString(fmt.Sprintf("%064x", n))
+		}
+	}
+	return sb.String()
+}
+
+func TestSwapFromLog(t *testing.T) {
+	wei, _ := new(big.Int).SetString("1500000000000000000", 10)
+
+	// V3: 1.5 WETH (token1) sold for 3000 USDC (token0)
+	v3 := Log{
+		Address:  swapPool,
+		Topics:   []string{swapV3Topic, "0x" + abiWords(callToken), "0x" + abiWords(callWallet)},
+		Data:     "0x" + abiWords(-3000000000, wei, 1, 1, -1),
+		LogIndex: 7,
+	}
+	swap, soldToken1, ok := swapFromLog(v3)
+	if !ok || !soldToken1 || swap.Protocol != ProtocolUniswapV3 || swap.AmountIn != "1500000000000000000" || swap.AmountOut != "3000000000" || swap.Recipient != callWallet || swap.LogIndex != 7 {
+		t.Errorf("unexpected V3 swap %+v, soldToken1 %v", swap, soldToken1)
+	}
+
+	// V2: 100 of token0 sold for 5 of token1
+	v2 := Log{
+		Address: swapPool,
+		Topics:  []string{swapV2Topic, "0x" + abiWords(callToken), "0x" + abiWords(callWallet)},
+		Data:    "0x" + abiWords(100, 0, 0, 5),
+	}
+	swap, soldToken1, ok = swapFromLog(v2)
+	if !ok || soldToken1 || swap.Protocol != ProtocolUniswapV2 || swap.AmountIn != "100" || swap.AmountOut != "5" {
+		t.Errorf("unexpected V2 swap %+v, soldToken1 %v", swap, soldToken1)
+	}
+
+	v2.Topics[0] = transferTopic
+	if _, _, ok := swapFromLog(v2); ok {
+		t.Error("transfer log decoded as swap")
+	}
+}
+
+func TestDecodeRouterCall(t *testing.T) {
+	// swapExactTokensForTokens(1000, 900, [USDC, WETH], wallet, deadline)
+	v2 := "0x38ed1739" + abiWords(1000, 900, 0xa0, callWallet, 1700000000, 2, swapUSDC, swapWETH)
+	swaps := decodeRouterCall(callToken, v2, "0x0")
+	want := Swap{Protocol: ProtocolUniswapV2, Router: callToken, Recipient: callWallet, TokenIn: swapUSDC, TokenOut: swapWETH, AmountIn: "1000", AmountOut: "900", Intent: true, ExactInput: true}
+	if len(swaps) != 1 || swaps[0] != want {
+		t.Errorf("V2 router call = %+v; want %+v", swaps, want)
+	}
+
+	// multicall(deadline, [exactInputSingle((WETH, USDC, 500, wallet, 10, 20, 0))])
+	single := "04e45aaf" + abiWords(swapWETH, swapUSDC, 500, callWallet, 10, 20, 0)
+	multicall := "0x5ae401dc" + abiWords(1700000000, 0x40, 1, 0x20, len(single)/2) + single + strings.Repeat("0", 64-len(single)%64)
+	swaps = decodeRouterCall(callToken, multicall, "0x0")
+	want = Swap{Protocol: ProtocolUniswapV3, Router: callToken, Recipient: callWallet, TokenIn: swapWETH, TokenOut: swapUSDC, AmountIn: "10", AmountOut: "20", Intent: true, ExactInput: true}
+	if len(swaps) != 1 || swaps[0] != want {
+		t.Errorf("V3 multicall = %+v; want %+v", swaps, want)
+	}
+
+	// exactOutput((USDC 500 WETH path, wallet, 30, 40)) buys USDC for WETH
+	path := swapUSDC[2:] + "0001f4" + swapWETH[2:]
+	exactOutput := "0x09b81346" + abiWords(0x20, 0x80, callWallet, 30, 40, len(path)/2) + path + strings.Repeat("0", 64-len(path)%64)
+	swaps = decodeRouterCall(callToken, exactOutput, "0x0")
+	want = Swap{Protocol: ProtocolUniswapV3, Router: callToken, Recipient: callWallet, TokenIn: swapWETH, TokenOut: swapUSDC, AmountIn: "40", AmountOut: "30", Intent: true}
+	if len(swaps) != 1 || swaps[0] != want {
+		t.Errorf("V3 exact output = %+v; want %+v", swaps, want)
+	}
+
+	if swaps := decodeRouterCall(callToken, "0xa9059cbb"+abiWords(callWallet, 1), "0x0"); swaps != nil {
+		t.Errorf("transfer decoded as swap %+v", swaps)
+	}
+}
+
+func TestBlockParser_decodeSwaps(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockTokenRPC(t, map[string]string{
+		swapPool + "/" + token0Selector:   "0x" + abiWords(swapUSDC),
+		swapPool + "/" + token1Selector:   "0x" + abiWords(swapWETH),
+		swapUSDC + "/" + symbolSelector:   "0x55534443" + strings.Repeat("0", 56),
+		swapUSDC + "/" + decimalsSelector: "0x" + abiWords(6),
+		swapWETH + "/" + symbolSelector:   "0x57455448" + strings.Repeat("0", 56),
+		swapWETH + "/" + decimalsSelector: "0x" + abiWords(18),
+	})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+
+	wei, _ := new(big.Int).SetString("1500000000000000000", 10)
+	tx := Transaction{Logs: []Log{{
+		Address: swapPool,
+		Topics:  []string{swapV3Topic, "0x" + abiWords(callToken), "0x" + abiWords(callWallet)},
+		Data:    "0x" + abiWords(-3000000000, wei, 1, 1, -1),
+	}}}
+	swaps := bp.decodeSwaps(tx)
+	if len(swaps) != 1 || swaps[0].TokenIn != swapWETH || swaps[0].TokenOut != swapUSDC || swaps[0].Summary != "sold 1.5 WETH for 3000 USDC" {
+		t.Errorf("unexpected swaps %+v", swaps)
+	}
+
+	// failed router call, without Swap events
+	tx = Transaction{To: callToken, Input: "0x38ed1739" + abiWords(1000000, 900, 0xa0, callWallet, 1700000000, 2, swapUSDC, swapWETH), Value: "0x0"}
+	swaps = bp.decodeSwaps(tx)
+	if len(swaps) != 1 || swaps[0].Summary != "sell 1 USDC for at least 0.0000000000000009 WETH" {
+		t.Errorf("unexpected swaps %+v", swaps)
+	}
+}
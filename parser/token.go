@@ -0,0 +1,250 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// AlertTokenBalanceMismatch is raised when the derived token balance differs from balanceOf reported by the token
+const AlertTokenBalanceMismatch = "token-balance-mismatch"
+
+// balanceOf(address)
+const balanceOfSelector = "0x70a08231"
+
+// TokenBalance is the ERC-20 balance of a subscribed address derived from observed Transfer events
+type TokenBalance struct {
+	Contract    string          `json:"contract"`             // Token contract address
+	Balance     string          `json:"balance"`              // Balance in token base units as decimal string
+	BlockNumber int             `json:"blockNumber"`          // Last block in which the balance changed or was fetched
+	Reconciled  *Reconciliation `json:"reconciled,omitempty"` // Last check against balanceOf
+
+	Token     *TokenMetadata `json:"token,omitempty"`     // Metadata of the token when resolved
+	Formatted string         `json:"formatted,omitempty"` // Balance in token units, e.g. `1.5 USDC`
+}
+
+type tokenKey struct {
+	address  string
+	contract string
+}
+
+// tokenTransfer is a decoded ERC-20 Transfer event
+type tokenTransfer struct {
+	contract string
+	from     string
+	to       string
+	amount   *big.Int
+}
+
+// GetTokenBalances returns non-zero ERC-20 balances of the address.
+//
+// Balances are tracked only for tokens transferred while the address was subscribed.
+func (bp *BlockParser) GetTokenBalances(address string) []TokenBalance {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	balances := []TokenBalance{}
+	for _, b := range bp.store.TokenBalances(normalizeAddress(address)) {
+		if b.Balance == "0" {
+			continue
+		}
+		if amount, ok := new(big.Int).SetString(b.Balance, 10); ok {
+			b.Token, b.Formatted = bp.formatTokenAmount(b.Contract, amount)
+		}
+		balances = append(balances, b)
+	}
+	return balances
+}
+
+// tokenTransferFromLog decodes ERC-20 Transfer log. ERC-721 transfers, which share the signature
+// but have the token id indexed, are not token transfers.
+func tokenTransferFromLog(log map[string]interface{}) (tokenTransfer, bool) {
+	if removed, _ := log["removed"].(bool); removed {
+		return tokenTransfer{}, false
+	}
+	rawTopics, _ := log["topics"].([]interface{})
+	topics := make([]string, 0, len(rawTopics))
+	for _, t := range rawTopics {
+		s, _ := t.(string)
+		topics = append(topics, s)
+	}
+	data, _ := log["data"].(string)
+	amount, ok := tokenTransferAmount(topics, data)
+	if !ok {
+		return tokenTransfer{}, false
+	}
+
+	contract, _ := log["address"].(string)
+	return tokenTransfer{
+		contract: normalizeAddress(contract),
+		from:     topicToAddress(topics[1]),
+		to:       topicToAddress(topics[2]),
+		amount:   amount,
+	}, true
+}
+
+// tokenTransferAmount returns the amount transferred by ERC-20 Transfer event with the given topics and data.
+func tokenTransferAmount(topics []string, data string) (*big.Int, bool) {
+	if len(topics) != 3 || topics[0] != transferTopic {
+		return nil, false
+	}
+	words := dataWords(data)
+	if len(words) != 1 {
+		return nil, false
+	}
+	return hexToBig(words[0]), true
+}
+
+// applyTokenTransfer applies the transfer to tracked balances of observed addresses. Balances touched
+// for the first time are fetched from the token after the block is processed. Caller must hold bp.mu.
+func (bp *BlockParser) applyTokenTransfer(t tokenTransfer, blockNumber int) {
+	if t.from == t.to {
+		return
+	}
+	for _, side := range []struct {
+		address string
+		delta   *big.Int
+	}{
+		{t.from, new(big.Int).Neg(t.amount)},
+		{t.to, t.amount},
+	} {
+		if !bp.store.IsObserved(side.address) {
+			continue
+		}
+		b, tracked := bp.store.TokenBalance(side.address, t.contract)
+		if !tracked {
+			// balanceOf at the end of the block includes the transfer
+			if bp.tokenBaselines == nil {
+				bp.tokenBaselines = make(map[tokenKey]bool)
+			}
+			bp.tokenBaselines[tokenKey{address: side.address, contract: t.contract}] = true
+			continue
+		}
+		balance, _ := new(big.Int).SetString(b.Balance, 10)
+		if balance == nil {
+			balance = new(big.Int)
+		}
+		b.Balance = balance.Add(balance, side.delta).String()
+		b.BlockNumber = blockNumber
+		bp.noteTokenDelta(blockNumber, tokenKey{address: side.address, contract: t.contract}, side.delta)
+		L.L.Info("Token", t.contract, "balance of", side.address, "changed to", b.Balance)
+		bp.store.StoreTokenBalance(side.address, b)
+	}
+}
+
+// noteTokenDelta remembers the balance change applied by the block so it can be reverted when the block gets
+// orphaned. Caller must hold bp.mu.
+func (bp *BlockParser) noteTokenDelta(blockNumber int, key tokenKey, delta *big.Int) {
+	if bp.tokenDeltas == nil {
+		bp.tokenDeltas = make(map[int]map[tokenKey]*big.Int)
+	}
+	if bp.tokenDeltas[blockNumber] == nil {
+		bp.tokenDeltas[blockNumber] = make(map[tokenKey]*big.Int)
+	}
+	if bp.tokenDeltas[blockNumber][key] == nil {
+		bp.tokenDeltas[blockNumber][key] = new(big.Int)
+	}
+	bp.tokenDeltas[blockNumber][key].Add(bp.tokenDeltas[blockNumber][key], delta)
+}
+
+// revertTokenDeltas reverts token balance changes applied by the orphaned block. Caller must hold bp.mu.
+func (bp *BlockParser) revertTokenDeltas(blockNumber int) {
+	for key, delta := range bp.tokenDeltas[blockNumber] {
+		b, tracked := bp.store.TokenBalance(key.address, key.contract)
+		if !tracked {
+			continue
+		}
+		balance, _ := new(big.Int).SetString(b.Balance, 10)
+		if balance == nil {
+			balance = new(big.Int)
+		}
+		b.Balance = balance.Sub(balance, delta).String()
+		L.L.Info("Token", key.contract, "balance of", key.address, "reverted to", b.Balance)
+		bp.store.StoreTokenBalance(key.address, b)
+	}
+	delete(bp.tokenDeltas, blockNumber)
+}
+
+// updateTokenBalances fetches balances touched for the first time by the processed block and, when reconcileAll
+// is set, verifies all tracked token balances of observed addresses with balanceOf at the block.
+func (bp *BlockParser) updateTokenBalances(blockNumber int, reconcileAll bool) {
+	type update struct {
+		key     tokenKey
+		balance TokenBalance
+		tracked bool
+	}
+
+	bp.mu.Lock()
+	var updates []update
+	for key := range bp.tokenBaselines {
+		updates = append(updates, update{key: key, balance: TokenBalance{Contract: key.contract}})
+	}
+	if reconcileAll {
+		for _, address := range bp.store.ObservedAddresses() {
+			for _, b := range bp.store.TokenBalances(address) {
+				updates = append(updates, update{key: tokenKey{address: address, contract: b.Contract}, balance: b, tracked: true})
+			}
+		}
+	}
+	bp.mu.Unlock()
+
+	for _, u := range updates {
+		actual, err := bp.balanceOf(u.key.contract, u.key.address, blockNumber)
+		if err != nil {
+			// baselines are retried with the next block
+			L.L.Error("Failed fetching token", u.key.contract, "balance of", u.key.address, "Error:", err.Error())
+			continue
+		}
+
+		b := u.balance
+		if u.tracked {
+			b.Reconciled = &Reconciliation{
+				BlockNumber: blockNumber,
+				Expected:    b.Balance,
+				Actual:      actual.String(),
+				Time:        time.Now().UTC(),
+			}
+			expected, _ := new(big.Int).SetString(b.Balance, 10)
+			if expected == nil {
+				expected = new(big.Int)
+			}
+			if diff := new(big.Int).Sub(actual, expected); diff.Sign() != 0 {
+				b.Reconciled.Difference = diff.String()
+			}
+		}
+		b.Balance = actual.String()
+		b.BlockNumber = blockNumber
+
+		bp.mu.Lock()
+		if r := b.Reconciled; u.tracked && r.Difference != "" {
+			bp.raiseAlert(Alert{
+				Type:        AlertTokenBalanceMismatch,
+				Address:     u.key.address,
+				BlockNumber: blockNumber,
+				Message:     fmt.Sprintf("derived balance %s of token %s differs from balanceOf %s by %s", r.Expected, u.key.contract, r.Actual, r.Difference),
+			}, fmt.Sprintf("%s/%s/%s/%d", AlertTokenBalanceMismatch, u.key.address, u.key.contract, blockNumber))
+		}
+		delete(bp.tokenBaselines, u.key)
+		bp.store.StoreTokenBalance(u.key.address, b)
+		bp.mu.Unlock()
+	}
+}
+
+// balanceOf calls balanceOf(address) of the token contract at the block.
+func (bp *BlockParser) balanceOf(contract, address string, blockNumber int) (*big.Int, error) {
+	call := map[string]interface{}{
+		"to":   contract,
+		"data": balanceOfSelector + strings.Repeat("0", 24) + strings.TrimPrefix(address, "0x"),
+	}
+	result, err := bp.call("eth_call", call, fmt.Sprintf("0x%x", blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	data, _ := result.(string)
+	words := dataWords(data)
+	if len(words) != 1 {
+		return nil, fmt.Errorf("invalid balanceOf result: %v", result)
+	}
+	return hexToBig(words[0]), nil
+}
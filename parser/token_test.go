@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"testing"
+)
+
+// callWallet sends 5 USDT units to lifecycleRecipient
+const tokenLogs = `[
+	{
+		"address": "0xdac17f958d2ee523a2206206994597c13d831ec7",
+		"topics": [
+			"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+			"0x00000000000000000000000098c3d3183c4b8a650614ad179a1a98be0a8d6b8e",
+			"0x0000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f"
+		],
+		"data": "0x0000000000000000000000000000000000000000000000000000000000000005",
+		"transactionHash": "0xaa",
+		"logIndex": "0x1",
+		"blockNumber": "0xa"
+	}
+]`
+
+func TestTokenTransferFromLog(t *testing.T) {
+	erc721 := map[string]interface{}{
+		"address": callToken,
+		"topics":  []interface{}{transferTopic, "0x01", "0x02", "0x03"},
+		"data":    "0x",
+	}
+	if _, ok := tokenTransferFromLog(erc721); ok {
+		t.Error("ERC-721 transfer decoded as token transfer")
+	}
+
+	erc20 := map[string]interface{}{
+		"address": "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+		"topics": []interface{}{
+			transferTopic,
+			"0x00000000000000000000000098c3d3183c4b8a650614ad179a1a98be0a8d6b8e",
+			"0x0000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f",
+		},
+		"data": "0x00000000000000000000000000000000000000000000000000000000000003e8",
+	}
+	got, ok := tokenTransferFromLog(erc20)
+	if !ok || got.contract != callToken || got.from != callWallet || got.to != lifecycleRecipient || got.amount.String() != "1000" {
+		t.Errorf("unexpected token transfer %+v", got)
+	}
+
+	erc20["removed"] = true
+	if _, ok := tokenTransferFromLog(erc20); ok {
+		t.Error("removed log decoded as token transfer")
+	}
+}
+
+func TestBlockParser_updateTokenBalances(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{
+		"eth_getLogs": tokenLogs,
+		"eth_call":    `"0x0000000000000000000000000000000000000000000000000000000000000064"`,
+	})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+
+	// first transfer of the token, balance is fetched from the token
+	if err := bp.processBlockLogs(map[string]interface{}{"number": "0xa"}); err != nil {
+		t.Fatal("Failed processing block logs", err.Error())
+	}
+	if got := bp.GetTokenBalances(callWallet); len(got) != 0 {
+		t.Fatalf("balance should not be known before it is fetched, got %+v", got)
+	}
+	bp.updateTokenBalances(10, false)
+	got := bp.GetTokenBalances(callWallet)
+	if len(got) != 1 || got[0].Contract != callToken || got[0].Balance != "100" || got[0].Reconciled != nil {
+		t.Fatalf("unexpected initial balances %+v", got)
+	}
+
+	// later transfers are applied to the balance
+	if err := bp.processBlockLogs(map[string]interface{}{"number": "0xb"}); err != nil {
+		t.Fatal("Failed processing block logs", err.Error())
+	}
+	bp.updateTokenBalances(11, false)
+	if got := bp.GetTokenBalances(callWallet); got[0].Balance != "95" || got[0].BlockNumber != 11 {
+		t.Fatalf("unexpected derived balance %+v", got[0])
+	}
+
+	// balanceOf disagrees with the derived balance
+	bp.updateTokenBalances(12, true)
+	got = bp.GetTokenBalances(callWallet)
+	if r := got[0].Reconciled; got[0].Balance != "100" || r == nil || r.Expected != "95" || r.Actual != "100" || r.Difference != "5" {
+		t.Errorf("unexpected reconciled balance %+v %+v", got[0], r)
+	}
+	alerts := bp.GetAlerts(callWallet)
+	if len(alerts) != 1 || alerts[0].Type != AlertTokenBalanceMismatch {
+		t.Errorf("expected token balance mismatch alert, got %+v", alerts)
+	}
+
+	// unsubscribed receiver is not tracked
+	if got := bp.GetTokenBalances(lifecycleRecipient); len(got) != 0 {
+		t.Errorf("unsubscribed receiver should have no balances, got %+v", got)
+	}
+}
@@ -0,0 +1,216 @@
+package parser
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Selectors of the optional ERC-20 metadata functions
+const (
+	nameSelector     = "0x06fdde03" // name()
+	symbolSelector   = "0x95d89b41" // symbol()
+	decimalsSelector = "0x313ce567" // decimals()
+)
+
+// TokenMetadata describes an ERC-20 token. All fields are optional in the standard, fields the token
+// does not implement are left empty.
+type TokenMetadata struct {
+	Name     string `json:"name,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+	Decimals *int   `json:"decimals,omitempty"`
+}
+
+// LoadTokenCache loads token metadata cached in the file and keeps the file updated with newly resolved tokens.
+//
+// A missing file is not an error, it is created with the first resolved token.
+func (bp *BlockParser) LoadTokenCache(path string) error {
+	bp.tokenCache = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var tokens map[string]TokenMetadata
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	for contract, m := range tokens {
+		bp.store.StoreTokenMetadata(normalizeAddress(contract), m)
+	}
+	L.L.Info("Loaded metadata of", fmt.Sprintf("%d", len(tokens)), "tokens from", path)
+	return nil
+}
+
+// saveTokenCache writes all known token metadata to the cache file. The metadata is copied under bp.mu and written
+// after releasing it, writes are serialised so that an older copy does not replace a newer one.
+func (bp *BlockParser) saveTokenCache() error {
+	if bp.tokenCache == "" {
+		return nil
+	}
+	bp.tokenCacheMu.Lock()
+	defer bp.tokenCacheMu.Unlock()
+
+	bp.mu.Lock()
+	tokens := make(map[string]TokenMetadata)
+	for contract, m := range bp.store.AllTokenMetadata() {
+		tokens[contract] = m
+	}
+	bp.mu.Unlock()
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(bp.tokenCache, data)
+}
+
+// writeFileAtomic replaces the file at once so that an interrupted write does not lose its previous content.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resolveToken fetches metadata of the token contract unless it is already known.
+//
+// Metadata is cached only when the node answered all calls, reverted calls mean the function is not implemented.
+func (bp *BlockParser) resolveToken(contract string) {
+	bp.mu.Lock()
+	_, known := bp.store.TokenMetadata(contract)
+	bp.mu.Unlock()
+	if known {
+		return
+	}
+
+	var m TokenMetadata
+	results := make(map[string][]byte)
+	for _, selector := range []string{nameSelector, symbolSelector, decimalsSelector} {
+		data, err := bp.callContract(contract, selector)
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) {
+			L.L.Debug("Token", contract, "does not implement", selector+":", err.Error())
+			continue
+		} else if err != nil {
+			L.L.Error("Failed resolving metadata of token", contract, "Error:", err.Error())
+			return
+		}
+		results[selector] = data
+	}
+	m.Name = decodeTokenString(results[nameSelector])
+	m.Symbol = decodeTokenString(results[symbolSelector])
+	if data := results[decimalsSelector]; len(data) == 32 {
+		if decimals := new(big.Int).SetBytes(data); decimals.IsInt64() && decimals.Int64() <= 255 {
+			d := int(decimals.Int64())
+			m.Decimals = &d
+		}
+	}
+
+	bp.mu.Lock()
+	bp.store.StoreTokenMetadata(contract, m)
+	bp.mu.Unlock()
+	L.L.Info("Resolved token", contract, "symbol:", m.Symbol)
+	if err := bp.saveTokenCache(); err != nil {
+		L.L.Error("Saving token cache failed:", err.Error())
+	}
+}
+
+// callContract calls a function without arguments of the contract at the latest block.
+func (bp *BlockParser) callContract(contract, selector string) ([]byte, error) {
+	call := map[string]interface{}{
+		"to":   contract,
+		"data": selector,
+	}
+	result, err := bp.call("eth_call", call, "latest")
+	if err != nil {
+		return nil, err
+	}
+	data, ok := result.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid eth_call result: %v", result)
+	}
+	return hex.DecodeString(strings.TrimPrefix(data, "0x"))
+}
+
+// decodeTokenString decodes ABI encoded string returned by name() or symbol(). Older tokens (e.g. MKR)
+// return bytes32 instead, padded with zero bytes.
+func decodeTokenString(data []byte) string {
+	var raw []byte
+	if len(data) >= 64 && new(big.Int).SetBytes(data[:32]).Cmp(big.NewInt(32)) == 0 {
+		length := new(big.Int).SetBytes(data[32:64])
+		if !length.IsInt64() || length.Int64() > int64(len(data)-64) {
+			return ""
+		}
+		raw = data[64 : 64+length.Int64()]
+	} else if len(data) == 32 {
+		raw = data
+	}
+
+	// strip padding and anything that is not printable
+	return strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, string(raw))
+}
+
+// formatUnits renders amount in token base units as decimal number, e.g. 1500000 with 6 decimals is 1.5.
+func formatUnits(amount *big.Int, decimals int) string {
+	digits := new(big.Int).Abs(amount).String()
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+	}
+	if decimals == 0 {
+		return sign + digits
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+// formatTokenAmount returns metadata of the token and amount rendered with its decimals and symbol,
+// e.g. `1.5 USDC`. Caller must hold bp.mu.
+func (bp *BlockParser) formatTokenAmount(contract string, amount *big.Int) (*TokenMetadata, string) {
+	m, ok := bp.store.TokenMetadata(contract)
+	if !ok {
+		return nil, ""
+	}
+	if m.Decimals == nil {
+		// the amount cannot be scaled
+		return &m, ""
+	}
+	formatted := formatUnits(amount, *m.Decimals)
+	if m.Symbol != "" {
+		formatted += " " + m.Symbol
+	}
+	return &m, formatted
+}
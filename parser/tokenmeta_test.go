@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"ethTx/cmd/util/logging"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ABI encoded "USD Coin"
+const usdcName = "0x" +
+	"0000000000000000000000000000000000000000000000000000000000000020" +
+	"0000000000000000000000000000000000000000000000000000000000000008" +
+	"55534420436f696e000000000000000000000000000000000000000000000000"
+
+// bytes32 "MKR"
+const mkrSymbol = "0x4d4b520000000000000000000000000000000000000000000000000000000000"
+
+// mockTokenRPC answers eth_call with the result registered for `<contract>/<selector>` or for the selector
+// of any contract and eth_getCode with the code registered for `code/<address>`, other calls revert.
+func mockTokenRPC(t *testing.T, results map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var call struct {
+			To   string `json:"to"`
+			Data string `json:"data"`
+		}
+		var address string
+		if len(req.Params) > 0 && json.Unmarshal(req.Params[0], &address) == nil {
+			call.To, call.Data = "code", address
+		} else if len(req.Params) > 0 {
+			json.Unmarshal(req.Params[0], &call)
+		}
+		result, ok := results[call.To+"/"+call.Data]
+		if !ok {
+			result, ok = results[call.Data]
+		}
+		if !ok {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted"}}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + result + `"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDecodeTokenString(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{usdcName, "USD Coin"},
+		{mkrSymbol, "MKR"},
+		{"0x", ""},
+		// length past the end of data
+		{"0x" + usdcName[2:130] + "00", ""},
+	}
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(strings.TrimPrefix(tt.data, "0x"))
+		if got := decodeTokenString(data); got != tt.want {
+			t.Errorf("decodeTokenString(%s) = %q; want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		amount   string
+		decimals int
+		want     string
+	}{
+		{"1500000", 6, "1.5"},
+		{"1000000", 6, "1"},
+		{"1", 18, "0.000000000000000001"},
+		{"0", 18, "0"},
+		{"-250", 2, "-2.5"},
+		{"42", 0, "42"},
+	}
+	for _, tt := range tests {
+		amount, _ := new(big.Int).SetString(tt.amount, 10)
+		if got := formatUnits(amount, tt.decimals); got != tt.want {
+			t.Errorf("formatUnits(%s, %d) = %s; want %s", tt.amount, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestBlockParser_resolveToken(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockTokenRPC(t, map[string]string{
+		nameSelector:     usdcName,
+		symbolSelector:   mkrSymbol,
+		decimalsSelector: "0x0000000000000000000000000000000000000000000000000000000000000006",
+	})
+	cache := filepath.Join(t.TempDir(), "tokens.json")
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+	if err := bp.LoadTokenCache(cache); err != nil {
+		t.Fatal("missing cache file should not fail:", err)
+	}
+
+	bp.resolveToken(callToken)
+	bp.mu.Lock()
+	m, _ := bp.store.TokenMetadata(callToken)
+	tokenMeta, formatted := bp.formatTokenAmount(callToken, big.NewInt(1500000))
+	bp.mu.Unlock()
+	if m.Name != "USD Coin" || m.Symbol != "MKR" || m.Decimals == nil || *m.Decimals != 6 {
+		t.Fatalf("unexpected metadata %+v", m)
+	}
+	if tokenMeta == nil || formatted != "1.5 MKR" {
+		t.Errorf("formatTokenAmount = %v, %s; want 1.5 MKR", tokenMeta, formatted)
+	}
+
+	// cached tokens are loaded on restart without calling the node
+	restarted := &BlockParser{rpcURL: "http://127.0.0.1:0", store: NewTransactionStorage()}
+	if err := restarted.LoadTokenCache(cache); err != nil {
+		t.Fatal("failed loading token cache:", err)
+	}
+	if m, ok := restarted.store.TokenMetadata(callToken); !ok || m.Symbol != "MKR" {
+		t.Errorf("token not loaded from cache: %+v", m)
+	}
+}
+
+func TestBlockParser_resolveToken_nonStandard(t *testing.T) {
+	logging.Init("debug")
+
+	// only symbol is implemented
+	rpc := mockTokenRPC(t, map[string]string{symbolSelector: mkrSymbol})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+
+	bp.resolveToken(callToken)
+	bp.mu.Lock()
+	m, ok := bp.store.TokenMetadata(callToken)
+	tokenMeta, formatted := bp.formatTokenAmount(callToken, big.NewInt(1500000))
+	bp.mu.Unlock()
+	if !ok || m.Name != "" || m.Symbol != "MKR" || m.Decimals != nil {
+		t.Fatalf("unexpected metadata %+v", m)
+	}
+	if tokenMeta == nil || formatted != "" {
+		t.Errorf("amount without decimals should not be formatted, got %s", formatted)
+	}
+
+	// unreachable node, nothing is cached
+	bp = &BlockParser{rpcURL: "http://127.0.0.1:0", store: NewTransactionStorage()}
+	bp.resolveToken(callToken)
+	if _, ok := bp.store.TokenMetadata(callToken); ok {
+		t.Error("metadata should not be cached when the node cannot be reached")
+	}
+}
+
+func TestBlockParser_LoadTokenCache_invalid(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "tokens.json")
+	os.WriteFile(cache, []byte("not json"), 0o644)
+	bp := &BlockParser{store: NewTransactionStorage()}
+	if err := bp.LoadTokenCache(cache); err == nil {
+		t.Error("invalid cache file should fail")
+	}
+}
+
+func TestBlockParser_decodeLogs_tokenAmount(t *testing.T) {
+	decimals := 6
+	bp := &BlockParser{store: NewTransactionStorage()}
+	bp.store.StoreTokenMetadata(callToken, TokenMetadata{Symbol: "USDT", Decimals: &decimals})
+
+	tx := Transaction{Logs: []Log{{
+		Address: callToken,
+		Topics: []string{
+			transferTopic,
+			"0x00000000000000000000000098c3d3183c4b8a650614ad179a1a98be0a8d6b8e",
+			"0x0000000000000000000000003a10dc1a145da500d5fba38b9ec49c8ff11a981f",
+		},
+		Data: "0x00000000000000000000000000000000000000000000000000000000000f4240",
+	}}}
+	bp.decodeLogs(&tx)
+	if log := tx.Logs[0]; log.Token == nil || log.Token.Symbol != "USDT" || log.Formatted != "1 USDT" {
+		t.Errorf("unexpected token transfer log %+v", log)
+	}
+}
@@ -0,0 +1,224 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	TraceModeDebug  = "debug"  // debug_traceBlockByNumber with callTracer (geth, reth, nethermind...)
+	TraceModeParity = "parity" // trace_block (erigon, nethermind, openethereum style nodes)
+)
+
+// WithTraceMode enables tracking of internal transfers using the given tracing method.
+//
+// Empty mode disables tracing.
+func (bp *BlockParser) WithTraceMode(mode string) *BlockParser {
+	switch mode {
+	case "", TraceModeDebug, TraceModeParity:
+		bp.traceMode = mode
+	default:
+		L.L.Warn("Unknown trace mode", mode, "internal transfers will not be tracked")
+		bp.traceMode = ""
+	}
+	return bp
+}
+
+// processBlockTraces traces block transactions and stores internal transfers touching observed addresses.
+func (bp *BlockParser) processBlockTraces(blockData map[string]interface{}) error {
+	if bp.traceMode == "" {
+		return nil
+	}
+
+	blockNumber, ok := blockData["number"].(string)
+	if !ok {
+		return fmt.Errorf("failed parsing block.result number field")
+	}
+	timestamp, _ := blockData["timestamp"].(string)
+
+	var transfers []Transaction
+	var err error
+	switch bp.traceMode {
+	case TraceModeDebug:
+		transfers, err = bp.debugTraceTransfers(blockData, blockNumber)
+	case TraceModeParity:
+		transfers, err = bp.parityTraceTransfers(blockNumber)
+	}
+	if err != nil {
+		return err
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	for _, tx := range transfers {
+		tx.Timestamp = int64(hexToInt(timestamp))
+		if bp.store.IsObserved(tx.From) || bp.store.IsObserved(tx.To) {
+			bp.screenTransaction(&tx)
+			bp.queueTransaction(tx)
+		}
+		if bp.store.IsObserved(tx.From) {
+			L.L.Info("New internal transfer for", tx.From)
+			bp.store.StoreTransactions(tx.From, tx)
+		}
+		if tx.To != tx.From && bp.store.IsObserved(tx.To) {
+			L.L.Info("New internal transfer for", tx.To)
+			bp.store.StoreTransactions(tx.To, tx)
+		}
+		bp.noteBalanceDelta(tx)
+	}
+	L.L.Info(fmt.Sprintf("Processed %d internal transfers", len(transfers)))
+	return nil
+}
+
+// debugTraceTransfers extracts internal value transfers using callTracer.
+func (bp *BlockParser) debugTraceTransfers(blockData map[string]interface{}, blockNumber string) ([]Transaction, error) {
+	result, err := bp.call("debug_traceBlockByNumber", blockNumber, map[string]interface{}{"tracer": "callTracer"})
+	if err != nil {
+		return nil, err
+	}
+
+	traces, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid debug_traceBlockByNumber result: %v", result)
+	}
+
+	// older clients do not return txHash, traces are ordered as block transactions
+	blockTxs, _ := blockData["transactions"].([]interface{})
+
+	var transfers []Transaction
+	for i, t := range traces {
+		trace, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		txHash, _ := trace["txHash"].(string)
+		if txHash == "" && i < len(blockTxs) {
+			if tx, ok := blockTxs[i].(map[string]interface{}); ok {
+				txHash, _ = tx["hash"].(string)
+			}
+		}
+		frame, ok := trace["result"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// top-level frame is the transaction itself
+		if _, failed := frame["error"]; failed {
+			continue
+		}
+		calls, _ := frame["calls"].([]interface{})
+		for j, c := range calls {
+			transfers = walkCallFrame(transfers, c, txHash, hexToInt(blockNumber), strconv.Itoa(j))
+		}
+	}
+	return transfers, nil
+}
+
+// walkCallFrame appends value transfers of a callTracer frame and its successful subcalls.
+func walkCallFrame(transfers []Transaction, c interface{}, parentHash string, blockNumber int, traceAddress string) []Transaction {
+	frame, ok := c.(map[string]interface{})
+	if !ok {
+		return transfers
+	}
+	// reverted frames do not move any value, neither do their subcalls
+	if _, failed := frame["error"]; failed {
+		return transfers
+	}
+
+	callType, _ := frame["type"].(string)
+	from, _ := frame["from"].(string)
+	to, _ := frame["to"].(string)
+	value, _ := frame["value"].(string)
+
+	switch strings.ToUpper(callType) {
+	case "CALL", "CALLCODE", "CREATE", "CREATE2", "SELFDESTRUCT":
+		if hexToBig(value).Sign() > 0 {
+			transfers = append(transfers, internalTransfer(from, to, value, parentHash, blockNumber, traceAddress))
+		}
+	}
+
+	calls, _ := frame["calls"].([]interface{})
+	for i, sub := range calls {
+		transfers = walkCallFrame(transfers, sub, parentHash, blockNumber, traceAddress+"."+strconv.Itoa(i))
+	}
+	return transfers
+}
+
+// parityTraceTransfers extracts internal value transfers using trace_block.
+func (bp *BlockParser) parityTraceTransfers(blockNumber string) ([]Transaction, error) {
+	result, err := bp.call("trace_block", blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	traces, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid trace_block result: %v", result)
+	}
+
+	var transfers []Transaction
+	for _, t := range traces {
+		trace, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, failed := trace["error"]; failed {
+			continue
+		}
+		traceAddress, _ := trace["traceAddress"].([]interface{})
+		// empty trace address is the top-level call i.e. the transaction itself
+		if len(traceAddress) == 0 {
+			continue
+		}
+		path := make([]string, 0, len(traceAddress))
+		for _, a := range traceAddress {
+			n, _ := a.(float64)
+			path = append(path, strconv.Itoa(int(n)))
+		}
+
+		txHash, _ := trace["transactionHash"].(string)
+		blockNo, _ := trace["blockNumber"].(float64)
+		action, _ := trace["action"].(map[string]interface{})
+		res, _ := trace["result"].(map[string]interface{})
+
+		var from, to, value string
+		switch trace["type"] {
+		case "call":
+			callType, _ := action["callType"].(string)
+			if callType == "delegatecall" || callType == "staticcall" {
+				continue
+			}
+			from, _ = action["from"].(string)
+			to, _ = action["to"].(string)
+			value, _ = action["value"].(string)
+		case "create":
+			from, _ = action["from"].(string)
+			to, _ = res["address"].(string)
+			value, _ = action["value"].(string)
+		case "suicide":
+			from, _ = action["address"].(string)
+			to, _ = action["refundAddress"].(string)
+			value, _ = action["balance"].(string)
+		default:
+			continue
+		}
+
+		if hexToBig(value).Sign() > 0 {
+			transfers = append(transfers, internalTransfer(from, to, value, txHash, int(blockNo), strings.Join(path, ".")))
+		}
+	}
+	return transfers, nil
+}
+
+func internalTransfer(from, to, value, parentHash string, blockNumber int, traceAddress string) Transaction {
+	return Transaction{
+		From:         normalizeAddress(from),
+		To:           normalizeAddress(to),
+		Value:        value,
+		BlockNumber:  blockNumber,
+		Kind:         KindInternal,
+		ParentHash:   parentHash,
+		TraceAddress: traceAddress,
+	}
+}
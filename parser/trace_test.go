@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const (
+	traceWallet   = "0x98c3d3183c4b8a650614ad179a1a98be0a8d6b8e"
+	traceMultisig = "0x3a10dc1a145da500d5fba38b9ec49c8ff11a981f"
+)
+
+var callTracerResult = `[
+    {
+        "txHash": "0xaa",
+        "result": {
+            "type": "CALL",
+            "from": "0x0000000000000000000000000000000000000001",
+            "to": "` + traceMultisig + `",
+            "value": "0x0",
+            "calls": [
+                {"type": "CALL", "from": "` + traceMultisig + `", "to": "` + traceWallet + `", "value": "0xde0b6b3a7640000"},
+                {"type": "DELEGATECALL", "from": "` + traceMultisig + `", "to": "` + traceWallet + `", "value": "0x1"},
+                {"type": "CALL", "from": "` + traceMultisig + `", "to": "` + traceWallet + `", "value": "0x0"},
+                {"type": "CALL", "from": "` + traceMultisig + `", "to": "0x0000000000000000000000000000000000000002", "value": "0x0", "calls": [
+                    {"type": "CALL", "from": "0x0000000000000000000000000000000000000002", "to": "` + traceWallet + `", "value": "0x5"}
+                ]},
+                {"type": "CALL", "from": "` + traceMultisig + `", "to": "` + traceWallet + `", "value": "0x7", "error": "execution reverted"}
+            ]
+        }
+    }
+]`
+
+var traceBlockResult = `[
+    {"type": "call", "action": {"callType": "call", "from": "0x0000000000000000000000000000000000000001", "to": "` + traceMultisig + `", "value": "0x0"}, "traceAddress": [], "transactionHash": "0xaa", "blockNumber": 21202607},
+    {"type": "call", "action": {"callType": "call", "from": "` + traceMultisig + `", "to": "` + traceWallet + `", "value": "0xde0b6b3a7640000"}, "traceAddress": [0], "transactionHash": "0xaa", "blockNumber": 21202607},
+    {"type": "call", "action": {"callType": "delegatecall", "from": "` + traceMultisig + `", "to": "` + traceWallet + `", "value": "0x1"}, "traceAddress": [1], "transactionHash": "0xaa", "blockNumber": 21202607},
+    {"type": "call", "action": {"callType": "call", "from": "0x0000000000000000000000000000000000000002", "to": "` + traceWallet + `", "value": "0x5"}, "traceAddress": [3, 0], "transactionHash": "0xaa", "blockNumber": 21202607},
+    {"type": "call", "action": {"callType": "call", "from": "` + traceMultisig + `", "to": "` + traceWallet + `", "value": "0x7"}, "error": "Reverted", "traceAddress": [4], "transactionHash": "0xaa", "blockNumber": 21202607}
+]`
+
+func TestBlockParser_processBlockTraces(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{
+		"debug_traceBlockByNumber": callTracerResult,
+		"trace_block":              traceBlockResult,
+	})
+
+	for _, mode := range []string{TraceModeDebug, TraceModeParity} {
+		t.Run(mode, func(t *testing.T) {
+			bp := (&BlockParser{
+				mu:     sync.Mutex{},
+				rpcURL: rpc.URL,
+				store:  NewTransactionStorage(),
+			}).WithTraceMode(mode)
+			bp.Subscribe(traceWallet)
+
+			err := bp.processBlockTraces(map[string]interface{}{"number": "0x14386af", "timestamp": "0x6738fe6f"})
+			if err != nil {
+				t.Fatal("Failed processing block traces", err.Error())
+			}
+
+			txs := bp.GetTransactions(traceWallet)
+			if len(txs) != 2 {
+				t.Fatalf("wallet should have 2 internal transfers, has %d: %v", len(txs), txs)
+			}
+			want := []Transaction{
+				{From: traceMultisig, To: traceWallet, Value: "0xde0b6b3a7640000", BlockNumber: 0x14386af, Timestamp: 0x6738fe6f, Kind: KindInternal, ParentHash: "0xaa", TraceAddress: "0"},
+				{From: "0x0000000000000000000000000000000000000002", To: traceWallet, Value: "0x5", BlockNumber: 0x14386af, Timestamp: 0x6738fe6f, Kind: KindInternal, ParentHash: "0xaa", TraceAddress: "3.0"},
+			}
+			for i := range want {
+				if !reflect.DeepEqual(txs[i], want[i]) {
+					t.Errorf("transfer %d = %+v; want %+v", i, txs[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBlockParser_processBlockTraces_Disabled(t *testing.T) {
+	bp := &BlockParser{store: NewTransactionStorage()}
+	if err := bp.processBlockTraces(map[string]interface{}{}); err != nil {
+		t.Error("Disabled tracing should not fail:", err.Error())
+	}
+}
@@ -0,0 +1,102 @@
+package parser
+
+// UserOperationEvent(bytes32,address,address,uint256,bool,uint256,uint256) emitted by ERC-4337 EntryPoint
+const userOperationEventTopic = "0x49628fd1471006c1482da88028e9ce4dbb080b815c9b0344d39e5a8e6ec1419f"
+
+// entryPoints are the canonical ERC-4337 EntryPoint deployments. Events of other contracts are ignored
+// as anyone can emit an event claiming an operation of a subscribed account.
+var entryPoints = map[string]string{
+	"0x5ff137d4b0fdcd49dca30c7cf57e578a026d2789": "v0.6",
+	"0x0000000071727de22e5e9d8baf0edac6f37da032": "v0.7",
+	"0x4337084d9e255ff0702461cf8895ce9e3b5ff108": "v0.8",
+}
+
+// UserOperation is an ERC-4337 user operation of a subscribed smart contract account executed by a bundler
+type UserOperation struct {
+	Hash          string `json:"hash"`                // userOpHash
+	Sender        string `json:"sender"`              // Smart contract account
+	Paymaster     string `json:"paymaster,omitempty"` // Sponsor of the gas, empty when paid by the account
+	Nonce         string `json:"nonce"`               // Account nonce as decimal string, key in the upper 192 bits
+	Success       bool   `json:"success"`             // Whether the execution of the operation succeeded
+	ActualGasCost string `json:"actualGasCost"`       // Gas cost in Wei as decimal string
+	ActualGasUsed string `json:"actualGasUsed"`       // Gas used including the verification and bundler overhead
+	EntryPoint    string `json:"entryPoint"`          // EntryPoint contract that executed the operation
+	Version       string `json:"version"`             // EntryPoint version, e.g. v0.7
+	TxHash        string `json:"txHash"`              // Bundle transaction
+	Bundler       string `json:"bundler,omitempty"`   // Sender of the bundle transaction
+	LogIndex      int    `json:"logIndex"`
+	BlockNumber   int    `json:"blockNumber"`
+}
+
+// GetUserOperations returns user operations sent by the address.
+func (bp *BlockParser) GetUserOperations(address string) []UserOperation {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	ops := bp.store.UserOperations(normalizeAddress(address))
+	if ops == nil {
+		return []UserOperation{}
+	}
+	return ops
+}
+
+// userOperationFromLog decodes UserOperationEvent log of a known EntryPoint.
+func userOperationFromLog(log map[string]interface{}) (UserOperation, bool) {
+	if removed, _ := log["removed"].(bool); removed {
+		return UserOperation{}, false
+	}
+	address, _ := log["address"].(string)
+	version, ok := entryPoints[normalizeAddress(address)]
+	if !ok {
+		return UserOperation{}, false
+	}
+	rawTopics, _ := log["topics"].([]interface{})
+	topics := make([]string, 0, len(rawTopics))
+	for _, t := range rawTopics {
+		s, _ := t.(string)
+		topics = append(topics, s)
+	}
+	data, _ := log["data"].(string)
+	// nonce, success, actualGasCost, actualGasUsed
+	words := dataWords(data)
+	if len(topics) != 4 || topics[0] != userOperationEventTopic || len(words) != 4 {
+		return UserOperation{}, false
+	}
+
+	txHash, _ := log["transactionHash"].(string)
+	logIndex, _ := log["logIndex"].(string)
+	blockNumber, _ := log["blockNumber"].(string)
+	op := UserOperation{
+		Hash:          topics[1],
+		Sender:        topicToAddress(topics[2]),
+		Paymaster:     topicToAddress(topics[3]),
+		Nonce:         hexToBig(words[0]).String(),
+		Success:       hexToBig(words[1]).Sign() != 0,
+		ActualGasCost: hexToBig(words[2]).String(),
+		ActualGasUsed: hexToBig(words[3]).String(),
+		EntryPoint:    normalizeAddress(address),
+		Version:       version,
+		TxHash:        txHash,
+		LogIndex:      hexToInt(logIndex),
+		BlockNumber:   hexToInt(blockNumber),
+	}
+	if op.Paymaster == zeroAddress {
+		op.Paymaster = ""
+	}
+	return op, true
+}
+
+// blockSenders maps hashes of block transactions to their senders.
+func blockSenders(blockData map[string]interface{}) map[string]string {
+	transactions, _ := blockData["transactions"].([]interface{})
+	senders := make(map[string]string, len(transactions))
+	for _, tx := range transactions {
+		txMap, ok := tx.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hash, _ := txMap["hash"].(string)
+		from, _ := txMap["from"].(string)
+		senders[hash] = normalizeAddress(from)
+	}
+	return senders
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"testing"
+)
+
+const (
+	userOpEntryPoint = "0x0000000071727de22e5e9d8baf0edac6f37da032"
+	userOpBundler    = "0x4337001fff419768e088ce247456c1b892888084"
+)
+
+// UserOperationEvent of callWallet without paymaster: nonce 1, success, cost 0x5af3107a4000, gas used 0x186a0
+const userOpLogs = `[{
+	"address": "0x0000000071727De22E5E9d8BAf0edAc6f37da032",
+	"topics": [
+		"0x49628fd1471006c1482da88028e9ce4dbb080b815c9b0344d39e5a8e6ec1419f",
+		"0x1111111111111111111111111111111111111111111111111111111111111111",
+		"0x00000000000000000000000098c3d3183c4b8a650614ad179a1a98be0a8d6b8e",
+		"0x0000000000000000000000000000000000000000000000000000000000000000"
+	],
+	"data": "0x0000000000000000000000000000000000000000000000000000000000000001000000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000005af3107a400000000000000000000000000000000000000000000000000000000000000186a0",
+	"transactionHash": "0xbb",
+	"logIndex": "0x3",
+	"blockNumber": "0xa"
+}]`
+
+func TestUserOperationFromLog(t *testing.T) {
+	log := map[string]interface{}{
+		"address": userOpEntryPoint,
+		"topics": []interface{}{
+			userOperationEventTopic,
+			"0x1111111111111111111111111111111111111111111111111111111111111111",
+			"0x000000000000000000000000" + callWallet[2:],
+			"0x000000000000000000000000" + approvalSpender[2:],
+		},
+		"data":            "0x" + word100 + word0 + word100 + word1,
+		"transactionHash": "0xbb",
+		"logIndex":        "0x3",
+		"blockNumber":     "0xa",
+	}
+	op, ok := userOperationFromLog(log)
+	if !ok || op.Sender != callWallet || op.Paymaster != approvalSpender || op.Nonce != "100" || op.Success ||
+		op.ActualGasCost != "100" || op.ActualGasUsed != "1" || op.Version != "v0.7" || op.LogIndex != 3 || op.BlockNumber != 10 {
+		t.Errorf("unexpected user operation %+v", op)
+	}
+
+	// anyone can emit the event, only EntryPoint contracts are trusted
+	log["address"] = callToken
+	if _, ok := userOperationFromLog(log); ok {
+		t.Error("event of unknown contract decoded as user operation")
+	}
+}
+
+func TestBlockParser_processBlockLogs_userOperations(t *testing.T) {
+	logging.Init("debug")
+
+	rpc := mockRPC(t, map[string]string{"eth_getLogs": userOpLogs})
+	bp := &BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}
+	bp.Subscribe(callWallet)
+
+	block := map[string]interface{}{
+		"number": "0xa",
+		"transactions": []interface{}{
+			map[string]interface{}{"hash": "0xbb", "from": "0x4337001Fff419768e088Ce247456c1B892888084"},
+		},
+	}
+	if err := bp.processBlockLogs(block); err != nil {
+		t.Fatal("Failed processing block logs", err.Error())
+	}
+	got := bp.GetUserOperations(callWallet)
+	if len(got) != 1 {
+		t.Fatalf("expected single user operation, got %+v", got)
+	}
+	if op := got[0]; op.Bundler != userOpBundler || op.Paymaster != "" || !op.Success || op.Nonce != "1" ||
+		op.ActualGasCost != "100000000000000" || op.ActualGasUsed != "100000" || op.EntryPoint != userOpEntryPoint {
+		t.Errorf("unexpected user operation %+v", op)
+	}
+
+	if got := bp.GetUserOperations(lifecycleRecipient); len(got) != 0 {
+		t.Errorf("unexpected user operations of unsubscribed address %+v", got)
+	}
+}
@@ -0,0 +1,71 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"ethTx/parser/rawtx"
+	"fmt"
+	"strings"
+)
+
+// Verification is the result of checking node reported transaction data against the signed raw transaction
+type Verification struct {
+	Hash       string   `json:"hash,omitempty"`       // Keccak of the raw transaction
+	Sender     string   `json:"sender,omitempty"`     // Address recovered from the signature
+	Mismatches []string `json:"mismatches,omitempty"` // Fields in which node reported data differs from the raw transaction
+	Error      string   `json:"error,omitempty"`      // Raw transaction could not be decoded or its sender recovered
+}
+
+// WithVerification makes the parser verify matched transactions against their raw signed form.
+func (bp *BlockParser) WithVerification(enabled bool) *BlockParser {
+	bp.verifyTransactions = enabled
+	return bp
+}
+
+// getRawTransaction fetches signed transaction using eth_getRawTransactionByHash.
+func (bp *BlockParser) getRawTransaction(hash string) (string, error) {
+	result, err := bp.call("eth_getRawTransactionByHash", hash)
+	if err != nil {
+		return "", err
+	}
+
+	raw, ok := result.(string)
+	if !ok || raw == "" || raw == "0x" {
+		return "", fmt.Errorf("raw transaction %s not available: %v", hash, result)
+	}
+	return raw, nil
+}
+
+// verifyTransaction decodes raw form of tx, recovers its sender and compares it with node reported data.
+func (bp *BlockParser) verifyTransaction(tx *Transaction) {
+	raw, err := bp.getRawTransaction(tx.Hash)
+	if err != nil {
+		L.L.Error("Failed fetching raw transaction", tx.Hash, "Error:", err.Error())
+		return
+	}
+
+	tx.Verification = &Verification{}
+	decoded, err := rawtx.DecodeHex(raw)
+	if err != nil {
+		L.L.Warn("Transaction", tx.Hash, "could not be verified:", err.Error())
+		tx.Verification.Error = err.Error()
+		return
+	}
+	tx.Verification.Hash = decoded.Hash
+	tx.Verification.Sender = decoded.From
+
+	if !strings.EqualFold(decoded.Hash, tx.Hash) {
+		tx.Verification.Mismatches = append(tx.Verification.Mismatches, "hash")
+	}
+	if decoded.From != tx.From {
+		tx.Verification.Mismatches = append(tx.Verification.Mismatches, "from")
+	}
+	if decoded.To != tx.To {
+		tx.Verification.Mismatches = append(tx.Verification.Mismatches, "to")
+	}
+	if decoded.Value.Cmp(hexToBig(tx.Value)) != 0 {
+		tx.Verification.Mismatches = append(tx.Verification.Mismatches, "value")
+	}
+	if len(tx.Verification.Mismatches) > 0 {
+		L.L.Warn("Transaction", tx.Hash, "differs from its raw form in:", strings.Join(tx.Verification.Mismatches, ", "))
+	}
+}
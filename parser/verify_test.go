@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"ethTx/cmd/util/logging"
+	"reflect"
+	"testing"
+)
+
+// EIP-155 example transaction
+const (
+	rawEIP155    = `"0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"`
+	hashEIP155   = "0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788"
+	senderEIP155 = "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f"
+)
+
+func TestBlockParser_verifyTransaction(t *testing.T) {
+	logging.Init("debug")
+
+	tests := []struct {
+		name       string
+		from       string
+		value      string
+		mismatches []string
+	}{
+		{"matching", "0x9D8A62F656A8D1615C1294FD71E9CFB3E4855A4F", "0xde0b6b3a7640000", nil},
+		{"wrong sender", "0x3535353535353535353535353535353535353535", "0xde0b6b3a7640000", []string{"from"}},
+		{"wrong value", senderEIP155, "0x1", []string{"value"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpc := mockRPC(t, map[string]string{"eth_getRawTransactionByHash": rawEIP155})
+			bp := (&BlockParser{rpcURL: rpc.URL, store: NewTransactionStorage()}).WithVerification(true)
+			bp.Subscribe(tt.from)
+
+			block := map[string]interface{}{
+				"transactions": []interface{}{
+					map[string]interface{}{
+						"hash":        hashEIP155,
+						"from":        tt.from,
+						"to":          "0x3535353535353535353535353535353535353535",
+						"value":       tt.value,
+						"blockNumber": "0x1",
+					},
+				},
+			}
+			if err := bp.processBlockTransactions(block); err != nil {
+				t.Fatal(err)
+			}
+
+			tx, ok := bp.GetTransaction(hashEIP155)
+			if !ok || tx.Verification == nil {
+				t.Fatalf("transaction should be stored with verification: %+v", tx)
+			}
+			if tx.Verification.Sender != senderEIP155 || tx.Verification.Hash != hashEIP155 {
+				t.Errorf("unexpected verification %+v", tx.Verification)
+			}
+			if !reflect.DeepEqual(tx.Verification.Mismatches, tt.mismatches) {
+				t.Errorf("Mismatches = %v; want %v", tx.Verification.Mismatches, tt.mismatches)
+			}
+		})
+	}
+
+	t.Run("undecodable", func(t *testing.T) {
+		rpc := mockRPC(t, map[string]string{"eth_getRawTransactionByHash": `"0x05c0"`})
+		bp := &BlockParser{rpcURL: rpc.URL}
+		tx := Transaction{Hash: hashEIP155, From: senderEIP155}
+		bp.verifyTransaction(&tx)
+		if tx.Verification == nil || tx.Verification.Error == "" {
+			t.Errorf("expected verification error, got %+v", tx.Verification)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		bp := &BlockParser{store: NewTransactionStorage()}
+		bp.Subscribe(senderEIP155)
+		block := map[string]interface{}{"transactions": []interface{}{
+			map[string]interface{}{"hash": hashEIP155, "from": senderEIP155, "value": "0x0", "blockNumber": "0x1"},
+		}}
+		if err := bp.processBlockTransactions(block); err != nil {
+			t.Fatal(err)
+		}
+		if tx, _ := bp.GetTransaction(hashEIP155); tx.Verification != nil {
+			t.Errorf("verification should be disabled by default, got %+v", tx.Verification)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package parser
+
+import (
+	L "ethTx/cmd/util/logging"
+	"fmt"
+	"math/big"
+)
+
+// Withdrawal describes a beacon chain withdrawal (EIP-4895)
+type Withdrawal struct {
+	Index          int    `json:"index"`          // Monotonic withdrawal index
+	ValidatorIndex int    `json:"validatorIndex"` // Index of the validator that is withdrawing
+	AmountGwei     string `json:"amountGwei"`     // Withdrawn amount in Gwei as decimal string
+}
+
+var gweiToWei = big.NewInt(1_000_000_000)
+
+// processBlockWithdrawals stores block withdrawals crediting observed addresses.
+//
+// Blocks produced before Shanghai have no withdrawals and are skipped.
+func (bp *BlockParser) processBlockWithdrawals(blockData map[string]interface{}) error {
+	rawWithdrawals, exists := blockData["withdrawals"]
+	if !exists || rawWithdrawals == nil {
+		return nil
+	}
+	withdrawals, ok := rawWithdrawals.([]interface{})
+	if !ok {
+		return fmt.Errorf("failed parsing block.result withdrawals field")
+	}
+
+	blockNumber, _ := blockData["number"].(string)
+	timestamp, _ := blockData["timestamp"].(string)
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	for _, w := range withdrawals {
+		wMap, ok := w.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		address, _ := wMap["address"].(string)
+		address = normalizeAddress(address)
+		if !bp.store.IsObserved(address) {
+			continue
+		}
+
+		amount, _ := wMap["amount"].(string)
+		index, _ := wMap["index"].(string)
+		validatorIndex, _ := wMap["validatorIndex"].(string)
+
+		amountGwei := hexToBig(amount)
+		amountWei := new(big.Int).Mul(amountGwei, gweiToWei)
+
+		L.L.Info("New withdrawal for", address)
+		tx := Transaction{
+			To:          address,
+			Value:       fmt.Sprintf("0x%x", amountWei),
+			BlockNumber: hexToInt(blockNumber),
+			Timestamp:   int64(hexToInt(timestamp)),
+			Kind:        KindWithdrawal,
+			Withdrawal: &Withdrawal{
+				Index:          hexToInt(index),
+				ValidatorIndex: hexToInt(validatorIndex),
+				AmountGwei:     amountGwei.String(),
+			},
+		}
+		bp.store.StoreTransactions(address, tx)
+		bp.noteBalanceDelta(tx)
+		bp.queueTransaction(tx)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"encoding/json"
+	"ethTx/cmd/util/logging"
+	"testing"
+)
+
+func TestBlockParser_processBlockWithdrawals(t *testing.T) {
+	logging.Init("debug")
+
+	const validatorAddr = "0xb9d7934878b5fb9610b3fe8a5e441e8fad7e293f"
+	bp := &BlockParser{store: NewTransactionStorage()}
+	bp.Subscribe(validatorAddr)
+
+	var blockData map[string]interface{}
+	json.Unmarshal([]byte(block), &blockData)
+
+	if err := bp.processBlockWithdrawals(blockData); err != nil {
+		t.Fatal("Failed processing block withdrawals", err.Error())
+	}
+
+	txs := bp.GetTransactions(validatorAddr)
+	if len(txs) != 16 {
+		t.Fatalf("address should have 16 withdrawals, has %d", len(txs))
+	}
+
+	first := txs[0]
+	if first.Kind != KindWithdrawal || first.To != validatorAddr || first.BlockNumber != 0x14386af || first.Timestamp != 0x6738fe6f {
+		t.Errorf("unexpected withdrawal entry %+v", first)
+	}
+	// 0x12752af Gwei
+	want := Withdrawal{Index: 0x3f97ddb, ValidatorIndex: 0xc6d88, AmountGwei: "19354287"}
+	if first.Withdrawal == nil || *first.Withdrawal != want {
+		t.Errorf("withdrawal = %+v; want %+v", first.Withdrawal, want)
+	}
+	if first.Value != "0x44c29f19841600" {
+		t.Errorf("value = %s; want 0x44c29f19841600", first.Value)
+	}
+
+	// pre-Shanghai block
+	delete(blockData, "withdrawals")
+	if err := bp.processBlockWithdrawals(blockData); err != nil {
+		t.Error("Block without withdrawals should not fail:", err.Error())
+	}
+}